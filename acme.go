@@ -15,10 +15,12 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"9fans.net/go/plumb"
 	"github.com/rjkroege/edwood/internal/draw"
+	"github.com/rjkroege/edwood/internal/draw/memdraw"
 	"github.com/rjkroege/edwood/internal/dumpfile"
 	"github.com/rjkroege/edwood/internal/frame"
 )
@@ -27,13 +29,28 @@ var (
 	command []*Command
 
 	debugAddr         = flag.String("debug", "", "Serve debug information on the supplied address")
+	httpAddr          = flag.String("http", "", "Serve the HTTP/JSON API to the file system on the supplied address (only a loopback address unless -fsys.token is given)")
+	fsysTraceFlag     = flag.String("fsys.trace", "", "Record every 9P message, with timing, in the supplied file")
 	globalAutoIndent  = flag.Bool("a", false, "Start each window in autoindent mode")
+	globalGutter      = flag.String("gutter", "", "Start each window with a gutter of absolute or relative line numbers")
+	themefile         = flag.String("theme", "", "Read the colours to use from the supplied theme file")
+	tickflag          = flag.String("tick", "thin", "Draw the insertion point as a thin bar, a thick bar, a block or an underline: thin, bar, block or underline")
+	blinkflag         = flag.Duration("blink", 0, "Blink the insertion point, showing and hiding it for the supplied duration")
+	scaleflag         = flag.String("scale", "auto", "Scale everything drawn by the supplied factor instead of the one given by the resolution of the display")
 	barflag           = flag.Bool("b", false, "Click to focus window instead of focus follows mouse (Bart's flag)")
-	varfontflag       = flag.String("f", defaultVarFont, "Variable-width font")
-	fixedfontflag     = flag.String("F", defaultFixedFont, "Fixed-width font")
+	varfontflag       = flag.String("f", defaultVarFont, "Variable-width font or a comma-separated chain of fonts to take missing glyphs from")
+	fixedfontflag     = flag.String("F", defaultFixedFont, "Fixed-width font or a comma-separated chain of fonts to take missing glyphs from")
 	mtpt              = flag.String("m", defaultMtpt, "Mountpoint for 9P file server")
 	swapScrollButtons = flag.Bool("r", false, "Swap scroll buttons")
 	winsize           = flag.String("W", "1024x768", "Window size and position as WidthxHeight[@X,Y]")
+	headlessflag      = flag.Bool("headless", false, "Run without a display, serving only the 9P file system")
+	recordflag        = flag.String("record", "", "Record the mouse and keyboard events, with their times, in the supplied file")
+	replayflag        = flag.String("replay", "", "Replay the mouse and keyboard events recorded in the supplied file; needs -headless")
+	sessionflag       = flag.String("session", "", "Start in the named session, which is saved on switching session and on exit")
+	sessionsflag      = flag.Bool("sessions", false, "List the saved sessions and exit")
+	autodumpflag      = flag.Duration("autodump", 0, "Dump the state of Edwood at the supplied interval, for -restore")
+	autodumpsflag     = flag.Int("autodumps", 3, "Number of generations of automatic dumps to keep")
+	restoreflag       = flag.Bool("restore", false, "Restore the latest automatic dump, as after a crash")
 )
 
 func main() {
@@ -49,6 +66,26 @@ func main() {
 	flag.IntVar(&ncol, "c", 2, "Number of columns at startup")
 	flag.StringVar(&loadfile, "l", "", "Load state from file generated with Dump command")
 	flag.Parse()
+	if _, err := parseGutterMode(*globalGutter); err != nil {
+		log.Fatal(err)
+	}
+	if style, err := frame.ParseTickStyle(*tickflag); err != nil {
+		log.Fatal(err)
+	} else {
+		tickstyle = style
+	}
+	tickblink = *blinkflag
+	scale, err := parsescale(*scaleflag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *themefile != "" {
+		th, err := loadtheme(*themefile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		theme = th
+	}
 
 	if *debugAddr != "" {
 		go func() {
@@ -56,7 +93,6 @@ func main() {
 		}()
 	}
 
-	var err error
 	home, err = os.UserHomeDir()
 	if err != nil {
 		log.Fatalf("could not get user home directory: %v", err)
@@ -71,6 +107,41 @@ func main() {
 		maxtab = 4
 	}
 
+	if *sessionsflag {
+		names, err := sessionnames()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return
+	}
+	var sessionload string
+	if *sessionflag != "" {
+		file, err := sessionfile(*sessionflag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cursession = *sessionflag
+		sessionload = file
+	}
+	// The automatic dump of a session is newer than its saved state.
+	if *restoreflag && loadfile == "" {
+		file, err := autodumpfile()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if _, err := os.Stat(file); err != nil {
+			log.Printf("can't restore automatic dump: %v", err)
+		} else {
+			loadfile = file
+		}
+	}
+	if _, err := os.Stat(sessionload); err == nil && loadfile == "" {
+		loadfile = sessionload
+	}
+
 	var dump *dumpfile.Content
 
 	if loadfile != "" {
@@ -90,7 +161,7 @@ func main() {
 		}
 	}
 
-	os.Setenv("font", *varfontflag)
+	os.Setenv("font", primaryfont(*varfontflag))
 
 	// TODO(flux): this must be 9p open?  It's unused in the C code after its opening.
 	// Is it just somehow to keep it open?
@@ -98,103 +169,145 @@ func main() {
 
 	wdir, _ = os.Getwd()
 
+	if *headlessflag {
+		display, err := headlessdisplay(*winsize)
+		if err != nil {
+			log.Fatalf("can't make display: %v\n", err)
+		}
+		display.SetScale(scale)
+		edwood(display, dump, loadfile, ncol)
+		return
+	}
 	draw.Main(func(dd *draw.Device) {
-		display, err := dd.NewDisplay(nil, *varfontflag, "edwood", *winsize)
+		display, err := dd.NewDisplay(nil, primaryfont(*varfontflag), "edwood", *winsize)
 		if err != nil {
 			log.Fatalf("can't open display: %v\n", err)
 		}
 		if err := display.Attach(draw.Refnone); err != nil {
 			panic("failed to attach to window")
 		}
-		display.ScreenImage().Draw(display.ScreenImage().R(), display.White(), nil, image.Point{})
+		display.SetScale(scale)
+		edwood(display, dump, loadfile, ncol)
+	})
+}
 
-		mousectl = display.InitMouse()
-		keyboardctl = display.InitKeyboard()
+// edwood runs Edwood on display, starting with the windows of dump
+// or those of the files named on the command line, until it exits.
+func edwood(display draw.Display, dump *dumpfile.Content, loadfile string, ncol int) {
+	display.ScreenImage().Draw(display.ScreenImage().R(), display.White(), nil, image.Point{})
 
-		tagfont = *varfontflag
+	mousectl = display.InitMouse()
+	keyboardctl = display.InitKeyboard()
 
-		iconinit(display)
+	tagfont = *varfontflag
 
-		cwait = make(chan ProcessState)
-		ccommand = make(chan *Command)
-		ckill = make(chan string)
-		cxfidalloc = make(chan *Xfid)
-		cxfidfree = make(chan *Xfid)
-		cnewwindow = make(chan *Window)
-		csignal = make(chan os.Signal, 1)
-		cerr = make(chan error)
-		cedit = make(chan int)
-		cexit = make(chan struct{})
-		cwarn = make(chan uint)
+	iconinit(display)
 
-		mousectl = display.InitMouse()
-		mouse = &mousectl.Mouse
+	cwait = make(chan ProcessState)
+	ccommand = make(chan *Command)
+	ckill = make(chan string)
+	xfids = newXfidAllocator()
+	cnewwindow = make(chan *Window)
+	csignal = make(chan os.Signal, 1)
+	cerr = make(chan error)
+	cedit = make(chan int)
+	cexit = make(chan struct{})
+	cwarn = make(chan uint)
 
-		startplumbing()
-		fs := fsysinit()
+	mousectl = display.InitMouse()
+	if *recordflag != "" {
+		f, err := os.Create(*recordflag)
+		if err != nil {
+			log.Fatalf("can't record input: %v", err)
+		}
+		mousectl, keyboardctl = recordinput(mousectl, keyboardctl, f)
+	}
+	mouse = &mousectl.Mouse
+
+	startplumbing()
+	fs := fsysinit()
+	if *httpAddr != "" {
+		token, err := fsystoken()
+		if err != nil {
+			acmeerror("can't serve the HTTP API", err)
+		}
+		go func() {
+			log.Println(serveHTTPAPI(*httpAddr, token))
+		}()
+	}
 
-		// disk = NewDisk()  TODO(flux): Let's be sure we'll avoid this paging stuff
+	// disk = NewDisk()  TODO(flux): Let's be sure we'll avoid this paging stuff
 
-		const WindowsPerCol = 6
+	const WindowsPerCol = 6
 
-		row.Init(display.ScreenImage().R(), display)
-		if loadfile == "" || row.Load(dump, loadfile, true) != nil {
-			// Open the files from the command line, up to WindowsPerCol each
-			files := flag.Args()
-			if ncol < 0 {
-				if len(files) == 0 {
+	row.Init(display.ScreenImage().R(), display)
+	if loadfile == "" || row.Load(dump, loadfile, true) != nil {
+		// Open the files from the command line, up to WindowsPerCol each
+		files := flag.Args()
+		if ncol < 0 {
+			if len(files) == 0 {
+				ncol = 2
+			} else {
+				ncol = (len(files) + (WindowsPerCol - 1)) / WindowsPerCol
+				if ncol < 2 {
 					ncol = 2
-				} else {
-					ncol = (len(files) + (WindowsPerCol - 1)) / WindowsPerCol
-					if ncol < 2 {
-						ncol = 2
-					}
 				}
 			}
-			if ncol == 0 {
-				ncol = 2
-			}
-			for i := 0; i < ncol; i++ {
-				row.Add(nil, -1)
-			}
-			rightmostcol := row.col[len(row.col)-1]
-			if len(files) == 0 {
-				readfile(row.col[len(row.col)-1], wdir)
-			} else {
-				for i, filename := range files {
-					// guide  always goes in the rightmost column
-					if filepath.Base(filename) == "guide" || i/WindowsPerCol >= len(row.col) {
-						readfile(rightmostcol, filename)
-					} else {
-						readfile(row.col[i/WindowsPerCol], filename)
-					}
+		}
+		if ncol == 0 {
+			ncol = 2
+		}
+		for i := 0; i < ncol; i++ {
+			row.Add(nil, -1)
+		}
+		rightmostcol := row.col[len(row.col)-1]
+		if len(files) == 0 {
+			readfile(row.col[len(row.col)-1], wdir)
+		} else {
+			for i, filename := range files {
+				// guide  always goes in the rightmost column
+				if filepath.Base(filename) == "guide" || i/WindowsPerCol >= len(row.col) {
+					readfile(rightmostcol, filename)
+				} else {
+					readfile(row.col[i/WindowsPerCol], filename)
 				}
 			}
 		}
-		display.Flush()
-
-		// After row is initialized
-		ctx := context.Background()
-		go mousethread(display)
-		go keyboardthread(display)
-		go waitthread(ctx)
-		go newwindowthread()
-		go xfidallocthread(ctx, display)
+	}
+	display.Flush()
+
+	// After row is initialized
+	ctx := context.Background()
+	go mousethread(display)
+	go keyboardthread(display)
+	go waitthread(ctx)
+	go newwindowthread()
+	go xfidallocthread(ctx, xfids, display)
+	if *autodumpflag > 0 {
+		go autodumpthread(*autodumpflag, *autodumpsflag)
+	}
+	if *replayflag != "" {
+		go func() {
+			if err := replayfile(display, *replayflag); err != nil {
+				warning(nil, "can't replay %v: %v\n", *replayflag, err)
+			}
+		}()
+	}
 
-		signal.Ignore(ignoreSignals...)
-		signal.Notify(csignal, hangupSignals...)
+	signal.Ignore(ignoreSignals...)
+	signal.Notify(csignal, hangupSignals...)
 
-		select {
-		case <-cexit:
-			// Do nothing.
-		case <-csignal:
-			row.lk.Lock()
-			row.Dump("")
-			row.lk.Unlock()
-		}
-		killprocs(fs)
-		os.Exit(0)
-	})
+	select {
+	case <-cexit:
+		// Do nothing.
+	case <-csignal:
+		row.lk.Lock()
+		row.Dump("")
+		row.SaveSession()
+		row.lk.Unlock()
+	}
+	killprocs(fs)
+	os.Exit(0)
 }
 
 func readfile(c *Column, filename string) {
@@ -212,21 +325,56 @@ func readfile(c *Column, filename string) {
 
 var fontCache = make(map[string]draw.Font)
 
+// fontget returns the font called name. The name may be a list of
+// fonts separated by commas, a chain in which each rune is taken from
+// the first font that has a glyph for it. Fonts of the chain after the
+// first that can't be opened are left out.
 func fontget(name string, display draw.Display) draw.Font {
 	var font draw.Font
 	var ok bool
 	if font, ok = fontCache[name]; !ok {
-		f, err := display.OpenFont(name)
-		if err != nil {
-			warning(nil, "can't open font file %s: %v\n", name, err)
-			return nil
+		var fonts []draw.Font
+		for i, n := range strings.Split(name, draw.FontChainSep) {
+			f, err := display.OpenFont(n)
+			if err != nil {
+				warning(nil, "can't open font file %s: %v\n", n, err)
+				if i == 0 {
+					return nil
+				}
+				continue
+			}
+			fonts = append(fonts, f)
 		}
-		fontCache[name] = f
-		font = f
+		font = draw.NewFontChain(fonts...)
+		fontCache[name] = font
 	}
 	return font
 }
 
+// headlessdisplay returns the in-memory display of -headless, with a
+// screen of the size given by winsize as WidthxHeight[@X,Y]. Fonts
+// that can't be read are replaced by the display's default font.
+func headlessdisplay(winsize string) (*memdraw.Display, error) {
+	var w, h int
+	if _, err := fmt.Sscanf(strings.SplitN(winsize, "@", 2)[0], "%dx%d", &w, &h); err != nil {
+		return nil, fmt.Errorf("bad window size %q", winsize)
+	}
+	display := memdraw.NewDisplay(image.Rect(0, 0, w, h))
+	for _, f := range []*string{varfontflag, fixedfontflag} {
+		if _, err := display.OpenFont(primaryfont(*f)); err != nil {
+			log.Printf("can't open font %v: %v; using default font", *f, err)
+			*f = memdraw.DefaultFont
+		}
+	}
+	return display, nil
+}
+
+// primaryfont returns the name of the first font of the font chain
+// called name.
+func primaryfont(name string) string {
+	return strings.Split(name, draw.FontChainSep)[0]
+}
+
 var boxcursor = draw.Cursor{
 	Point: image.Point{-7, -7},
 	Clr: [32]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
@@ -242,16 +390,7 @@ var boxcursor = draw.Cursor{
 func iconinit(display draw.Display) {
 	//TODO(flux): Probably should de-globalize colors.
 	if tagcolors[frame.ColBack] == nil {
-		tagcolors[frame.ColBack] = display.AllocImageMix(draw.Palebluegreen, draw.White)
-		tagcolors[frame.ColHigh], _ = display.AllocImage(image.Rect(0, 0, 1, 1), display.ScreenImage().Pix(), true, draw.Palegreygreen)
-		tagcolors[frame.ColBord], _ = display.AllocImage(image.Rect(0, 0, 1, 1), display.ScreenImage().Pix(), true, draw.Purpleblue)
-		tagcolors[frame.ColText] = display.Black()
-		tagcolors[frame.ColHText] = display.Black()
-		textcolors[frame.ColBack] = display.AllocImageMix(draw.Paleyellow, draw.White)
-		textcolors[frame.ColHigh], _ = display.AllocImage(image.Rect(0, 0, 1, 1), display.ScreenImage().Pix(), true, draw.Darkyellow)
-		textcolors[frame.ColBord], _ = display.AllocImage(image.Rect(0, 0, 1, 1), display.ScreenImage().Pix(), true, draw.Yellowgreen)
-		textcolors[frame.ColText] = display.Black()
-		textcolors[frame.ColHText] = display.Black()
+		themeinit(display, theme)
 	}
 
 	// ...
@@ -267,15 +406,11 @@ func iconinit(display draw.Display) {
 	r.Max.X -= display.ScaleSize(ButtonBorder)
 	modbutton.Border(r, display.ScaleSize(ButtonBorder), tagcolors[frame.ColBord], image.Point{})
 	r = r.Inset(display.ScaleSize(ButtonBorder))
-	tmp, _ := display.AllocImage(image.Rect(0, 0, 1, 1), display.ScreenImage().Pix(), true, draw.Medblue)
-	modbutton.Draw(r, tmp, nil, image.Point{})
+	modbutton.Draw(r, modbuttoncol, nil, image.Point{})
 
 	r = button.R()
-	colbutton, _ = display.AllocImage(r, display.ScreenImage().Pix(), false, draw.Purpleblue)
-
-	but2col, _ = display.AllocImage(image.Rect(0, 0, 1, 1), display.ScreenImage().Pix(), true, 0xAA0000FF)
-	but3col, _ = display.AllocImage(image.Rect(0, 0, 1, 1), display.ScreenImage().Pix(), true, 0x006600FF)
-
+	colbutton, _ = display.AllocImage(r, display.ScreenImage().Pix(), false, draw.Notacolor)
+	colbutton.Draw(r, colbuttoncol, nil, image.Point{})
 }
 
 func ismtpt(filename string) bool {
@@ -287,6 +422,14 @@ func ismtpt(filename string) bool {
 	return strings.HasPrefix(s, m) && (m[len(m)-1] == '/' || len(s) == len(m) || s[len(m)] == '/')
 }
 
+// readmouse flushes display and waits for the next mouse event, which
+// it stores in mousectl.Mouse. Unlike Mousectl.Read, it works for any
+// display, such as the one of -headless.
+func readmouse(display draw.Display) {
+	display.Flush()
+	mousectl.Mouse = <-mousectl.C
+}
+
 func mousethread(display draw.Display) {
 	// TODO(rjk): Do we need this?
 	runtime.LockOSThread()
@@ -298,15 +441,26 @@ func mousethread(display draw.Display) {
 		display.Flush()
 		select {
 		case <-mousectl.Resize:
+			// The window may have moved to a monitor of another
+			// resolution, which changes the scale.
+			oscale := display.ScaleSize(100)
 			if err := display.Attach(draw.Refnone); err != nil {
 				panic("failed to attach to window")
 			}
 			display.ScreenImage().Draw(display.ScreenImage().R(), display.White(), nil, image.Point{})
-			iconinit(display)
-			ScrlResize(display)
-			row.Resize(display.ScreenImage().R())
-		case mousectl.Mouse = <-mousectl.C:
-			MovedMouse(mousectl.Mouse)
+			if display.ScaleSize(100) != oscale {
+				rescale(display, display.ScreenImage().R())
+			} else {
+				iconinit(display)
+				ScrlResize(display)
+				row.Resize(display.ScreenImage().R())
+			}
+		case m := <-mousectl.C:
+			// The mouse is read by the keyboard thread too.
+			row.lk.Lock()
+			mousectl.Mouse = m
+			row.lk.Unlock()
+			MovedMouse(m)
 		case <-cwarn:
 			// Do nothing
 		case pm := <-cplumb:
@@ -397,6 +551,18 @@ func MovedMouse(m draw.Mouse) {
 		t.Type(rune(but))
 		return
 	}
+	if w != nil && (m.Buttons&(32|64)) != 0 {
+		if m.Buttons&32 != 0 {
+			but = Kscrolloneleft
+		} else {
+			but = Kscrolloneright
+		}
+		w.Lock('M')
+		defer w.Unlock()
+		t.eq0 = ^0
+		t.Type(rune(but))
+		return
+	}
 	if m.Point.In(t.scrollr) {
 		if but != 0 {
 			switch t.what {
@@ -473,8 +639,12 @@ func keyboardthread(display draw.Display) {
 			}
 		case r := <-keyboardctl.C:
 			for {
-				typetext = row.Type(r, mouse.Point)
+				row.lk.Lock()
+				p := mouse.Point
+				row.lk.Unlock()
+				typetext = row.Type(r, p)
 				t = typetext
+				row.lk.Lock()
 				if t != nil && t.col != nil && !(r == draw.KeyDown || r == draw.KeyLeft || r == draw.KeyRight) { // scrolling doesn't change activecol
 					activecol = t.col
 				}
@@ -482,6 +652,7 @@ func keyboardthread(display draw.Display) {
 					// In a set of zeroxes, the last typed-in body becomes the curtext.
 					t.w.body.file.curtext = &t.w.body
 				}
+				row.lk.Unlock()
 				if timer != nil {
 					timer.Stop()
 				}
@@ -533,6 +704,9 @@ func waitthread(ctx context.Context) {
 			found := false
 			for _, c := range command {
 				if c.name == cmd+" " {
+					if c.dump != nil {
+						c.dump.killed = true
+					}
 					if err := c.proc.Kill(); err != nil {
 						warning(nil, "kill %v: %v\n", cmd, err)
 					}
@@ -566,7 +740,9 @@ func waitthread(ctx context.Context) {
 					t.Delete(t.q0, t.q1, true)
 					t.SetSelect(0, 0)
 				}
-				if !w.Success() {
+				if c.dump != nil {
+					c.dump.exited(w)
+				} else if !w.Success() {
 					warning(c.md, "%s: %s\n", c.name, w.String())
 				}
 				row.display.Flush()
@@ -577,7 +753,12 @@ func waitthread(ctx context.Context) {
 		case c := <-ccommand:
 			// has this command already exited?
 			if p, ok := exited[c.pid]; ok {
-				if msg := p.String(); msg != "" {
+				if c.dump != nil {
+					row.lk.Lock()
+					c.dump.exited(p)
+					row.display.Flush()
+					row.lk.Unlock()
+				} else if msg := p.String(); msg != "" {
 					warning(c.md, "%s\n", msg)
 				}
 				delete(exited, c.pid)
@@ -596,28 +777,57 @@ func waitthread(ctx context.Context) {
 	}
 }
 
+// An xfidAllocator hands out the Xfids kept by xfidallocthread.
+type xfidAllocator struct {
+	lk    sync.Mutex // serializes get, since requests and replies share alloc
+	alloc chan *Xfid
+	free  chan *Xfid
+}
+
+func newXfidAllocator() *xfidAllocator {
+	return &xfidAllocator{
+		alloc: make(chan *Xfid),
+		free:  make(chan *Xfid),
+	}
+}
+
+// get returns an Xfid from xfidallocthread. It is safe to call from
+// the fileServers of several connections at once.
+func (xa *xfidAllocator) get() *Xfid {
+	xa.lk.Lock()
+	defer xa.lk.Unlock()
+
+	xa.alloc <- nil
+	return <-xa.alloc
+}
+
+// put returns x to xfidallocthread.
+func (xa *xfidAllocator) put(x *Xfid) {
+	xa.free <- x
+}
+
 // maintain a linked list of Xfid
 // TODO(flux): It would be more idiomatic to prep one up front, and block on sending
 // it instead of using a send and a receive to get one.
 // Frankly, it would be more idiomatic to let the GC take care of them,
 // though that would require an exit signal in xfidctl.
-func xfidallocthread(ctx context.Context, d draw.Display) {
+func xfidallocthread(ctx context.Context, xa *xfidAllocator, d draw.Display) {
 	xfree := (*Xfid)(nil)
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-cxfidalloc:
+		case <-xa.alloc:
 			x := xfree
 			if x != nil {
 				xfree = x.next
 			} else {
 				x = &Xfid{}
 				x.c = make(chan func(*Xfid))
-				go xfidctl(x, d)
+				go xfidctl(x, d, xa.free)
 			}
-			cxfidalloc <- x
-		case x := <-cxfidfree:
+			xa.alloc <- x
+		case x := <-xa.free:
 			x.next = xfree
 			xfree = x
 		}
@@ -631,9 +841,13 @@ func newwindowthread() {
 	for {
 		// only fsysproc is talking to us, so synchronization is trivial
 		<-cnewwindow
+		// The row is locked, as it is for mousethread, since
+		// autodumpthread may be copying it.
+		row.lk.Lock()
 		w = makenewwindow(nil)
 		w.SetTag()
 		xfidlog(w, "new")
+		row.lk.Unlock()
 		cnewwindow <- w
 	}
 
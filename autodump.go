@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/rjkroege/edwood/internal/dumpfile"
+)
+
+// With -autodump, the state of Edwood is dumped every so often so
+// that -restore can bring it back after a crash. The file the latest
+// automatic dump is in is kept, along with older generations of it
+// named after it with .1, .2 and so on appended.
+
+// autodumpfile returns the file of the latest automatic dump, which is
+// kept with the sessions when in one.
+func autodumpfile() (string, error) {
+	if cursession != "" {
+		file, err := sessionfile(cursession)
+		if err != nil {
+			return "", err
+		}
+		return file[:len(file)-len(sessionsuffix)] + ".autodump", nil
+	}
+	if home == "" {
+		return "", fmt.Errorf("can't find home directory")
+	}
+	return filepath.Join(home, "edwood.autodump"), nil
+}
+
+// An autodumper writes automatic dumps, keeping n generations.
+type autodumper struct {
+	n        int
+	last     *dumpfile.Content // to not dump the same state again
+	lastfile string
+}
+
+// save writes dump to file, first moving the dumps already there to
+// the next generation. Nothing is written if dump is the same as the
+// last one saved.
+func (a *autodumper) save(dump *dumpfile.Content, file string) error {
+	if file == a.lastfile && reflect.DeepEqual(dump, a.last) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := dump.Save(tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	for i := a.n - 1; i > 0; i-- {
+		os.Rename(autodumpgen(file, i-1), autodumpgen(file, i))
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return err
+	}
+	a.last, a.lastfile = dump, file
+	return nil
+}
+
+// autodumpgen returns the file of generation i of the dump in file,
+// where generation 0 is the latest.
+func autodumpgen(file string, i int) string {
+	if i == 0 {
+		return file
+	}
+	return fmt.Sprintf("%s.%d", file, i)
+}
+
+// autodumpthread dumps the row every interval, keeping n generations.
+// The row, and in turn each window, is only locked while its state
+// is copied.
+func autodumpthread(interval time.Duration, n int) {
+	a := &autodumper{n: n}
+	warned := false
+	for range time.Tick(interval) {
+		row.lk.Lock()
+		dump, err := row.dumplocking(true)
+		file, ferr := autodumpfile()
+		row.lk.Unlock()
+		if err == nil {
+			err = ferr
+		}
+		if err == nil && len(dump.Columns) > 0 {
+			err = a.save(dump, file)
+		}
+		// Only warn once, not every interval.
+		if err != nil && !warned {
+			warning(nil, "automatic dump failed: %v\n", err)
+		}
+		warned = err != nil
+	}
+}
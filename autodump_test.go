@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rjkroege/edwood/internal/dumpfile"
+)
+
+func TestAutodumperSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "edwood.test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "dir", "edwood.autodump")
+	a := &autodumper{n: 3}
+	for _, dir := range []string{"/1", "/2", "/3", "/4", "/4"} {
+		if err := a.save(&dumpfile.Content{CurrentDir: dir}, file); err != nil {
+			t.Fatalf("save failed: %v", err)
+		}
+	}
+	// The second /4 isn't saved, so /3 is still the previous generation.
+	for i, want := range []string{"/4", "/3", "/2"} {
+		d, err := dumpfile.Load(autodumpgen(file, i))
+		if err != nil {
+			t.Fatalf("can't load generation %v: %v", i, err)
+		}
+		if d.CurrentDir != want {
+			t.Errorf("generation %v is of %v; want %v", i, d.CurrentDir, want)
+		}
+	}
+	for _, name := range []string{autodumpgen(file, 3), file + ".tmp"} {
+		if _, err := os.Stat(name); err == nil {
+			t.Errorf("%v exists", name)
+		}
+	}
+}
+
+func TestAutodumpFile(t *testing.T) {
+	_, restore := setSessionsForTesting(t)
+	defer restore()
+	for _, tc := range []struct {
+		session string
+		want    string
+	}{
+		{"", filepath.Join(home, "edwood.autodump")},
+		{"work", filepath.Join(home, "edwood.sessions", "work.autodump")},
+	} {
+		cursession = tc.session
+		file, err := autodumpfile()
+		if err != nil || file != tc.want {
+			t.Errorf("autodumpfile in session %q is %q, %v; want %q", tc.session, file, err, tc.want)
+		}
+	}
+}
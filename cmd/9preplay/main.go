@@ -0,0 +1,151 @@
+// Command 9preplay re-sends the 9P requests recorded in a trace
+// (see edwood's -fsys.trace flag) to a fresh Edwood and reports
+// every response that differs from the recorded one.
+//
+// Usage:
+//
+//	9preplay [-a address | -edwood prog] [-token file] trace
+//
+// The address is a network!address pair such as unix!/tmp/acme.sock
+// or tcp!localhost:5640. By default, the acme service in the current
+// name space is used. With -edwood, 9preplay instead runs prog
+// -headless in a temporary name space, replays the trace against it
+// and then kills it. Each connection in the trace is replayed on a
+// new connection to the file server.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"9fans.net/go/plan9/client"
+	"github.com/rjkroege/edwood/internal/ninep"
+)
+
+var (
+	addr      = flag.String("a", "", "Address of the file server as network!address (default: acme service in name space)")
+	tokenFile = flag.String("token", "", "File containing the secret to write to auth fids")
+	edwood    = flag.String("edwood", "", "Run `prog` -headless in a temporary name space and replay against it")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: 9preplay [-a address | -edwood prog] [-token file] trace\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("9preplay: ")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 || (*addr != "" && *edwood != "") {
+		usage()
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	trace, err := ninep.ReadTrace(f)
+	f.Close()
+	if err != nil {
+		log.Fatalf("%v: %v", flag.Arg(0), err)
+	}
+
+	network, address := "unix", filepath.Join(client.Namespace(), "acme")
+	if *addr != "" {
+		i := strings.Index(*addr, "!")
+		if i < 0 {
+			log.Fatalf("bad address %q", *addr)
+		}
+		network, address = (*addr)[:i], (*addr)[i+1:]
+	}
+	r := &ninep.Replayer{
+		Dial: func() (io.ReadWriteCloser, error) {
+			return net.Dial(network, address)
+		},
+	}
+	if *tokenFile != "" {
+		b, err := ioutil.ReadFile(*tokenFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		r.Secret = bytes.TrimSpace(b)
+	}
+	if *edwood != "" {
+		ns, stop, err := startEdwood(*edwood)
+		if err != nil {
+			log.Fatal(err)
+		}
+		address = filepath.Join(ns, "acme")
+		status := replay(r, trace)
+		stop()
+		os.Exit(status)
+	}
+	os.Exit(replay(r, trace))
+}
+
+// replay replays trace with r, printing the differences found, and
+// returns the exit status.
+func replay(r *ninep.Replayer, trace []*ninep.TraceEntry) int {
+	diffs, err := r.Replay(trace)
+	for _, d := range diffs {
+		fmt.Println(d)
+	}
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if len(diffs) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// startEdwood runs prog -headless with a new temporary name space and
+// waits for it to post its file server there. It returns the name
+// space and a function that kills prog and removes the name space.
+func startEdwood(prog string) (ns string, stop func(), err error) {
+	ns, err = ioutil.TempDir("", "9preplay.ns")
+	if err != nil {
+		return "", nil, err
+	}
+	cmd := exec.Command(prog, "-headless")
+	cmd.Env = append(os.Environ(), "NAMESPACE="+ns)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(ns)
+		return "", nil, err
+	}
+	stop = func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+		os.RemoveAll(ns)
+	}
+
+	// The file server is ready once its socket accepts connections.
+	for i := 0; ; i++ {
+		c, err := net.Dial("unix", filepath.Join(ns, "acme"))
+		if err == nil {
+			c.Close()
+			return ns, stop, nil
+		}
+		if i == 100 {
+			stop()
+			return "", nil, fmt.Errorf("%v didn't start: %v", prog, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,211 @@
+// Command edwood-dump inspects and edits the dump files written by
+// edwood's Dump command, without starting edwood.
+//
+// Usage:
+//
+//	edwood-dump convert file newfile
+//	edwood-dump print file
+//	edwood-dump ls file
+//	edwood-dump rewrite [-o newfile] old new file
+//	edwood-dump check file
+//
+// Convert writes the dump in file, which may be in the format of
+// acme, to newfile in the current format of edwood. Print shows all of
+// a dump and ls lists its windows, one to a line, with their types.
+// Rewrite replaces the directory or file old with new in the paths of
+// the dump, as after moving a repository, and saves it in the current
+// format to newfile or back to file. Check reports the problems that
+// would stop edwood loading the dump, exiting with status 1 if there
+// are any.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/rjkroege/edwood/internal/dumpfile"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `usage: edwood-dump convert file newfile
+       edwood-dump print file
+       edwood-dump ls file
+       edwood-dump rewrite [-o newfile] old new file
+       edwood-dump check file
+`)
+	os.Exit(2)
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("edwood-dump: ")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+	}
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+
+	switch cmd {
+	case "convert":
+		if len(args) != 2 {
+			usage()
+		}
+		save(load(args[0]), args[1])
+	case "print":
+		if len(args) != 1 {
+			usage()
+		}
+		printdump(load(args[0]))
+	case "ls":
+		if len(args) != 1 {
+			usage()
+		}
+		for _, w := range load(args[0]).Windows {
+			fmt.Println(describe(w))
+		}
+	case "rewrite":
+		fs := flag.NewFlagSet("rewrite", flag.ExitOnError)
+		fs.Usage = usage
+		out := fs.String("o", "", "Write the dump to the supplied file instead of back to the one read")
+		fs.Parse(args)
+		if fs.NArg() != 3 {
+			usage()
+		}
+		old, new, file := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+		c := load(file)
+		n := c.ReplacePath(old, new)
+		if *out == "" {
+			*out = file
+		}
+		save(c, *out)
+		fmt.Fprintf(os.Stderr, "replaced %d paths\n", n)
+	case "check":
+		if len(args) != 1 {
+			usage()
+		}
+		errs := load(args[0]).Validate()
+		for _, err := range errs {
+			fmt.Printf("%v: %v\n", args[0], err)
+		}
+		if len(errs) > 0 {
+			os.Exit(1)
+		}
+	default:
+		usage()
+	}
+}
+
+// load reads the dump in file, which may be in the legacy format of
+// acme, as edwood's -l flag does.
+func load(file string) *dumpfile.Content {
+	c, err := dumpfile.Load(file)
+	if err == nil {
+		return c
+	}
+	home, herr := os.UserHomeDir()
+	if herr != nil {
+		log.Fatalf("%v: %v", file, err)
+	}
+	c, lerr := dumpfile.LoadLegacy(file, home)
+	if lerr != nil {
+		log.Fatalf("%v: %v; nor in legacy format: %v", file, err, lerr)
+	}
+	return c
+}
+
+func save(c *dumpfile.Content, file string) {
+	if err := c.Save(file); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// describe returns a line describing window w: its type and file, or
+// the command run to make it.
+func describe(w *dumpfile.Window) string {
+	if w.Type == dumpfile.Exec {
+		return fmt.Sprintf("%v\t%v\t%v", w.Type, w.ExecDir, w.ExecCommand)
+	}
+	return fmt.Sprintf("%v\t%v", w.Type, w.Name())
+}
+
+func printdump(c *dumpfile.Content) {
+	fmt.Printf("directory %v\n", c.CurrentDir)
+	fmt.Printf("fonts %v %v\n", c.VarFont, c.FixedFont)
+	fmt.Printf("tag %v\n", text(c.RowTag))
+	for i, col := range c.Columns {
+		fmt.Printf("\ncolumn %d at %.1f%%", i, col.Position)
+		if col.Width > 0 {
+			fmt.Printf(", %d wide", col.Width)
+		}
+		fmt.Printf("\n\ttag %v\n", text(col.Tag))
+		for _, w := range c.Windows {
+			if w.Column == i && w.Type != dumpfile.Exec {
+				printwindow(w)
+			}
+		}
+	}
+	for _, w := range c.Windows {
+		if w.Type == dumpfile.Exec {
+			fmt.Printf("\n%v\n", describe(w))
+			if w.Restart != dumpfile.RestartOnLoad {
+				fmt.Printf("\trestart %v\n", w.Restart)
+			}
+			for _, kv := range w.ExecEnv {
+				fmt.Printf("\tenv %v\n", kv)
+			}
+		} else if w.Column < 0 || w.Column >= len(c.Columns) {
+			fmt.Printf("\nin no column:\n")
+			printwindow(w)
+		}
+	}
+}
+
+func printwindow(w *dumpfile.Window) {
+	fmt.Printf("\n\t%v at %.1f%%\n", describe(w), w.Position)
+	fmt.Printf("\t\ttag %v\n", text(w.Tag))
+	fmt.Printf("\t\tbody selection %d,%d, origin %d", w.Body.Q0, w.Body.Q1, w.Origin)
+	if w.Type == dumpfile.Unsaved {
+		fmt.Printf(", %d runes", len([]rune(w.Body.Buffer)))
+	}
+	fmt.Println()
+
+	var settings []string
+	if w.Font != "" {
+		settings = append(settings, "font "+w.Font)
+	}
+	if w.Gutter != "" {
+		settings = append(settings, "gutter "+w.Gutter)
+	}
+	if w.TabWidth > 0 {
+		settings = append(settings, fmt.Sprintf("tab %d", w.TabWidth))
+	}
+	for _, s := range []struct {
+		on   bool
+		name string
+	}{
+		{w.NoWrap, "nowrap"},
+		{w.TagExpand, "tagexpand"},
+		{w.AutoIndent, "autoindent"},
+		{w.FileMenu, "menu"},
+		{w.Scratch, "scratch"},
+	} {
+		if s.on {
+			settings = append(settings, s.name)
+		}
+	}
+	if len(settings) > 0 {
+		fmt.Printf("\t\t%v\n", strings.Join(settings, ", "))
+	}
+}
+
+// text returns t quoted, with its selection if it has one.
+func text(t dumpfile.Text) string {
+	if t.Q0 == t.Q1 {
+		return fmt.Sprintf("%q", t.Buffer)
+	}
+	return fmt.Sprintf("%q selecting %d,%d", t.Buffer, t.Q0, t.Q1)
+}
@@ -21,6 +21,9 @@ type Column struct {
 	w       []*Window // These are sorted from top to bottom (increasing Y)
 	safe    bool
 	fortest bool // True if running in test mode (to elide hard to mock actions.)
+
+	zoomed   *Window         // window made as tall as it can be by Zoom
+	unzoomed map[*Window]int // layout from before Zoom, as weights for layout
 }
 
 // nw returns the number of Window pointers in Column c.
@@ -121,7 +124,7 @@ func (c *Column) Add(w, clone *Window, y int) *Window {
 		// will be split to accommodate the newly added window.)
 		// minht is the height of the first line of the tag and the border thickness
 		// TODO(rjk): Make minht a method of the tag to simplify variable height fonts.
-		minht := v.tag.fr.DefaultFontHeight() + c.display.ScaleSize(Border) + 1
+		minht := v.tag.fr.DefaultFontHeight() + c.display.ScaleSize(Border) + sepwidth(c.display)
 		j := 0
 		// Code inspection suggests that the frame fill status may have altered
 		// after resizing.
@@ -161,7 +164,7 @@ func (c *Column) Add(w, clone *Window, y int) *Window {
 			c.display.ScreenImage().Draw(r, textcolors[frame.ColBack], nil, image.Point{})
 		}
 		r1 := r
-		y = min(y, ymax-(v.tag.fr.DefaultFontHeight()*v.taglines+v.body.fr.DefaultFontHeight()+c.display.ScaleSize(Border)+1))
+		y = min(y, ymax-(v.tag.fr.DefaultFontHeight()*v.taglines+v.body.fr.DefaultFontHeight()+c.display.ScaleSize(Border)+sepwidth(c.display)))
 		ffs := v.body.fr.GetFrameFillStatus()
 		r1.Max.Y = min(y, v.body.fr.Rect().Min.Y+ffs.Nlines*v.body.fr.DefaultFontHeight())
 		r1.Min.Y = v.Resize(r1, false, false)
@@ -221,6 +224,9 @@ func (c *Column) Close(w *Window, dofree bool) {
 	}
 	acmeerror("can't find window", nil)
 Found:
+	if c.zoomed == w {
+		c.zoomed, c.unzoomed = nil, nil
+	}
 	r = w.r
 	w.tag.col = nil
 	w.body.col = nil
@@ -465,8 +471,8 @@ Pack:
 	r.Min.Y = y1
 	r.Max.Y = y2
 	h := w.body.fr.DefaultFontHeight() // TODO(flux) Is this the right frame font height to use?
-	if r.Dy() < w.tagtop.Dy()+1+h+c.display.ScaleSize(Border) {
-		r.Max.Y = r.Min.Y + w.tagtop.Dy() + 1 + h + c.display.ScaleSize(Border)
+	if r.Dy() < w.tagtop.Dy()+sepwidth(c.display)+h+c.display.ScaleSize(Border) {
+		r.Max.Y = r.Min.Y + w.tagtop.Dy() + sepwidth(c.display) + h + c.display.ScaleSize(Border)
 	}
 	// draw window
 	r.Max.Y = w.Resize(r, false, true)
@@ -513,12 +519,12 @@ func (c *Column) DragWin(w *Window, but int) {
 	b = mouse.Buttons
 	op = mouse.Point
 	for mouse.Buttons == b {
-		mousectl.Read()
+		readmouse(c.display)
 	}
 	c.display.SetCursor(nil)
 	if mouse.Buttons != 0 {
 		for mouse.Buttons != 0 {
-			mousectl.Read()
+			readmouse(c.display)
 		}
 		return
 	}
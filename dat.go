@@ -1,8 +1,10 @@
 package main
 
 import (
+	"io"
 	"math"
 	"os"
+	"sync/atomic"
 	"unicode/utf8"
 
 	"9fans.net/go/plan9"
@@ -19,6 +21,7 @@ const (
 	Qacme
 	Qcons
 	Qconsctl
+	Qctl
 	Qdraw
 	Qeditout
 	Qindex
@@ -36,6 +39,7 @@ const (
 	QWwrsel
 	QWtag
 	QWxdata
+	QWspans
 	QMAX
 )
 
@@ -66,9 +70,11 @@ const (
 	Scrollwid    = 12
 	Scrollgap    = 8
 
-	KF             = 0xF000 // Start of private unicode space
-	Kscrolloneup   = KF | 0x20
-	Kscrollonedown = KF | 0x21
+	KF              = 0xF000 // Start of private unicode space
+	Kscrolloneup    = KF | 0x20
+	Kscrollonedown  = KF | 0x21
+	Kscrolloneleft  = KF | 0x22
+	Kscrolloneright = KF | 0x23
 )
 
 var (
@@ -87,6 +93,11 @@ var (
 	but2col   draw.Image
 	but3col   draw.Image
 
+	modbuttoncol   draw.Image
+	colbuttoncol   draw.Image
+	scrollbarcol   draw.Image
+	scrollthumbcol draw.Image
+
 	//	boxcursor Cursor
 	row Row
 
@@ -110,8 +121,6 @@ var (
 	cwait      chan ProcessState
 	ccommand   chan *Command
 	ckill      chan string
-	cxfidalloc chan *Xfid
-	cxfidfree  chan *Xfid
 	cnewwindow chan *Window
 	cexit      chan struct{}
 	csignal    chan os.Signal
@@ -119,6 +128,8 @@ var (
 	cedit      chan int
 	cwarn      chan uint
 
+	xfids *xfidAllocator // of the main program
+
 	editoutlk = make(chan bool, 1)
 
 	WinID = 0
@@ -142,6 +153,28 @@ type Command struct {
 	av            []string
 	iseditcommand bool
 	md            *MntDir
+	dump          *dumpexec // for the Exec window of a dump it was run for
+}
+
+// environ returns the environment of the process of c: nil for that of
+// Edwood, unless it is run for a dump with variables of its own.
+func (c *Command) environ() []string {
+	if c.dump == nil || len(c.dump.dw.ExecEnv) == 0 {
+		return nil
+	}
+	return append(os.Environ(), c.dump.dw.ExecEnv...)
+}
+
+// stderr returns where the standard error of the process of c goes: w,
+// and also the end kept to report its failure if run for a dump.
+func (c *Command) stderr(w io.Writer) io.Writer {
+	switch {
+	case c.dump == nil:
+		return w
+	case w == nil:
+		return c.dump
+	}
+	return io.MultiWriter(w, c.dump)
 }
 
 // DirTab describes a file or directory in file server.
@@ -179,6 +212,14 @@ type Fid struct {
 	nrpart int
 	rpart  [utf8.UTFMax]byte
 	logoff int
+
+	// Contents of a generated file (e.g. index) as of Topen.
+	// See genfiles.
+	snapshot []byte
+
+	// Used by an auth fid (see fileServer.auth).
+	authdata []byte // secret written so far
+	authok   bool   // authdata matches the file server's token
 }
 
 type Xfid struct {
@@ -208,15 +249,16 @@ type Expand struct {
 	a1    int            // end of address
 }
 
-type Ref int
+// Ref is a reference count. It is updated atomically, since the
+// fileServers of several connections may walk to the same window.
+type Ref int32
 
 func (r *Ref) Inc() {
-	*r++
+	atomic.AddInt32((*int32)(r), 1)
 }
 
 func (r *Ref) Dec() int {
-	*r--
-	return int(*r)
+	return int(atomic.AddInt32((*int32)(r), -1))
 }
 
 // WIN returns the window ID contained in a Qid.
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rjkroege/edwood/internal/dumpfile"
+)
+
+// A dump keeps the command that made each Exec window, a window
+// controlled by an outside program such as win, and Load runs it again
+// in the directory and environment recorded with it. The program sets
+// these with the dump, dumpdir, dumpenv and restart ctl messages. As
+// the window the program makes is gone if it fails, the failure is
+// reported in a window put where the dumped one was.
+
+// dumpenvvars are the variables of Edwood's environment recorded with
+// the command of a window, so that it runs as it did even if Edwood is
+// started differently when the dump is loaded.
+var dumpenvvars = []string{"PATH", "SHELL", "PLAN9", "NAMESPACE", "TERM", "LANG"}
+
+// restartmin is how long a command restarted whenever it exits must
+// run for it to be restarted: one that exits sooner would likely just
+// fail again.
+const restartmin = 10 * time.Second
+
+// maxerrtail is how much of the end of the standard error of a command
+// is kept to report with its failure.
+const maxerrtail = 1024
+
+// lookenv returns the index in env of the variable name, or -1.
+func lookenv(env []string, name string) int {
+	for i, kv := range env {
+		if strings.HasPrefix(kv, name) && len(kv) > len(name) && kv[len(name)] == '=' {
+			return i
+		}
+	}
+	return -1
+}
+
+// setenv returns env with the variable of kv, given as NAME=value, set.
+func setenv(env []string, kv string) []string {
+	if i := lookenv(env, kv[:strings.IndexByte(kv, '=')]); i >= 0 {
+		env[i] = kv
+		return env
+	}
+	return append(env, kv)
+}
+
+// dumpenviron returns env, the environment set for the command of a
+// window, with the variables of dumpenvvars it doesn't set added where
+// Edwood's environment gives them a value.
+func dumpenviron(env []string) []string {
+	for _, name := range dumpenvvars {
+		if v := os.Getenv(name); v != "" && lookenv(env, name) < 0 {
+			env = append(env, name+"="+v)
+		}
+	}
+	return env
+}
+
+// parseRestart returns the restart policy named s in a restart ctl
+// message.
+func parseRestart(s string) (dumpfile.Restart, error) {
+	switch s {
+	case "onload":
+		return dumpfile.RestartOnLoad, nil
+	case string(dumpfile.RestartNever), string(dumpfile.RestartAlways):
+		return dumpfile.Restart(s), nil
+	}
+	return "", ErrBadCtl
+}
+
+// A dumpexec is a run of the command of an Exec window of a dump.
+type dumpexec struct {
+	dw      *dumpfile.Window
+	started time.Time
+	killed  bool // by Kill, so not restarted
+
+	mu      sync.Mutex
+	errtail []byte // end of the standard error of the command
+}
+
+// rundump runs the command of dw, an Exec window of a dump, in its
+// directory and environment.
+func rundump(dw *dumpfile.Window) {
+	dir := dw.ExecDir
+	if dir == "" {
+		dir = home
+	}
+	e := &dumpexec{dw: dw, started: time.Now()}
+	c := &Command{dump: e}
+	cpid := make(chan *os.Process)
+	go func() {
+		err := runproc(nil, dw.ExecCommand, dir, true, "", "", c, cpid, false)
+		if err != nil && err != errEmptyCmd {
+			row.lk.Lock()
+			e.report(fmt.Sprintf("%s: %v\n", dw.ExecCommand, err))
+			row.display.Flush()
+			row.lk.Unlock()
+		}
+	}()
+	go runwaittask(c, cpid)
+}
+
+// Write keeps the end of the standard error of the command.
+func (e *dumpexec) Write(b []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.errtail = append(e.errtail, b...)
+	if n := len(e.errtail); n > maxerrtail {
+		e.errtail = append(e.errtail[:0], e.errtail[n-maxerrtail:]...)
+	}
+	return len(b), nil
+}
+
+// exited reports the exit of the command with state if it failed, and
+// runs it again if it is to be restarted whenever it exits. The row
+// must be locked.
+func (e *dumpexec) exited(state ProcessState) {
+	cmd := e.dw.ExecCommand
+	if !state.Success() {
+		e.mu.Lock()
+		msg := string(e.errtail) + fmt.Sprintf("%s: %s\n", cmd, state.String())
+		e.mu.Unlock()
+		e.report(msg)
+	}
+	if e.dw.Restart != dumpfile.RestartAlways || e.killed {
+		return
+	}
+	if time.Since(e.started) < restartmin {
+		e.report(fmt.Sprintf("%s: exited within %v of starting; not restarted\n", cmd, restartmin))
+		return
+	}
+	rundump(e.dw)
+}
+
+// report appends msg to the window the command made, or if it is gone,
+// to a new one of the same name in the place of the dumped window. The
+// row must be locked.
+func (e *dumpexec) report(msg string) {
+	name := e.dw.Name()
+	if name == "" {
+		warning(nil, "%s", msg)
+		return
+	}
+	w := lookfile(name)
+	if w == nil {
+		if w = e.newwindow(name); w == nil {
+			warning(nil, "%s", msg)
+			return
+		}
+	}
+	w.Lock('E')
+	t := &w.body
+	w.Commit(t)
+	q0 := t.Nc()
+	t.BsInsert(q0, []rune(msg), true)
+	t.Show(q0, t.Nc(), true)
+	w.SetTag()
+	t.ScrDraw(t.fr.GetFrameFillStatus().Nchars)
+	t.file.TreatAsClean()
+	w.Unlock()
+}
+
+// newwindow makes a window named name where the dumped window was.
+func (e *dumpexec) newwindow(name string) *Window {
+	if len(row.col) == 0 && row.Add(nil, -1) == nil {
+		return nil
+	}
+	i := e.dw.Column
+	if i < 0 || i >= len(row.col) {
+		i = len(row.col) - 1
+	}
+	c := row.col[i]
+	y := c.r.Min.Y + int((e.dw.Position*float64(c.r.Dy()))/100.+0.5)
+	if y < c.r.Min.Y || y >= c.r.Max.Y {
+		y = -1
+	}
+	w := c.Add(nil, nil, y)
+	if w == nil {
+		return nil
+	}
+	w.SetName(name)
+	w.filemenu = false
+	w.body.file.isscratch = true
+	xfidlog(w, "new")
+	return w
+}
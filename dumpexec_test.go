@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rjkroege/edwood/internal/dumpfile"
+)
+
+func TestSetenv(t *testing.T) {
+	env := []string{"A=1", "AB=2"}
+	env = setenv(env, "A=3")
+	env = setenv(env, "B=4")
+	if want := []string{"A=3", "AB=2", "B=4"}; !reflect.DeepEqual(env, want) {
+		t.Errorf("environment is %q; want %q", env, want)
+	}
+}
+
+func TestDumpenviron(t *testing.T) {
+	for _, name := range dumpenvvars {
+		if v, ok := os.LookupEnv(name); ok {
+			defer os.Setenv(name, v)
+		} else {
+			defer os.Unsetenv(name)
+		}
+		os.Setenv(name, "")
+	}
+	os.Setenv("PATH", "/bin")
+	os.Setenv("SHELL", "/bin/sh")
+	env := dumpenviron([]string{"SHELL=/bin/rc", "winid=1"})
+	want := []string{"SHELL=/bin/rc", "winid=1", "PATH=/bin"}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("environment is %q; want %q", env, want)
+	}
+}
+
+func TestDumpExecWindow(t *testing.T) {
+	setGlobalsForLoadTesting()
+	w := row.Add(nil, -1).Add(nil, nil, -1)
+	w.SetName("/home/gopher/-win")
+	w.dumpstr = "win"
+	w.dumpdir = "/home/gopher"
+	w.dumpenv = []string{"PATH=/bin"}
+	w.restart = dumpfile.RestartAlways
+
+	dump, err := row.dump()
+	if err != nil {
+		t.Fatalf("dump failed: %v", err)
+	}
+	dw := dump.Windows[0]
+	if dw.Type != dumpfile.Exec || dw.ExecCommand != "win" || dw.ExecDir != "/home/gopher" ||
+		!reflect.DeepEqual(dw.ExecEnv, w.dumpenv) || dw.Restart != dumpfile.RestartAlways {
+		t.Errorf("dumped window is %#v", dw)
+	}
+}
+
+func TestDumpExecExited(t *testing.T) {
+	dw := &dumpfile.Window{
+		Type:        dumpfile.Exec,
+		Position:    50,
+		Tag:         dumpfile.Text{Buffer: "/home/gopher/-win Del Snarf | Look"},
+		ExecCommand: "win",
+		Restart:     dumpfile.RestartAlways,
+	}
+	for _, tc := range []struct {
+		name    string
+		success bool
+		killed  bool
+		want    string
+	}{
+		{"failed", false, false, "win: no rc\nwin: pid 1, success false\nwin: exited within 10s of starting; not restarted\n"},
+		{"killed", false, true, "win: no rc\nwin: pid 1, success false\n"},
+		{"succeeded", true, true, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			setGlobalsForLoadTesting()
+			row.Add(nil, -1)
+			e := &dumpexec{dw: dw, started: time.Now(), killed: tc.killed}
+			e.Write([]byte("win: no rc\n"))
+			e.exited(&mockProcessState{pid: 1, success: tc.success})
+
+			w := lookfile("/home/gopher/-win")
+			if tc.want == "" {
+				if w != nil {
+					t.Fatalf("reported %q", w.body.file.b)
+				}
+				return
+			}
+			if w == nil {
+				t.Fatalf("no window for the failure")
+			}
+			if got := string(w.body.file.b); got != tc.want {
+				t.Errorf("reported %q; want %q", got, tc.want)
+			}
+			if !w.body.file.isscratch || w.body.file.Dirty() {
+				t.Errorf("window of failure is not clean scratch")
+			}
+		})
+	}
+}
@@ -670,6 +670,7 @@ func (mf *MockFrame) SelectOpt(*draw.Mousectl, *draw.Mouse, func(frame.SelectScr
 	return 0, 0
 }
 func (mf *MockFrame) DrawSel(image.Point, int, int, bool) {}
+func (mf *MockFrame) SetStyle(int, int, *frame.Style)     {}
 
 func mockrun(win *Window, s string, rdir string, newns bool, argaddr string, xarg string, iseditcmd bool) {
 	// Optionally generate an error.
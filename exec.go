@@ -29,15 +29,18 @@ type Exectab struct {
 
 var exectab = []Exectab{
 	//	{ "Abort",		doabort,	false,	true /*unused*/,		true /*unused*/,		},
+	{"Balance", balancex, false, true /*unused*/, true /*unused*/},
 	{"Cut", cut, true, true, true},
 	{"Del", del, false, false, true /*unused*/},
 	{"Delcol", delcol, false, true /*unused*/, true /*unused*/},
 	{"Delete", del, false, true, true /*unused*/},
 	{"Dump", dump, false, true, true /*unused*/},
 	{"Edit", edit, false, true /*unused*/, true /*unused*/},
+	{"Equalise", equalisex, false, true /*unused*/, true /*unused*/},
 	{"Exit", xexit, false, true /*unused*/, true /*unused*/},
 	{"Font", fontx, false, true /*unused*/, true /*unused*/},
 	{"Get", get, false, true, true /*unused*/},
+	{"Gutter", gutterx, false, true /*unused*/, true /*unused*/},
 	{"ID", id, false, true /*unused*/, true /*unused*/},
 	//	{ "Incl",		incl,		false,	true /*unused*/,		true /*unused*/		},
 	{"Indent", indent, false, true /*unused*/, true /*unused*/},
@@ -45,18 +48,27 @@ var exectab = []Exectab{
 	{"Load", dump, false, false, true /*unused*/},
 	{"Local", local, false, true /*unused*/, true /*unused*/},
 	{"Look", look, false, true /*unused*/, true /*unused*/},
+	{"Match", matchx, false, true /*unused*/, true /*unused*/},
 	{"New", newx, false, true /*unused*/, true /*unused*/},
 	{"Newcol", newcol, false, true /*unused*/, true /*unused*/},
 	{"Paste", paste, true, true, true /*unused*/},
 	{"Put", put, false, true /*unused*/, true /*unused*/},
 	{"Putall", putall, false, true /*unused*/, true /*unused*/},
 	{"Redo", undo, false, false, true /*unused*/},
+	{"Scale", scalex, false, true /*unused*/, true /*unused*/},
+	{"Screenshot", screenshotx, false, true /*unused*/, true /*unused*/},
 	{"Send", sendx, true, true /*unused*/, true /*unused*/},
+	{"Session", sessionx, false, true /*unused*/, true /*unused*/},
+	{"Sessions", sessionsx, false, true /*unused*/, true /*unused*/},
 	{"Snarf", cut, false, true, false},
 	{"Sort", sortx, false, true /*unused*/, true /*unused*/},
 	{"Tab", tab, false, true /*unused*/, true /*unused*/},
+	{"Theme", themex, false, true /*unused*/, true /*unused*/},
 	{"Undo", undo, false, true, true /*unused*/},
+	{"Whitespace", whitespacex, false, true /*unused*/, true /*unused*/},
+	{"Wrap", wrapx, false, true /*unused*/, true /*unused*/},
 	{"Zerox", zeroxx, false, true /*unused*/, true /*unused*/},
+	{"Zoom", zoomx, false, true /*unused*/, true /*unused*/},
 }
 
 var wsre = regexp.MustCompile("[ \t\n]+")
@@ -242,6 +254,7 @@ func edit(et *Text, _ *Text, argt *Text, _, _ bool, arg string) {
 
 func xexit(*Text, *Text, *Text, bool, bool, string) {
 	if row.Clean() {
+		row.SaveSession()
 		close(cexit)
 		//	threadexits(nil);
 	}
@@ -565,6 +578,9 @@ func put(et *Text, _0 *Text, argt *Text, _1 bool, _2 bool, arg string) {
 		warning(nil, "no file name\n")
 		return
 	}
+	if w.strip {
+		w.StripTrailing()
+	}
 	putfile(f, 0, f.Size(), name)
 	xfidlog(w, "put")
 }
@@ -853,9 +869,10 @@ func runproc(win *Window, s string, dir string, newns bool, argaddr string, arg
 		rcarg = []string{shell, "-c", t}
 		cmd := exec.Command(rcarg[0], rcarg[1:]...)
 		cmd.Dir = dir
+		cmd.Env = c.environ()
 		cmd.Stdin = sin
 		cmd.Stdout = sout
-		cmd.Stderr = serr
+		cmd.Stderr = c.stderr(serr)
 		err := cmd.Start()
 		if err != nil {
 			Fail()
@@ -976,9 +993,10 @@ func runproc(win *Window, s string, dir string, newns bool, argaddr string, arg
 	}
 	cmd := exec.Command(c.av[0], c.av[1:]...)
 	cmd.Dir = dir
+	cmd.Env = c.environ()
 	cmd.Stdin = sin
 	cmd.Stdout = sout
-	cmd.Stderr = serr
+	cmd.Stderr = c.stderr(serr)
 	err := cmd.Start()
 	if err != nil {
 		Fail()
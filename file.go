@@ -70,6 +70,12 @@ type File struct {
 
 	// cq0 tracks the insertion point for the cache.
 	cq0 int // [private]
+
+	// highlighter computes the syntax highlighting of the File. Spans
+	// supplied through the file server are held in extspans and replace
+	// those of highlighter while present.
+	highlighter Highlighter
+	extspans    *spanList
 }
 
 // Remember that the high-level goal is to slowly coerce this into looking like
@@ -334,6 +340,7 @@ func (f *File) InsertAt(p0 int, s []rune) {
 	if len(s) != 0 {
 		f.Modded()
 	}
+	f.highlightinserted(p0, len(s))
 	for _, text := range f.text {
 		text.inserted(p0, s)
 	}
@@ -362,6 +369,7 @@ func (f *File) InsertAtWithoutCommit(p0 int, s []rune) {
 	f.cache = append(f.cache, s...)
 
 	// run the observers
+	f.highlightinserted(p0, len(s))
 	for _, text := range f.text {
 		text.inserted(p0, s)
 	}
@@ -404,6 +412,7 @@ func (f *File) DeleteAt(p0, p1 int) {
 	if p1 > p0 {
 		f.Modded()
 	}
+	f.highlightdeleted(p0, p1)
 	for _, text := range f.text {
 		text.deleted(p0, p1)
 	}
@@ -578,6 +587,7 @@ func (f *File) Undo(isundo bool) (q0, q1 int, ok bool) {
 			f.mod = u.mod
 			f.treatasclean = false
 			f.b.Delete(u.p0, u.p0+u.n)
+			f.highlightdeleted(u.p0, u.p0+u.n)
 			for _, text := range f.text {
 				text.deleted(u.p0, u.p0+u.n)
 			}
@@ -590,6 +600,7 @@ func (f *File) Undo(isundo bool) (q0, q1 int, ok bool) {
 			f.mod = u.mod
 			f.treatasclean = false
 			f.b.Insert(u.p0, u.buf)
+			f.highlightinserted(u.p0, u.n)
 			for _, text := range f.text {
 				text.inserted(u.p0, u.buf)
 			}
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"crypto/subtle"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"os"
 	"os/user"
 	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"9fans.net/go/plan9"
@@ -19,11 +23,33 @@ type fileServer struct {
 	conn        io.ReadWriteCloser
 	fids        map[uint32]*Fid
 	fcall       []fsfunc
-	closing     bool
+	closing     int32 // set atomically by close
 	username    string
 	messagesize int
+
+	// Remote is true if the connection was accepted by one of the
+	// additional listeners (see fsyslisten). An I/O error on a remote
+	// connection only ends that connection.
+	remote bool
+
+	// Authtoken, if not nil, is the shared secret that clients must
+	// write to an auth fid (see fileServer.auth) before attaching.
+	authtoken []byte
+
+	// Additional listeners serving their own fileServer per connection.
+	listeners []net.Listener
+
+	// Trace, if not nil, records the messages of the connection.
+	trace *ninep.TraceConn
+
+	// Xfids hands out the Xfids that carry requests to the windows.
+	xfids *xfidAllocator
 }
 
+// fsystrace, if not nil, records the 9P messages of every connection
+// to the file system. It is set up by fsysinit if requested with -fsys.trace.
+var fsystrace *ninep.Trace
+
 const DEBUG = false
 
 type fsfunc func(*Xfid, *Fid) *Xfid
@@ -57,6 +83,7 @@ var dirtab = []*DirTab{
 	{"acme", plan9.QTDIR, Qacme, 0500 | plan9.DMDIR},
 	{"cons", plan9.QTFILE, Qcons, 0600},
 	{"consctl", plan9.QTFILE, Qconsctl, 0000},
+	{"ctl", plan9.QTFILE, Qctl, 0200},
 	{"draw", plan9.QTDIR, Qdraw, 0000 | plan9.DMDIR}, // to suppress graphics progs started in acme
 	{"editout", plan9.QTFILE, Qeditout, 0200},
 	{"index", plan9.QTFILE, Qindex, 0400},
@@ -78,6 +105,7 @@ var dirtabw = []*DirTab{
 	{"wrsel", plan9.QTFILE, QWwrsel, 0200},
 	{"tag", plan9.QTAPPEND, QWtag, 0600 | plan9.DMAPPEND},
 	{"xdata", plan9.QTFILE, QWxdata, 0600},
+	{"spans", plan9.QTFILE, QWspans, 0600},
 }
 
 // windowDirTab returns the DirTab entry for window directory for the window with given id.
@@ -110,26 +138,125 @@ func fsysinit() *fileServer {
 		acmeerror("can't post service", err)
 	}
 
+	if *fsysTraceFlag != "" {
+		f, err := os.Create(*fsysTraceFlag)
+		if err != nil {
+			acmeerror("can't create 9P trace file", err)
+		}
+		fsystrace = ninep.NewTrace(f)
+	}
+
 	fs := &fileServer{
 		conn:        p1,
 		fids:        make(map[uint32]*Fid),
 		fcall:       nil, // initialized by initfcall
-		closing:     false,
+		closing:     0,
 		username:    getuser(),
 		messagesize: 0, // we'll know after Tversion
+		xfids:       xfids,
 	}
 	fs.initfcall()
+	if fsystrace != nil {
+		fs.trace = fsystrace.Conn()
+	}
 	go fs.fsysproc()
+
+	fs.listeners, err = fsyslisten(fs.xfids)
+	if err != nil {
+		acmeerror("can't listen for 9P clients", err)
+	}
 	return fs
 }
 
+// serve9p serves the file system to a client of one of the additional
+// listeners with its own fid table, until the connection is closed.
+// If token is not nil, the client must authenticate with it.
+// Requests are carried by Xfids from xa.
+func serve9p(conn net.Conn, token []byte, xa *xfidAllocator) {
+	fs := &fileServer{
+		conn:      conn,
+		fids:      make(map[uint32]*Fid),
+		username:  getuser(),
+		remote:    true,
+		authtoken: token,
+		xfids:     xa,
+	}
+	fs.initfcall()
+	if fsystrace != nil {
+		fs.trace = fsystrace.Conn()
+	}
+	fs.fsysproc()
+	conn.Close()
+	fs.clunkall()
+}
+
+// clunkall releases the fids still in use after the connection is gone.
+func (fs *fileServer) clunkall() {
+	for _, f := range fs.fids {
+		if f.busy && f.open && FILE(f.qid) == QWevent {
+			fs.flushevents()
+			break
+		}
+	}
+	for _, f := range fs.fids {
+		if !f.busy {
+			continue
+		}
+		x := fs.xfids.get()
+		x.fcall = plan9.Fcall{Type: plan9.Tclunk, Fid: f.fid}
+		x.fs = hungup{fs}
+		x.f = f
+		if x = fs.clunk(x, f); x != nil {
+			fs.xfids.put(x)
+		}
+	}
+}
+
+// Hungup is the responder of the requests made by clunkall: there
+// is nobody left to respond to.
+type hungup struct {
+	fs *fileServer
+}
+
+func (h hungup) respond(x *Xfid, t *plan9.Fcall, err error) *Xfid {
+	return x
+}
+
+func (h hungup) msize() int {
+	return h.fs.msize()
+}
+
+// flushevents abandons the reads of event files by the client of fs
+// that are still waiting for an event, as if they were flushed.
+func (fs *fileServer) flushevents() {
+	row.lk.Lock()
+	defer row.lk.Unlock()
+	for _, c := range row.col {
+		for _, w := range c.w {
+			w.Lock('E')
+			if wx := w.eventx; wx != nil && wx.fs == fs {
+				w.eventx = nil
+				wx.flushed = true
+				wx.c <- nil
+			}
+			w.Unlock()
+		}
+	}
+}
+
 func (fs *fileServer) fsysproc() {
 	x := (*Xfid)(nil)
 	var f *Fid
 	for {
 		fc, err := plan9.ReadFcall(fs.conn)
 		if err != nil || fc == nil {
-			if fs.closing {
+			if fs.isclosing() {
+				break
+			}
+			if fs.remote {
+				if err != io.EOF {
+					log.Printf("9P client %v: %v", fs.conn.(net.Conn).RemoteAddr(), err)
+				}
 				break
 			}
 			acmeerror("fsysproc", err)
@@ -137,9 +264,11 @@ func (fs *fileServer) fsysproc() {
 		if DEBUG {
 			fmt.Fprintf(os.Stderr, "<-- %v\n", fc)
 		}
+		if fs.trace != nil {
+			fs.tracerequest(fc)
+		}
 		if x == nil {
-			cxfidalloc <- nil
-			x = <-cxfidalloc
+			x = fs.xfids.get()
 		}
 		x.fcall = *fc
 		x.fs = fs
@@ -159,10 +288,22 @@ func (fs *fileServer) fsysproc() {
 				x = fs.respond(x, fc, fmt.Errorf("fid not in use"))
 				continue
 			}
+			if f.qid.Type&plan9.QTAUTH != 0 {
+				switch x.fcall.Type {
+				case plan9.Tread, plan9.Twrite, plan9.Tclunk:
+				default:
+					x.f = f
+					x = fs.respond(x, fc, fmt.Errorf("not allowed on auth fid"))
+					continue
+				}
+			}
 		}
 		x.f = f
 		x = fs.fcall[x.fcall.Type](x, f)
 	}
+	if x != nil && fs.remote {
+		fs.xfids.put(x)
+	}
 }
 
 // Add creates a new MntDir and returns a new reference to it.
@@ -223,11 +364,19 @@ func (mnt *Mnt) GetFromID(id uint64) *MntDir {
 
 func (fs *fileServer) close() {
 	if fs != nil {
-		fs.closing = true
+		atomic.StoreInt32(&fs.closing, 1)
 		fs.conn.Close()
+		for _, l := range fs.listeners {
+			l.Close()
+		}
 	}
 }
 
+// isclosing reports whether fs is being shut down by close.
+func (fs *fileServer) isclosing() bool {
+	return atomic.LoadInt32(&fs.closing) != 0
+}
+
 func (fs *fileServer) respond(x *Xfid, t *plan9.Fcall, err error) *Xfid {
 	if t == nil {
 		t = &plan9.Fcall{}
@@ -240,7 +389,20 @@ func (fs *fileServer) respond(x *Xfid, t *plan9.Fcall, err error) *Xfid {
 	}
 	t.Fid = x.fcall.Fid
 	t.Tag = x.fcall.Tag
+	if fs.trace != nil {
+		// Before sending, so that it is in the trace before any
+		// request the client sends in reaction to it.
+		fs.trace.Response(t)
+	}
 	if err := plan9.WriteFcall(fs.conn, t); err != nil {
+		if fs.remote {
+			// fsysproc will notice the broken connection.
+			return x
+		}
+		if fs.isclosing() {
+			// Edwood is exiting, perhaps at this request.
+			return x
+		}
 		acmeerror("write error in respond", err)
 	}
 	if DEBUG {
@@ -249,12 +411,27 @@ func (fs *fileServer) respond(x *Xfid, t *plan9.Fcall, err error) *Xfid {
 	return x
 }
 
+// tracerequest records request fc in the trace, without the secret
+// written to an auth fid.
+func (fs *fileServer) tracerequest(fc *plan9.Fcall) {
+	if f, ok := fs.fids[fc.Fid]; ok && fc.Type == plan9.Twrite && f.qid.Type&plan9.QTAUTH != 0 {
+		redacted := *fc
+		redacted.Data = nil
+		fc = &redacted
+	}
+	fs.trace.Request(fc)
+}
+
 func (fs *fileServer) msize() int {
 	return fs.messagesize
 }
 
 func (fs *fileServer) version(x *Xfid, f *Fid) *Xfid {
 	var t plan9.Fcall
+	if x.fcall.Msize > BUFSIZE {
+		// The xfid code assumes messages are at most BUFSIZE.
+		x.fcall.Msize = BUFSIZE
+	}
 	fs.messagesize = int(x.fcall.Msize)
 	t.Msize = x.fcall.Msize
 	if x.fcall.Version != "9P2000" {
@@ -264,9 +441,46 @@ func (fs *fileServer) version(x *Xfid, f *Fid) *Xfid {
 	return fs.respond(x, &t, nil)
 }
 
+// auth responds to a plan9.Tauth request. If the file server requires
+// authentication, the client must write the shared secret to the
+// returned auth fid and then present it in Tattach.
 func (fs *fileServer) auth(x *Xfid, f *Fid) *Xfid {
 	var t plan9.Fcall
-	return fs.respond(x, &t, fmt.Errorf("acme: authentication not required"))
+	if fs.authtoken == nil {
+		return fs.respond(x, &t, fmt.Errorf("acme: authentication not required"))
+	}
+	af := fs.newfid(x.fcall.Afid)
+	if af.busy {
+		return fs.respond(x, &t, fmt.Errorf("afid already in use"))
+	}
+	af.busy = true
+	af.open = false
+	af.qid = plan9.Qid{Type: plan9.QTAUTH}
+	af.dir = nil
+	af.w = nil
+	af.authdata = nil
+	af.authok = false
+	t.Aqid = af.qid
+	return fs.respond(x, &t, nil)
+}
+
+// authread responds to a read of auth fid f. There is nothing to read:
+// the protocol consists of the client writing the secret.
+func (fs *fileServer) authread(x *Xfid, f *Fid) *Xfid {
+	return fs.respond(x, &plan9.Fcall{}, nil)
+}
+
+// authwrite responds to a write of part of the secret to auth fid f.
+func (fs *fileServer) authwrite(x *Xfid, f *Fid) *Xfid {
+	var t plan9.Fcall
+	if len(f.authdata)+len(x.fcall.Data) > len(fs.authtoken)+plan9.IOHDRSZ {
+		f.authok = false
+		return fs.respond(x, &t, fmt.Errorf("authentication failed"))
+	}
+	f.authdata = append(f.authdata, x.fcall.Data...)
+	f.authok = subtle.ConstantTimeCompare(bytes.TrimSpace(f.authdata), fs.authtoken) == 1
+	t.Count = uint32(len(x.fcall.Data))
+	return fs.respond(x, &t, nil)
 }
 
 func (fs *fileServer) flush(x *Xfid, f *Fid) *Xfid {
@@ -283,6 +497,12 @@ func (fs *fileServer) attach(x *Xfid, f *Fid) *Xfid {
 		log.Printf("attach from uname %q does not match %q but allowing anyway",
 			x.fcall.Uname, fs.username)
 	}
+	if fs.authtoken != nil {
+		af, ok := fs.fids[x.fcall.Afid]
+		if x.fcall.Afid == plan9.NOFID || !ok || !af.busy || !af.authok {
+			return fs.respond(x, nil, fmt.Errorf("authentication required"))
+		}
+	}
 	var id uint64
 	if x.fcall.Aname != "" {
 		var err error
@@ -512,6 +732,9 @@ func (fs *fileServer) create(x *Xfid, f *Fid) *Xfid {
 
 // TODO(flux): I'm pretty sure handling of int64 sized files is broken by type casts to int.
 func (fs *fileServer) read(x *Xfid, f *Fid) *Xfid {
+	if f.qid.Type&plan9.QTAUTH != 0 {
+		return fs.authread(x, f)
+	}
 	if f.qid.Type&plan9.QTDIR != 0 {
 		if FILE(f.qid) == Qacme { // empty dir
 			t := plan9.Fcall{
@@ -520,52 +743,78 @@ func (fs *fileServer) read(x *Xfid, f *Fid) *Xfid {
 			fs.respond(x, &t, nil)
 			return x
 		}
-		clock := getclock()
-		id := WIN(f.qid)
-		d := dirtab
-		if id > 0 {
-			d = dirtabw
-		}
-		d = d[1:] // Skip '.'
-
-		var ids []int // for window sub-directories
-		if id == 0 {
-			row.lk.Lock()
-			for _, c := range row.col {
-				for _, w := range c.w {
-					ids = append(ids, w.id)
-				}
+		// File lengths depend on state owned by other threads, and
+		// the index waits for every window, so compute them within
+		// the xfid thread. A window directory needs its window locked.
+		x.c <- func(x *Xfid) {
+			if w := x.f.w; w != nil {
+				w.Lock('F')
+				defer w.Unlock()
 			}
-			row.lk.Unlock()
-			sort.Ints(ids)
+			fs.dirread(x, x.f)
 		}
-
-		var t plan9.Fcall
-		ninep.DirRead(&t, &x.fcall, func(i int) *plan9.Dir {
-			if i < len(d) {
-				return d[i].Dir(id, fs.username, clock)
-			}
-			i -= len(d)
-			if i < len(ids) {
-				k := ids[i]
-				return windowDirTab(k).Dir(k, fs.username, clock)
-			}
-			return nil
-		})
-
-		fs.respond(x, &t, nil)
-		return x
+		return nil
 	}
 	x.c <- xfidread
 	return nil
 }
 
+// dirread responds to a read of directory f. If f is a window
+// directory, the window must be locked.
+func (fs *fileServer) dirread(x *Xfid, f *Fid) {
+	clock := getclock()
+	id := WIN(f.qid)
+	d := dirtab
+	if id > 0 {
+		d = dirtabw
+	}
+	d = d[1:] // Skip '.'
+
+	var ids []int // for window sub-directories
+	if id == 0 {
+		row.lk.Lock()
+		for _, c := range row.col {
+			for _, w := range c.w {
+				ids = append(ids, w.id)
+			}
+		}
+		row.lk.Unlock()
+		sort.Ints(ids)
+	}
+
+	var t plan9.Fcall
+	ninep.DirRead(&t, &x.fcall, func(i int) *plan9.Dir {
+		if i < len(d) {
+			dir := d[i].Dir(id, fs.username, clock)
+			dir.Length = filelength(d[i].qid, f.w, nil)
+			return dir
+		}
+		i -= len(d)
+		if i < len(ids) {
+			k := ids[i]
+			return windowDirTab(k).Dir(k, fs.username, clock)
+		}
+		return nil
+	})
+	fs.respond(x, &t, nil)
+}
+
 func (fs *fileServer) write(x *Xfid, f *Fid) *Xfid {
+	if f.qid.Type&plan9.QTAUTH != 0 {
+		return fs.authwrite(x, f)
+	}
 	x.c <- xfidwrite
 	return nil
 }
 
 func (fs *fileServer) clunk(x *Xfid, f *Fid) *Xfid {
+	if f.qid.Type&plan9.QTAUTH != 0 {
+		f.busy = false
+		f.qid = plan9.Qid{}
+		f.authdata = nil
+		f.authok = false
+		return x.respond(&plan9.Fcall{}, nil)
+	}
 	mnt.DecRef(f.mntdir) // IncRef in attach/walk
 	x.c <- xfidclose
 	return nil
@@ -577,24 +826,54 @@ func (fs *fileServer) remove(x *Xfid, f *Fid) *Xfid {
 }
 
 func (fs *fileServer) stat(x *Xfid, f *Fid) *Xfid {
+	d := f.dir.Dir(WIN(x.f.qid), fs.username, getclock())
+	if f.w == nil && FILE(f.qid) != Qindex {
+		return fs.respondstat(x, d)
+	}
+	// The length depends on state owned by other threads,
+	// so compute it within the xfid thread.
+	x.c <- func(x *Xfid) {
+		w := x.f.w
+		if w != nil {
+			w.Lock('F')
+			defer w.Unlock()
+			if w.col == nil {
+				fs.respond(x, nil, ErrDeletedWin)
+				return
+			}
+		}
+		d.Length = filelength(FILE(x.f.qid), w, x.f.snapshot)
+		fs.respondstat(x, d)
+	}
+	return nil
+}
+
+func (fs *fileServer) respondstat(x *Xfid, d *plan9.Dir) *Xfid {
 	var t plan9.Fcall
 
 	t.Stat = make([]byte, fs.messagesize-plan9.IOHDRSZ)
-	b, _ := f.dir.Dir(WIN(x.f.qid), fs.username, getclock()).Bytes()
+	b, _ := d.Bytes()
 	if len(b) > len(t.Stat) {
 		// don't send partial directory entry
 		return fs.respond(x, nil, fmt.Errorf("msize too small"))
 	}
 	n := copy(t.Stat, b)
 	t.Stat = t.Stat[:n]
-	x = fs.respond(x, &t, nil)
-	return x
+	return fs.respond(x, &t, nil)
 }
 
 func (fs *fileServer) wstat(x *Xfid, f *Fid) *Xfid {
 	var t plan9.Fcall
 
-	return fs.respond(x, &t, ErrPermission)
+	if f.w == nil {
+		return fs.respond(x, &t, ErrPermission)
+	}
+	d, err := plan9.UnmarshalDir(x.fcall.Stat)
+	if err != nil {
+		return fs.respond(x, &t, err)
+	}
+	x.c <- func(x *Xfid) { xfidwstat(x, d) }
+	return nil
 }
 
 func (fs *fileServer) newfid(fid uint32) *Fid {
@@ -635,7 +914,7 @@ func (dt *DirTab) Dir(id int, user string, clock int64) *plan9.Dir {
 		Mode:   dt.perm,
 		Atime:  uint32(clock),
 		Mtime:  uint32(clock),
-		Length: 0, // would be nice to do better
+		Length: 0, // see filelength
 		Name:   dt.name,
 		Uid:    user,
 		Gid:    user,
@@ -651,3 +930,33 @@ func getuser() string {
 	}
 	return user.Username
 }
+
+// filelength returns the length in bytes of file q (e.g. QWbody) in
+// window w, which must be locked. If the file is a generated file
+// that has been snapshotted, the length of the snapshot is returned.
+// Files with contents that are not readable or are produced on
+// demand (e.g. event) have zero length.
+func filelength(q uint64, w *Window, snapshot []byte) uint64 {
+	if snapshot != nil {
+		return uint64(len(snapshot))
+	}
+	if w == nil {
+		if q == Qindex {
+			return uint64(len(indexbytes(nil)))
+		}
+		return 0
+	}
+	switch q {
+	case QWaddr:
+		return 2 * 12
+	case QWbody, QWdata, QWxdata:
+		w.Commit(&w.body)
+		return uint64(w.body.file.b.Nbyte())
+	case QWtag:
+		w.Commit(&w.tag)
+		return uint64(w.tag.file.b.Nbyte())
+	case QWctl, QWspans:
+		return uint64(len(genfilesnapshot(q, w)))
+	}
+	return 0
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"syscall"
+)
+
+var (
+	fsysUnixFlag     = flag.String("fsys.unix", "", "Also serve the 9P file system on this unix socket path")
+	fsysUnixModeFlag = flag.Uint("fsys.unixmode", 0600, "Permission bits of the -fsys.unix socket; also checked against client credentials")
+	fsysTCPFlag      = flag.String("fsys.tcp", "", "Also serve the 9P file system on this TCP address (requires -fsys.token)")
+	fsysTokenFlag    = flag.String("fsys.token", "", "File containing the shared secret TCP clients must write to the Tauth fid and HTTP clients must send as a bearer token")
+)
+
+// fsyslisten starts the additional 9P listeners selected by the
+// command line flags. Each accepted connection is served by its
+// own fileServer (and so has its own fid table), with Xfids from xa.
+func fsyslisten(xa *xfidAllocator) ([]net.Listener, error) {
+	var ls []net.Listener
+	if *fsysUnixFlag != "" {
+		l, err := listenUnix(*fsysUnixFlag, os.FileMode(*fsysUnixModeFlag)&os.ModePerm, xa)
+		if err != nil {
+			return nil, err
+		}
+		ls = append(ls, l)
+	}
+	if *fsysTCPFlag != "" {
+		if *fsysTokenFlag == "" {
+			closeListeners(ls)
+			return nil, fmt.Errorf("-fsys.tcp requires -fsys.token")
+		}
+		token, err := readToken(*fsysTokenFlag)
+		if err != nil {
+			closeListeners(ls)
+			return nil, err
+		}
+		l, err := net.Listen("tcp", *fsysTCPFlag)
+		if err != nil {
+			closeListeners(ls)
+			return nil, fmt.Errorf("listen failed: %v", err)
+		}
+		log.Printf("9P fileserver listening on address %v\n", l.Addr())
+		go accept9p(l, token, nil, xa)
+		ls = append(ls, l)
+	}
+	return ls, nil
+}
+
+func closeListeners(ls []net.Listener) {
+	for _, l := range ls {
+		l.Close()
+	}
+}
+
+// listenUnix listens on unix socket path, which is created with
+// permission bits mode. A stale socket left by a previous instance
+// is replaced, but any other kind of file is an error. Connections
+// are served with Xfids from xa.
+func listenUnix(path string, mode os.FileMode, xa *xfidAllocator) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%v exists and is not a socket", path)
+		}
+		if c, err := net.Dial("unix", path); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("%v is in use", path)
+		}
+		os.Remove(path)
+	}
+
+	// Create the socket without any access, so that nobody
+	// can connect before the permission bits are set.
+	old := syscall.Umask(0777)
+	l, err := net.Listen("unix", path)
+	syscall.Umask(old)
+	if err != nil {
+		return nil, fmt.Errorf("listen failed: %v", err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	go accept9p(l, nil, func(c net.Conn) error {
+		return checkPeer(c, mode)
+	}, xa)
+	return l, nil
+}
+
+// checkPeer verifies that the credentials of the process at the
+// other end of unix socket connection c are allowed by mode: the
+// owner (our own user) is always allowed, members of our group
+// are allowed if mode has group permissions, and anybody else
+// if mode has other permissions.
+func checkPeer(c net.Conn, mode os.FileMode) error {
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return fmt.Errorf("not a unix socket connection")
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var (
+		cred    *syscall.Ucred
+		credErr error
+	)
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return err
+	}
+	if credErr != nil {
+		return credErr
+	}
+	switch {
+	case int(cred.Uid) == os.Getuid():
+	case mode&0060 != 0 && int(cred.Gid) == os.Getgid():
+	case mode&0006 != 0:
+	default:
+		return fmt.Errorf("permission denied for uid %d gid %d", cred.Uid, cred.Gid)
+	}
+	return nil
+}
+
+// fsystoken returns the shared secret in the -fsys.token file,
+// or nil if there is none.
+func fsystoken() ([]byte, error) {
+	if *fsysTokenFlag == "" {
+		return nil, nil
+	}
+	return readToken(*fsysTokenFlag)
+}
+
+// readToken reads the shared secret from file filename, which must
+// not be accessible by group or others.
+func readToken(filename string) ([]byte, error) {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if fi.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("token file %v is accessible by others (mode %v)", filename, fi.Mode().Perm())
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return nil, fmt.Errorf("token file %v is empty", filename)
+	}
+	return b, nil
+}
+
+// accept9p serves each connection accepted on l concurrently,
+// after checking it with check (if not nil), with Xfids from xa.
+func accept9p(l net.Listener, token []byte, check func(net.Conn) error, xa *xfidAllocator) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return // listener closed
+		}
+		if check != nil {
+			if err := check(c); err != nil {
+				log.Printf("rejecting 9P client %v: %v", c.RemoteAddr(), err)
+				c.Close()
+				continue
+			}
+		}
+		go serve9p(c, token, xa)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"9fans.net/go/plan9/client"
+)
+
+func TestServe9pAuth(t *testing.T) {
+	xa, stop := startXfidallocthread()
+	defer stop()
+	token := []byte("sesame")
+
+	c1, c2 := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		serve9p(c2, token, xa)
+		close(done)
+	}()
+	conn, err := client.NewConn(c1)
+	if err != nil {
+		t.Fatalf("NewConn failed: %v", err)
+	}
+	defer func() {
+		conn.Close()
+		<-done
+	}()
+
+	if _, err := conn.Attach(nil, getuser(), ""); err == nil {
+		t.Fatalf("attach without authentication succeeded")
+	}
+
+	afid, err := conn.Auth(getuser(), "")
+	if err != nil {
+		t.Fatalf("Auth failed: %v", err)
+	}
+	if _, err := afid.Write([]byte("open sesame")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := conn.Attach(afid, getuser(), ""); err == nil {
+		t.Fatalf("attach with wrong secret succeeded")
+	}
+	afid.Close()
+
+	afid, err = conn.Auth(getuser(), "")
+	if err != nil {
+		t.Fatalf("Auth failed: %v", err)
+	}
+	if _, err := afid.Write([]byte("sesame\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := conn.Attach(afid, getuser(), ""); err != nil {
+		t.Fatalf("attach with correct secret failed: %v", err)
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	xa, stop := startXfidallocthread()
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "edwood")
+	if err != nil {
+		t.Fatalf("can't create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "acme")
+
+	l, err := listenUnix(path, 0600, xa)
+	if err != nil {
+		t.Fatalf("listenUnix failed: %v", err)
+	}
+	defer l.Close()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	if got, want := fi.Mode().Perm(), os.FileMode(0600); got != want {
+		t.Errorf("socket mode is %v; want %v", got, want)
+	}
+	if _, err := listenUnix(path, 0600, xa); err == nil {
+		t.Errorf("second listenUnix on %v succeeded", path)
+	}
+
+	// Two concurrent clients, each with its own fid table.
+	for i := 0; i < 2; i++ {
+		conn, err := client.Dial("unix", path)
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer conn.Close()
+		if _, err := conn.Attach(nil, getuser(), ""); err != nil {
+			t.Fatalf("Attach failed: %v", err)
+		}
+	}
+}
+
+func TestReadToken(t *testing.T) {
+	f, err := ioutil.TempFile("", "edwood")
+	if err != nil {
+		t.Fatalf("can't create temporary file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("sesame\n")
+	f.Close()
+
+	os.Chmod(f.Name(), 0644)
+	if _, err := readToken(f.Name()); err == nil {
+		t.Errorf("readToken accepted a world-readable file")
+	}
+	os.Chmod(f.Name(), 0600)
+	b, err := readToken(f.Name())
+	if err != nil {
+		t.Fatalf("readToken failed: %v", err)
+	}
+	if got, want := string(b), "sesame"; got != want {
+		t.Errorf("token is %q; want %q", got, want)
+	}
+}
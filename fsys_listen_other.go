@@ -0,0 +1,16 @@
+// +build !linux
+
+package main
+
+import "net"
+
+// fsyslisten does nothing: additional 9P listeners are only
+// supported on Linux.
+func fsyslisten(xa *xfidAllocator) ([]net.Listener, error) {
+	return nil, nil
+}
+
+// fsystoken returns nil: there is no -fsys.token flag.
+func fsystoken() ([]byte, error) {
+	return nil, nil
+}
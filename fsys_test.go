@@ -2,15 +2,19 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"reflect"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -18,6 +22,8 @@ import (
 	"9fans.net/go/plan9"
 	"9fans.net/go/plan9/client"
 	"github.com/google/go-cmp/cmp"
+	"github.com/rjkroege/edwood/internal/dumpfile"
+	"github.com/rjkroege/edwood/internal/edwoodtest"
 	"github.com/rjkroege/edwood/internal/ninep"
 )
 
@@ -113,6 +119,14 @@ func augmentPathEnv() {
 // namespace so that a test may exercise IPC to the subordinate edwood
 // process.
 func startAcme(t *testing.T, args ...string) *Acme {
+	acmd := exec.Command(os.Args[0], args...)
+	acmd.Env = []string{"TEST_MAIN=edwood"}
+	return startAcmeCmd(t, acmd)
+}
+
+// startAcmeCmd is startAcme for any program serving acme's file
+// system, which acmd runs with acmd.Env added to the environment.
+func startAcmeCmd(t *testing.T, acmd *exec.Cmd) *Acme {
 	// If $USER is not set (i.e. running in a Docker container)
 	// MountService will fail. Detect this and give up if this is so.
 	if _, hzuser := os.LookupEnv("USER"); !hzuser {
@@ -126,9 +140,7 @@ func startAcme(t *testing.T, args ...string) *Acme {
 	os.Setenv("NAMESPACE", ns)
 	augmentPathEnv()
 
-	acmd := exec.Command(os.Args[0], args...)
-	acmd.Env = append(os.Environ(), "TEST_MAIN=edwood")
-
+	acmd.Env = append(os.Environ(), acmd.Env...)
 	acmd.Stdout = os.Stdout
 	acmd.Stderr = os.Stderr
 	if err := acmd.Start(); err != nil {
@@ -149,6 +161,7 @@ func startAcme(t *testing.T, args ...string) *Acme {
 		}
 	}
 	return &Acme{
+		t:    t,
 		ns:   ns,
 		cmd:  acmd,
 		fsys: fsys,
@@ -452,6 +465,233 @@ Occasion
 	fid.Close()
 }
 
+// TestHeadless runs Exec commands and Edit in an edwood without a
+// display, driving it entirely through 9P, and makes it exit.
+func TestHeadless(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping on windows")
+	}
+	a := startAcme(t, "-headless")
+	defer a.Cleanup()
+	tfs := tFsys{t, a.fsys}
+
+	// execute runs cmd as if clicked with button 2 in the tag of
+	// window id.
+	execute := func(id, cmd string) {
+		if err := fsysExec(a.fsys, "/"+id, cmd); err != nil {
+			t.Fatalf("can't execute %q in window %v: %v", cmd, id, err)
+		}
+	}
+
+	tfs.Write("/new/body", "hello world\n")
+	execute("2", "Edit ,s/hello/goodbye/")
+	if got, want := tfs.Read("/2/body"), "goodbye world\n"; got != want {
+		t.Errorf("body after Edit is %q; want %q", got, want)
+	}
+	tfs.Write("/2/ctl", "clean")
+
+	dir, err := ioutil.TempDir("", "edwood.test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, ctl := range []string{"/ctl", "/2/ctl"} {
+		file := filepath.Join(dir, "shot.png")
+		tfs.Write(ctl, "screenshot "+file)
+		if fi, err := os.Stat(file); err != nil || fi.Size() == 0 {
+			t.Errorf("screenshot through %v not written: %v", ctl, err)
+		}
+		os.Remove(file)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- a.cmd.Wait() }()
+	fsysExec(a.fsys, "/2", "Exit") // which may exit before responding
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("edwood exited with %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Errorf("edwood didn't exit")
+	}
+}
+
+func TestAutodumpRestore(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping on windows")
+	}
+	dir, err := ioutil.TempDir("", "edwood.test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	start := func(args ...string) *Acme {
+		acmd := exec.Command(os.Args[0], append([]string{"-headless"}, args...)...)
+		acmd.Env = []string{"TEST_MAIN=edwood", "HOME=" + dir}
+		return startAcmeCmd(t, acmd)
+	}
+	name := filepath.Join(dir, "scratch")
+
+	a := start("-autodump", "50ms")
+	tfs := tFsys{t, a.fsys}
+	tfs.Write("/new/body", "not saved\n")
+	tfs.Write("/2/ctl", "name "+name)
+	file := filepath.Join(dir, "edwood.autodump")
+	for i := 0; ; i++ {
+		d, err := dumpfile.Load(file)
+		if err == nil && len(d.Windows) == 2 && strings.HasPrefix(d.Windows[1].Tag.Buffer, name+" ") {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("no automatic dump of the window: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	a.Cleanup() // as if it crashed
+
+	a = start("-restore")
+	defer a.Cleanup()
+	tfs = tFsys{t, a.fsys}
+	for _, line := range strings.Split(tfs.Read("/index"), "\n") {
+		f := strings.Fields(line)
+		if len(f) > 5 && f[5] == name {
+			if got, want := tfs.Read("/"+f[0]+"/body"), "not saved\n"; got != want {
+				t.Errorf("restored body is %q; want %q", got, want)
+			}
+			return
+		}
+	}
+	t.Errorf("window %v not restored", name)
+}
+
+func TestLoadExecFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping on windows")
+	}
+	dir, err := ioutil.TempDir("", "edwood.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "-ls")
+	dump := &dumpfile.Content{
+		CurrentDir: dir,
+		Columns:    []dumpfile.Column{{Tag: dumpfile.Text{Buffer: "New Cut Paste Snarf Sort Zerox Delcol "}}},
+		Windows: []*dumpfile.Window{{
+			Type:        dumpfile.Exec,
+			Tag:         dumpfile.Text{Buffer: name + " Del Snarf | Look"},
+			ExecDir:     dir,
+			ExecCommand: "ls nonexistent",
+			ExecEnv:     []string{"LANG=C"},
+		}},
+	}
+	file := filepath.Join(dir, "edwood.dump")
+	if err := dump.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	a := startAcme(t, "-headless", "-l", file)
+	defer a.Cleanup()
+	tfs := tFsys{t, a.fsys}
+	for i := 0; i < 100; i++ {
+		// The index is empty until the dump has been loaded.
+		index, err := fsysRead(a.fsys, "/index")
+		if err != nil {
+			t.Fatalf("can't read index: %v", err)
+		}
+		for _, line := range strings.Split(index, "\n") {
+			f := strings.Fields(line)
+			if len(f) > 5 && f[5] == name {
+				body := tfs.Read("/" + f[0] + "/body")
+				if !strings.Contains(body, "nonexistent") || !strings.HasSuffix(body, "ls nonexistent: exit status 2\n") {
+					t.Errorf("failure is reported as %q", body)
+				}
+				return
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Errorf("failure of %v not reported in its window", dump.Windows[0].ExecCommand)
+}
+
+func TestReplay(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping on windows")
+	}
+	a := startAcme(t, "-headless", "-c", "1")
+	defer a.Cleanup()
+	tfs := tFsys{t, a.fsys}
+
+	// Leave a window of many lines as the only one in the column, so
+	// that it covers the middle of the screen.
+	tfs.Write("/1/ctl", "delete")
+	tfs.Write("/new/body", strings.Repeat("alpha beta\n", 60))
+	tfs.Write("/2/addr", "0")
+	tfs.Write("/2/ctl", "dot=addr\nshow")
+
+	dir, err := ioutil.TempDir("", "edwood.test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	replay := func(session string) *dumpfile.Window {
+		file := filepath.Join(dir, "session")
+		if err := ioutil.WriteFile(file, []byte(session), 0644); err != nil {
+			t.Fatal(err)
+		}
+		tfs.Write("/ctl", "replay "+file)
+		dump := filepath.Join(dir, "dump")
+		tfs.Write("/ctl", "dump "+dump)
+		d, err := dumpfile.Load(dump)
+		if err != nil {
+			t.Fatalf("can't load dump: %v", err)
+		}
+		if len(d.Windows) != 1 {
+			t.Fatalf("dumped %d windows; want 1", len(d.Windows))
+		}
+		return d.Windows[0]
+	}
+
+	w := replay(`# double-click the first word of a line
+0 mouse 30 300 0 1000
+20 mouse 30 300 1 1020
+40 mouse 30 300 0 1040
+60 mouse 30 300 1 1060
+80 mouse 30 300 0 1080
+# type over it
+100 key 88
+120 mouse 30 300 0 1120
+`)
+	body := w.Body
+	if got, want := strings.Count(body.Buffer, "X beta\n"), 1; got != want {
+		t.Errorf("body has %d lines typed over; want %d", got, want)
+	}
+	if got, want := strings.Count(body.Buffer, "alpha beta\n"), 59; got != want {
+		t.Errorf("body has %d lines left alone; want %d", got, want)
+	}
+	if i := strings.Index(body.Buffer, "X"); body.Q0 != i+1 || body.Q1 != i+1 {
+		t.Errorf("body selection is %d,%d; want %d,%d", body.Q0, body.Q1, i+1, i+1)
+	}
+
+	n := len(body.Buffer)
+	w = replay(`# sweep down a few lines with button 1
+0 mouse 30 400 0 2000
+20 mouse 30 400 1 2020
+40 mouse 30 440 1 2040
+# and cut it by chording button 2
+60 mouse 30 440 3 2060
+80 mouse 30 440 1 2080
+100 mouse 30 440 0 2100
+`)
+	body = w.Body
+	if cut := n - len(body.Buffer); cut <= 0 || cut%len("alpha beta\n") != 0 {
+		t.Errorf("cut %d bytes; want whole lines' worth", cut)
+	}
+	if body.Q0 != body.Q1 {
+		t.Errorf("body selection is %d,%d after cut; want it empty", body.Q0, body.Q1)
+	}
+}
+
 type tFsys struct {
 	t    *testing.T
 	fsys *client.Fsys
@@ -1267,7 +1507,11 @@ func TestFileServerRead(t *testing.T) {
 
 	var rootDirs []plan9.Dir
 	for _, dt := range dirtab[1:] { // skip "."
-		rootDirs = append(rootDirs, *dt.Dir(0, "gopher", fixedClockValue))
+		d := dt.Dir(0, "gopher", fixedClockValue)
+		if dt.qid == Qindex {
+			d.Length = uint64(len(indexbytes(nil)))
+		}
+		rootDirs = append(rootDirs, *d)
 	}
 	for id := 1; id <= WinID; id++ {
 		rootDirs = append(rootDirs, *windowDirTab(id).Dir(0, "gopher", fixedClockValue))
@@ -1314,7 +1558,7 @@ func TestFileServerRead(t *testing.T) {
 
 			var got []plan9.Dir
 			for {
-				fs.read(x, x.f)
+				runXfid(x, func() { fs.read(x, x.f) })
 
 				fc := mc.ReadFcall(t)
 				if len(fc.Data) == 0 {
@@ -1335,6 +1579,47 @@ func TestFileServerRead(t *testing.T) {
 	}
 }
 
+func TestFileServerReadRootLockedWindow(t *testing.T) {
+	WinID = 0
+	w := NewWindow().initHeadless(nil)
+	row.col = []*Column{{w: []*Window{w}}}
+	defer func() {
+		WinID = 0
+		row = Row{}
+	}()
+
+	// The length of the index waits for a window held by another
+	// thread, which must not stop the file server.
+	w.Lock('E')
+	mc := new(mockConn)
+	fs := &fileServer{
+		conn:     mc,
+		username: "gopher",
+	}
+	x := &Xfid{
+		fcall: plan9.Fcall{
+			Type:  plan9.Tread,
+			Count: 1024,
+		},
+		f: &Fid{
+			qid: plan9.Qid{
+				Type: plan9.QTDIR,
+				Path: QID(0, Qdir),
+			},
+		},
+		c: make(chan func(*Xfid), 1),
+	}
+	if fs.read(x, x.f) != nil {
+		t.Fatalf("directory read responded within the file server")
+	}
+	w.Unlock()
+	(<-x.c)(x)
+	fc := mc.ReadFcall(t)
+	if fc.Type != plan9.Rread || len(fc.Data) == 0 {
+		t.Errorf("got response %v; want directory entries", fc)
+	}
+}
+
 func TestFileServerRemove(t *testing.T) {
 	mc := new(mockConn)
 	fs := &fileServer{conn: mc}
@@ -1367,8 +1652,9 @@ func TestFileServerStat(t *testing.T) {
 				}
 				x.f = &Fid{
 					dir: dt,
+					qid: plan9.Qid{Path: QID(0, dt.qid)},
 				}
-				fs.stat(x, x.f)
+				runXfid(x, func() { fs.stat(x, x.f) })
 
 				fc := mc.ReadFcall(t)
 				if got, want := fc.Type, uint8(plan9.Rstat); got != want {
@@ -1416,10 +1702,179 @@ func TestFileServerStatSmallMsize(t *testing.T) {
 func TestFileServerWstat(t *testing.T) {
 	mc := new(mockConn)
 	fs := &fileServer{conn: mc}
-	fs.wstat(&Xfid{}, nil)
+	fs.wstat(&Xfid{}, &Fid{})
 
 	want := errorFcall(ErrPermission)
 	if got := mc.ReadFcall(t); !cmp.Equal(got, want) {
 		t.Fatalf("got response %v; want %v", got, want)
 	}
 }
+
+func TestFileServerStatLength(t *testing.T) {
+	w := NewWindow().initHeadless(nil)
+	w.col = new(Column)
+	w.display = edwoodtest.NewDisplay()
+	w.body.fr = &MockFrame{}
+	w.tag.file.b = Buffer("/etc/hosts Del Snarf | Look Get ")
+	w.body.file.b = Buffer("Hello, 世界!\n")
+
+	for _, tc := range []struct {
+		q    uint64
+		want uint64
+	}{
+		{Qdir, 0},
+		{QWaddr, 24},
+		{QWbody, 15},
+		{QWdata, 15},
+		{QWxdata, 15},
+		{QWtag, 32},
+		{QWctl, uint64(len(w.CtlPrint(true)))},
+		{QWevent, 0},
+	} {
+		mc := new(mockConn)
+		fs := &fileServer{
+			conn:        mc,
+			messagesize: 8192,
+			username:    "gopher",
+		}
+		x := &Xfid{
+			fcall: plan9.Fcall{Type: plan9.Tstat},
+			f: &Fid{
+				qid: plan9.Qid{Path: QID(w.id, tc.q)},
+				w:   w,
+				dir: dirtabw[0],
+			},
+		}
+		runXfid(x, func() { fs.stat(x, x.f) })
+
+		fc := mc.ReadFcall(t)
+		if fc.Type != plan9.Rstat {
+			t.Fatalf("stat of %v failed: %v", tc.q, fc.Ename)
+		}
+		d, err := plan9.UnmarshalDir(fc.Stat)
+		if err != nil {
+			t.Fatalf("UnmarshalDir failed: %v", err)
+		}
+		if d.Length != tc.want {
+			t.Errorf("length of file %v is %v; want %v", tc.q, d.Length, tc.want)
+		}
+	}
+}
+
+// runXfid calls f while serving functions sent to x.c, just like
+// xfidctl, and waits for them to finish.
+func runXfid(x *Xfid, f func()) {
+	x.c = make(chan func(*Xfid))
+	done := make(chan struct{})
+	go func() {
+		for f := range x.c {
+			f(x)
+		}
+		close(done)
+	}()
+	f()
+	close(x.c)
+	<-done
+}
+
+// startXfidallocthread starts an xfidallocthread of its own for tests
+// that exercise a complete file server. It returns the allocator
+// and a function that stops it.
+func startXfidallocthread() (*xfidAllocator, func()) {
+	xa := newXfidAllocator()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		xfidallocthread(ctx, xa, nil)
+		close(done)
+	}()
+	return xa, func() {
+		cancel()
+		<-done
+	}
+}
+
+func TestFsysTraceReplay(t *testing.T) {
+	load := func() {
+		filename := editDumpFileForTesting(t, filepath.Join("testdata", "example.dump"))
+		defer os.Remove(filename)
+		setGlobalsForLoadTesting()
+		if err := row.Load(nil, filename, true); err != nil {
+			t.Fatalf("Row.Load failed: %v", err)
+		}
+	}
+	load()
+	xa, stop := startXfidallocthread()
+	defer stop()
+
+	var wg sync.WaitGroup
+	dial := func() (io.ReadWriteCloser, error) {
+		c1, c2 := net.Pipe()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve9p(c2, nil, xa)
+		}()
+		return c1, nil
+	}
+
+	var trace bytes.Buffer
+	fsystrace = ninep.NewTrace(&trace)
+	rwc, _ := dial()
+	conn, err := client.NewConn(rwc)
+	if err != nil {
+		t.Fatalf("NewConn failed: %v", err)
+	}
+	fsys, err := conn.Attach(nil, getuser(), "")
+	if err != nil {
+		t.Fatalf("Attach failed: %v", err)
+	}
+	for _, name := range []string{"index", "1/tag", "1/body"} {
+		fid, err := fsys.Open(name, plan9.OREAD)
+		if err != nil {
+			t.Fatalf("can't open %v: %v", name, err)
+		}
+		if _, err := ioutil.ReadAll(fid); err != nil {
+			t.Fatalf("can't read %v: %v", name, err)
+		}
+		fid.Close()
+	}
+	fid, err := fsys.Open("1/body", plan9.OWRITE)
+	if err != nil {
+		t.Fatalf("can't open body: %v", err)
+	}
+	fid.Write([]byte("hello\n"))
+	fid.Close()
+	if _, err := fsys.Stat("1/body"); err != nil {
+		t.Fatalf("can't stat body: %v", err)
+	}
+	if _, err := fsys.Open("1/nonexistent", plan9.OREAD); err == nil {
+		t.Fatalf("opened nonexistent file")
+	}
+	conn.Close()
+	wg.Wait()
+	fsystrace = nil
+
+	entries, err := ninep.ReadTrace(&trace)
+	if err != nil {
+		t.Fatalf("ReadTrace failed: %v", err)
+	}
+	if len(entries) == 0 {
+		t.Fatalf("nothing traced")
+	}
+
+	// Replaying the trace against a fresh Edwood gets the same responses.
+	load()
+	r := &ninep.Replayer{Dial: dial}
+	diffs, err := r.Replay(entries)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(diffs) > 0 {
+		t.Errorf("replay differs from trace:\n%v", strings.Join(diffs, "\n"))
+	}
+	if got, want := row.LookupWin(1).body.file.b.String(), "hello\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("body after replay is %q; want suffix %q", got, want)
+	}
+}
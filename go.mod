@@ -15,7 +15,7 @@ require (
 	github.com/sanity-io/litter v1.1.0
 	github.com/stretchr/testify v1.3.0 // indirect
 	golang.org/x/exp v0.0.0-20190212162250-21964bba6549 // indirect
-	golang.org/x/image v0.0.0-20190209060608-ef4a1470e0dc // indirect
+	golang.org/x/image v0.0.0-20190209060608-ef4a1470e0dc
 	golang.org/x/mobile v0.0.0-20190127143845-a42111704963 // indirect
 	golang.org/x/sys v0.0.0-20190219092855-153ac476189d // indirect
 )
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+
+	"github.com/rjkroege/edwood/internal/draw"
+	"github.com/rjkroege/edwood/internal/frame"
+)
+
+// GutterMode selects the line numbers shown in the gutter to the left
+// of a window body.
+type GutterMode int
+
+const (
+	GutterNone     GutterMode = iota // no gutter
+	GutterAbsolute                   // line numbers counted from the start of the file
+	GutterRelative                   // line numbers counted from the line containing dot
+)
+
+func (m GutterMode) String() string {
+	switch m {
+	case GutterNone:
+		return "off"
+	case GutterAbsolute:
+		return "absolute"
+	case GutterRelative:
+		return "relative"
+	}
+	return fmt.Sprintf("GutterMode(%d)", int(m))
+}
+
+// parseGutterMode returns the GutterMode named s. The empty string
+// selects GutterNone.
+func parseGutterMode(s string) (GutterMode, error) {
+	switch s {
+	case "", "off":
+		return GutterNone, nil
+	case "abs", "absolute":
+		return GutterAbsolute, nil
+	case "rel", "relative":
+		return GutterRelative, nil
+	}
+	return GutterNone, fmt.Errorf("bad gutter mode %q", s)
+}
+
+// guttercol is the colour of the line numbers in a gutter.
+var guttercol draw.Image
+
+// gutter holds the state of the line-number gutter of a Text.
+type gutter struct {
+	mode    GutterMode
+	r       image.Rectangle // where the numbers are drawn, to the left of the frame
+	ndigits int             // width of r in digits
+
+	// orgline is the number of the line containing rune org, or 0 if
+	// it must be counted again. It is moved along with Text.org.
+	org     int
+	orgline int
+
+	nums []int // number drawn on each line of the frame, or 0 for none
+}
+
+// minGutterDigits is the minimum width of a gutter in digits.
+const minGutterDigits = 3
+
+// layoutgutter reserves space for the gutter on the left of r, the
+// rectangle of the Text's frame, and returns what is left for the
+// frame.
+func (t *Text) layoutgutter(r image.Rectangle) image.Rectangle {
+	g := &t.gutter
+	g.nums = nil
+	if g.mode == GutterNone {
+		g.r = image.Rectangle{}
+		return r
+	}
+
+	nl := 1
+	for _, c := range t.file.b {
+		if c == '\n' {
+			nl++
+		}
+	}
+	if n := len(strconv.Itoa(nl)); n > g.ndigits {
+		g.ndigits = n
+	}
+	if g.ndigits < minGutterDigits {
+		g.ndigits = minGutterDigits
+	}
+
+	wid := (g.ndigits + 1) * fontget(t.font, t.display).StringWidth("0")
+	if wid > r.Dx()/2 {
+		wid = r.Dx() / 2
+	}
+	g.r = image.Rect(r.Min.X, r.Min.Y, r.Min.X+wid, r.Max.Y)
+	r.Min.X += wid
+	return r
+}
+
+// gutterinserted updates the gutter for the insertion of r at q0.
+func (t *Text) gutterinserted(q0 int, r []rune) {
+	g := &t.gutter
+	if q0 >= g.org || g.orgline == 0 {
+		return
+	}
+	g.org += len(r)
+	for _, c := range r {
+		if c == '\n' {
+			g.orgline++
+		}
+	}
+}
+
+// gutterdeleted updates the gutter for the deletion of runes [q0, q1).
+func (t *Text) gutterdeleted(q0, q1 int) {
+	if q0 < t.gutter.org {
+		t.gutter.orgline = 0
+	}
+}
+
+// orgline returns the number of the line containing t.org.
+func (t *Text) orgline() int {
+	g := &t.gutter
+	if g.orgline == 0 || g.org > t.file.Size() {
+		g.org, g.orgline = 0, 1
+	}
+	for ; g.org < t.org; g.org++ {
+		if t.file.ReadC(g.org) == '\n' {
+			g.orgline++
+		}
+	}
+	for ; g.org > t.org; g.org-- {
+		if t.file.ReadC(g.org-1) == '\n' {
+			g.orgline--
+		}
+	}
+	return g.orgline
+}
+
+// lineof returns the number of the line containing q.
+func (t *Text) lineof(q int) int {
+	line := t.orgline()
+	for p := t.org; p < q; p++ {
+		if t.file.ReadC(p) == '\n' {
+			line++
+		}
+	}
+	for p := t.org; p > q; p-- {
+		if t.file.ReadC(p-1) == '\n' {
+			line--
+		}
+	}
+	return line
+}
+
+// drawgutter draws the number of each line of the Text that starts in
+// its frame. Only the numbers that have changed since the gutter was
+// last drawn are drawn again.
+func (t *Text) drawgutter() {
+	g := &t.gutter
+	if g.mode == GutterNone || g.r.Empty() || t.fr == nil || t.display == nil {
+		return
+	}
+	ffs := t.fr.GetFrameFillStatus()
+	height := t.fr.DefaultFontHeight()
+	nums := make([]int, ffs.Maxlines)
+
+	line := t.orgline()
+	dot := 0
+	if g.mode == GutterRelative {
+		dot = t.lineof(t.q0)
+	}
+	maxnum := 0
+	number := func(p, line int) {
+		i := (t.fr.Ptofchar(p).Y - t.fr.Rect().Min.Y) / height
+		if i < 0 || i >= len(nums) {
+			return
+		}
+		n := line
+		if g.mode == GutterRelative && line != dot {
+			n = line - dot
+			if n < 0 {
+				n = -n
+			}
+		}
+		nums[i] = n
+		if n > maxnum {
+			maxnum = n
+		}
+	}
+	if t.org == 0 || t.file.ReadC(t.org-1) == '\n' {
+		number(0, line)
+	}
+	for p := 0; p < ffs.Nchars; p++ {
+		if t.file.ReadC(t.org+p) == '\n' {
+			line++
+			number(p+1, line)
+		}
+	}
+
+	// Make the gutter wider if the numbers don't fit. This relays out
+	// the frame, so don't do it while typing.
+	if n := len(strconv.Itoa(maxnum)); n > g.ndigits && !t.file.HasUncommitedChanges() {
+		g.ndigits = n
+		t.relayout()
+		return
+	}
+
+	font := fontget(t.font, t.display)
+	screen := t.display.ScreenImage()
+	for i, n := range nums {
+		if i < len(g.nums) && g.nums[i] == n {
+			continue
+		}
+		r := image.Rect(g.r.Min.X, g.r.Min.Y+i*height, g.r.Max.X, g.r.Min.Y+(i+1)*height).Intersect(g.r)
+		screen.Draw(r, textcolors[frame.ColBack], nil, image.Point{})
+		if n > 0 {
+			s := strconv.Itoa(n)
+			pt := image.Pt(r.Max.X-font.StringWidth(s+" "), r.Min.Y)
+			screen.Bytes(pt, guttercol, image.Point{}, font, []byte(s))
+		}
+	}
+	g.nums = nums
+}
+
+// SetGutter sets the mode of the gutter of w's body and redraws it.
+func (w *Window) SetGutter(mode GutterMode) {
+	t := &w.body
+	if t.gutter.mode == mode {
+		return
+	}
+	t.gutter.mode = mode
+	if t.fr == nil || t.display == nil {
+		return
+	}
+	t.relayout()
+	t.ScrDraw(t.fr.GetFrameFillStatus().Nchars)
+}
+
+// relayout lays out and draws the Text again in its current rectangle.
+// The enclosing Window may have extended t.all above the frame to
+// cover the border between the tag and body, which is preserved.
+func (t *Text) relayout() {
+	all := t.all
+	r := all
+	r.Min.Y = t.fr.Rect().Min.Y
+	t.Resize(r, true, false)
+	t.all = all
+}
+
+// gutterx implements the Gutter command. With an argument of abs,
+// rel or off, it sets the mode of the window's gutter; without one it
+// turns the gutter on or off. An argument in upper case sets the mode
+// of every window and of windows created later.
+func gutterx(et *Text, _ *Text, argt *Text, _, _ bool, arg string) {
+	r, _ := getarg(argt, false, true)
+	if r == "" {
+		r = strings.TrimSpace(arg)
+	}
+	if r == "" {
+		if et == nil || et.w == nil {
+			return
+		}
+		mode := GutterAbsolute
+		if et.w.body.gutter.mode != GutterNone {
+			mode = GutterNone
+		}
+		et.w.SetGutter(mode)
+		return
+	}
+	mode, err := parseGutterMode(r)
+	if err != nil {
+		mode, err = parseGutterMode(strings.ToLower(r))
+		if err != nil {
+			warning(nil, "%v\n", err)
+			return
+		}
+		*globalGutter = mode.String()
+		row.AllWindows(func(w *Window) { w.SetGutter(mode) })
+		return
+	}
+	if et != nil && et.w != nil {
+		et.w.SetGutter(mode)
+	}
+}
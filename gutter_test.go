@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseGutterMode(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		mode GutterMode
+		ok   bool
+	}{
+		{"", GutterNone, true},
+		{"off", GutterNone, true},
+		{"abs", GutterAbsolute, true},
+		{"absolute", GutterAbsolute, true},
+		{"rel", GutterRelative, true},
+		{"relative", GutterRelative, true},
+		{"sideways", GutterNone, false},
+	} {
+		mode, err := parseGutterMode(tc.s)
+		if mode != tc.mode || (err == nil) != tc.ok {
+			t.Errorf("parseGutterMode(%q) is %v, %v; want %v, ok=%v", tc.s, mode, err, tc.mode, tc.ok)
+		}
+	}
+}
+
+// gutternums returns the first n line numbers in the gutter of w.
+func gutternums(w *Window, n int) []int {
+	nums := w.body.gutter.nums
+	if len(nums) > n {
+		nums = nums[:n]
+	}
+	return nums
+}
+
+func TestGutter(t *testing.T) {
+	w := loadWindowForTesting(t, strings.Repeat("x\n", 200))
+	fr := w.body.fr.Rect()
+
+	w.SetGutter(GutterAbsolute)
+	if got := w.body.fr.Rect(); got.Min.X <= fr.Min.X || got.Max.X != fr.Max.X {
+		t.Fatalf("frame is %v with gutter; want narrower than %v", got, fr)
+	}
+	if got, want := w.body.gutter.r.Max.X, w.body.fr.Rect().Min.X; got != want {
+		t.Errorf("gutter ends at %v; want %v", got, want)
+	}
+	if diff := cmp.Diff([]int{1, 2, 3}, gutternums(w, 3)); diff != "" {
+		t.Errorf("gutter mismatch (-want +got):\n%s", diff)
+	}
+
+	w.body.SetOrigin(20, true)
+	if diff := cmp.Diff([]int{11, 12, 13}, gutternums(w, 3)); diff != "" {
+		t.Errorf("gutter after scrolling mismatch (-want +got):\n%s", diff)
+	}
+
+	// Edits before the origin change the numbers.
+	w.body.file.InsertAt(0, []rune("x\ny\n"))
+	if diff := cmp.Diff([]int{13, 14, 15}, gutternums(w, 3)); diff != "" {
+		t.Errorf("gutter after insertion mismatch (-want +got):\n%s", diff)
+	}
+	w.body.file.DeleteAt(0, 2)
+	if diff := cmp.Diff([]int{12, 13, 14}, gutternums(w, 3)); diff != "" {
+		t.Errorf("gutter after deletion mismatch (-want +got):\n%s", diff)
+	}
+
+	// A line continued on the next line of the frame has one number.
+	q := w.body.org + 1
+	w.body.file.InsertAt(q, []rune(strings.Repeat("z", 1000)))
+	if nums := gutternums(w, 3); len(nums) < 3 || nums[0] != 12 || nums[1] != 0 {
+		t.Errorf("gutter with a long line is %v; want [12 0 ...]", nums)
+	}
+	w.body.file.DeleteAt(q, q+1000)
+
+	w.SetGutter(GutterRelative)
+	w.body.SetSelect(w.body.org+4, w.body.org+4)
+	if diff := cmp.Diff([]int{2, 1, 14, 1, 2}, gutternums(w, 5)); diff != "" {
+		t.Errorf("relative gutter mismatch (-want +got):\n%s", diff)
+	}
+
+	w.SetGutter(GutterNone)
+	if got := w.body.fr.Rect(); got != fr {
+		t.Errorf("frame is %v without gutter; want %v", got, fr)
+	}
+
+	w.SetGutter(GutterRelative)
+	dump, err := row.dump()
+	if err != nil {
+		t.Fatalf("dump failed: %v", err)
+	}
+	if got, want := dump.Windows[0].Gutter, "relative"; got != want {
+		t.Errorf("dumped gutter is %q; want %q", got, want)
+	}
+	if got, want := dump.Windows[1].Gutter, ""; got != want {
+		t.Errorf("dumped gutter is %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,545 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rjkroege/edwood/internal/draw"
+	"github.com/rjkroege/edwood/internal/frame"
+)
+
+// TokenKind classifies a span of highlighted text.
+type TokenKind int
+
+const (
+	TokenNone TokenKind = iota
+	TokenKeyword
+	TokenComment
+	TokenString
+	TokenNumber
+	TokenPreproc
+	TokenVariable
+	TokenHeading
+	TokenEmphasis
+	TokenCode
+	TokenInserted
+	TokenDeleted
+	TokenHunk
+	TokenTrailing
+	TokenMatch
+	NumTokenKinds
+)
+
+var tokennames = [NumTokenKinds]string{
+	"none",
+	"keyword",
+	"comment",
+	"string",
+	"number",
+	"preproc",
+	"variable",
+	"heading",
+	"emphasis",
+	"code",
+	"inserted",
+	"deleted",
+	"hunk",
+	"trailing",
+	"match",
+}
+
+func (k TokenKind) String() string {
+	if k < 0 || k >= NumTokenKinds {
+		return fmt.Sprintf("TokenKind(%d)", int(k))
+	}
+	return tokennames[k]
+}
+
+// parseTokenKind returns the TokenKind called s.
+func parseTokenKind(s string) (TokenKind, error) {
+	for k, n := range tokennames {
+		if n == s {
+			return TokenKind(k), nil
+		}
+	}
+	return TokenNone, fmt.Errorf("unknown token kind %q", s)
+}
+
+// tokencolours are the text colours of each kind of token. Trailing
+// white space and matching brackets are shown by their background
+// colour instead.
+var tokencolours = [NumTokenKinds]draw.Color{
+	TokenKeyword:  0x000099FF,
+	TokenComment:  0x006600FF,
+	TokenString:   0x990000FF,
+	TokenNumber:   0x990099FF,
+	TokenPreproc:  0x996600FF,
+	TokenVariable: 0x006699FF,
+	TokenHeading:  0x000099FF,
+	TokenEmphasis: 0x660066FF,
+	TokenCode:     0x666666FF,
+	TokenInserted: 0x006600FF,
+	TokenDeleted:  0x990000FF,
+	TokenHunk:     0x660099FF,
+	TokenTrailing: 0xFFAAAAFF,
+	TokenMatch:    0x9EEEEEFF,
+}
+
+// hlstyles holds the frame style used to draw each kind of token.
+var hlstyles [NumTokenKinds]*frame.Style
+
+// hlstylesinit allocates the styles used to draw highlighted text in
+// colours.
+func hlstylesinit(display draw.Display, colours [NumTokenKinds]draw.Color) {
+	for k := TokenKeyword; k < NumTokenKinds; k++ {
+		c, _ := display.AllocImage(image.Rect(0, 0, 1, 1), display.ScreenImage().Pix(), true, colours[k])
+		if k == TokenTrailing || k == TokenMatch {
+			hlstyles[k] = &frame.Style{Bg: c}
+			continue
+		}
+		hlstyles[k] = &frame.Style{
+			Fg:        c,
+			Underline: k == TokenHeading,
+		}
+	}
+}
+
+// Span is a run [Q0, Q1) of a File's text that should be highlighted
+// as a token of kind Kind.
+type Span struct {
+	Q0, Q1 int
+	Kind   TokenKind
+}
+
+// Highlighter computes the highlighting of a File's text. It is told
+// about every edit to the File so that it can keep its state up to
+// date without examining the whole File again.
+type Highlighter interface {
+	// Inserted is called after n runes are inserted at q0.
+	Inserted(q0, n int)
+
+	// Deleted is called after runes [q0, q1) are deleted.
+	Deleted(q0, q1 int)
+
+	// Spans returns the sorted, non-overlapping spans that intersect
+	// [q0, q1), clipped to [q0, q1).
+	Spans(q0, q1 int) []Span
+}
+
+// runeSource is the text that a lexHighlighter highlights.
+type runeSource interface {
+	ReadC(q int) rune
+	Size() int
+}
+
+// lexer splits the lines of a kind of file into tokens.
+type lexer interface {
+	// lexLine calls emit for each token in line, which includes its
+	// newline if it has one, given state, the state at the start of
+	// the line. It returns the state at the start of the next line.
+	// State 0 is the state at the start of a file.
+	lexLine(line []rune, state int, emit func(p0, p1 int, k TokenKind)) int
+}
+
+// maxlexline is the length at which a line is split in two for
+// lexing, to bound the work done for each edit.
+const maxlexline = 1024
+
+// linestate records the lexer state at the start of line of text at q
+// and the spans found when the line was last lexed, with positions
+// relative to q so that they stay right as earlier lines are edited.
+// If dirty is set, the line has changed since it was last lexed and so
+// its spans and the state of the following line may be wrong.
+type linestate struct {
+	q     int
+	state int
+	dirty bool
+	spans []Span
+}
+
+// lexHighlighter is a Highlighter that uses a lexer. It caches the
+// lexer state at the start of each line and the line's spans so that
+// after an edit only the lines from the edit until the state converges
+// with the cached state are lexed again. Lexing is deferred until
+// spans are asked for and then only goes as far as the end of the
+// spans.
+type lexHighlighter struct {
+	src   runeSource
+	lx    lexer
+	lines []linestate // sorted by q; lines[0].q is always 0.
+
+	nlexed int // count of lines lexed (for testing)
+}
+
+func newLexHighlighter(src runeSource, lx lexer) *lexHighlighter {
+	return &lexHighlighter{
+		src:   src,
+		lx:    lx,
+		lines: []linestate{{q: 0, dirty: true}},
+	}
+}
+
+// lineat returns the index of the last cached line starting at or before q.
+func (h *lexHighlighter) lineat(q int) int {
+	return sort.Search(len(h.lines), func(i int) bool { return h.lines[i].q > q }) - 1
+}
+
+func (h *lexHighlighter) Inserted(q0, n int) {
+	i := h.lineat(q0)
+	h.lines[i].dirty = true
+	for j := i + 1; j < len(h.lines); j++ {
+		h.lines[j].q += n
+	}
+}
+
+func (h *lexHighlighter) Deleted(q0, q1 int) {
+	i := h.lineat(q0)
+	h.lines[i].dirty = true
+	j := i + 1
+	for j < len(h.lines) && h.lines[j].q <= q1 {
+		j++
+	}
+	h.lines = append(h.lines[:i+1], h.lines[j:]...)
+	for j := i + 1; j < len(h.lines); j++ {
+		h.lines[j].q -= q1 - q0
+	}
+}
+
+// readline returns the text of the line starting at q.
+func (h *lexHighlighter) readline(q int) []rune {
+	var line []rune
+	for n := h.src.Size(); q < n && len(line) < maxlexline; q++ {
+		c := h.src.ReadC(q)
+		line = append(line, c)
+		if c == '\n' {
+			break
+		}
+	}
+	return line
+}
+
+// relex lexes the dirty lines starting before q until the state and
+// spans of every line starting before q are known.
+func (h *lexHighlighter) relex(q int) {
+	size := h.src.Size()
+	for i := 0; i < len(h.lines) && h.lines[i].q < q; i++ {
+		l := &h.lines[i]
+		if !l.dirty {
+			continue
+		}
+		l.dirty = false
+		l.spans = nil
+		if l.q >= size {
+			h.lines = h.lines[:i+1]
+			break
+		}
+		line := h.readline(l.q)
+		state := h.lx.lexLine(line, l.state, func(p0, p1 int, k TokenKind) {
+			l.spans = append(l.spans, Span{Q0: p0, Q1: p1, Kind: k})
+		})
+		h.nlexed++
+		next := l.q + len(line)
+		if next == size && line[len(line)-1] != '\n' {
+			h.lines = h.lines[:i+1]
+			break
+		}
+
+		// Drop the cached lines that no longer start a line.
+		j := i + 1
+		for j < len(h.lines) && h.lines[j].q < next {
+			j++
+		}
+		h.lines = append(h.lines[:i+1], h.lines[j:]...)
+
+		switch {
+		case i+1 == len(h.lines) || h.lines[i+1].q > next:
+			h.lines = append(h.lines, linestate{})
+			copy(h.lines[i+2:], h.lines[i+1:])
+			h.lines[i+1] = linestate{q: next, state: state, dirty: true}
+		case h.lines[i+1].state != state:
+			h.lines[i+1].state = state
+			h.lines[i+1].dirty = true
+		}
+	}
+}
+
+func (h *lexHighlighter) Spans(q0, q1 int) []Span {
+	if size := h.src.Size(); q1 > size {
+		q1 = size
+	}
+	if q0 >= q1 {
+		return nil
+	}
+	h.relex(q1)
+
+	var spans []Span
+	for i := h.lineat(q0); i < len(h.lines) && h.lines[i].q < q1; i++ {
+		l := &h.lines[i]
+		for _, s := range l.spans {
+			s.Q0 += l.q
+			s.Q1 += l.q
+			if s.Q0 < q0 {
+				s.Q0 = q0
+			}
+			if s.Q1 > q1 {
+				s.Q1 = q1
+			}
+			if s.Q0 < s.Q1 {
+				spans = append(spans, s)
+			}
+		}
+	}
+	return spans
+}
+
+// spanList is a Highlighter holding spans supplied from outside of
+// Edwood (e.g. written to a window's spans file). The spans are moved
+// to follow edits to the File.
+type spanList struct {
+	spans []Span // sorted and non-overlapping
+}
+
+func (l *spanList) Inserted(q0, n int) {
+	for i := range l.spans {
+		s := &l.spans[i]
+		if s.Q0 >= q0 {
+			s.Q0 += n
+		}
+		if s.Q1 > q0 {
+			s.Q1 += n
+		}
+	}
+}
+
+func (l *spanList) Deleted(q0, q1 int) {
+	adjust := func(q int) int {
+		switch {
+		case q <= q0:
+			return q
+		case q <= q1:
+			return q0
+		}
+		return q - (q1 - q0)
+	}
+	spans := l.spans[:0]
+	for _, s := range l.spans {
+		s.Q0, s.Q1 = adjust(s.Q0), adjust(s.Q1)
+		if s.Q0 < s.Q1 {
+			spans = append(spans, s)
+		}
+	}
+	l.spans = spans
+}
+
+// Add adds span s, replacing any parts of existing spans that it overlaps.
+func (l *spanList) Add(s Span) {
+	var spans []Span
+	for _, t := range l.spans {
+		if t.Q1 <= s.Q0 || t.Q0 >= s.Q1 {
+			spans = append(spans, t)
+			continue
+		}
+		if t.Q0 < s.Q0 {
+			spans = append(spans, Span{t.Q0, s.Q0, t.Kind})
+		}
+		if t.Q1 > s.Q1 {
+			spans = append(spans, Span{s.Q1, t.Q1, t.Kind})
+		}
+	}
+	if s.Q0 < s.Q1 && s.Kind != TokenNone {
+		spans = append(spans, s)
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Q0 < spans[j].Q0 })
+	l.spans = spans
+}
+
+func (l *spanList) Spans(q0, q1 int) []Span {
+	i := sort.Search(len(l.spans), func(i int) bool { return l.spans[i].Q1 > q0 })
+	var spans []Span
+	for ; i < len(l.spans) && l.spans[i].Q0 < q1; i++ {
+		s := l.spans[i]
+		if s.Q0 < q0 {
+			s.Q0 = q0
+		}
+		if s.Q1 > q1 {
+			s.Q1 = q1
+		}
+		spans = append(spans, s)
+	}
+	return spans
+}
+
+// overlayspans returns spans, which must be sorted and not overlap,
+// with the spans of over added on top of them.
+func overlayspans(spans, over []Span) []Span {
+	if len(over) == 0 {
+		return spans
+	}
+	l := &spanList{spans: spans}
+	for _, s := range over {
+		l.Add(s)
+	}
+	return l.spans
+}
+
+// lexerfor returns the lexer for a file called name or nil if there isn't one.
+func lexerfor(name string) lexer {
+	if name == "" || strings.HasSuffix(name, "/") {
+		return nil
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".go":
+		return golexer
+	case ".c", ".h":
+		return clexer
+	case ".sh", ".bash", ".ksh", ".zsh":
+		return shlexer
+	case ".md", ".markdown":
+		return markdownlexer{}
+	case ".diff", ".patch":
+		return difflexer{}
+	}
+	return nil
+}
+
+// highlightinserted tells the File's highlighters about the insertion
+// of n runes at q0.
+func (f *File) highlightinserted(q0, n int) {
+	if f.highlighter != nil {
+		f.highlighter.Inserted(q0, n)
+	}
+	if f.extspans != nil {
+		f.extspans.Inserted(q0, n)
+	}
+}
+
+// highlightdeleted tells the File's highlighters about the deletion of
+// runes [q0, q1).
+func (f *File) highlightdeleted(q0, q1 int) {
+	if f.highlighter != nil {
+		f.highlighter.Deleted(q0, q1)
+	}
+	if f.extspans != nil {
+		f.extspans.Deleted(q0, q1)
+	}
+}
+
+// spans returns the highlighted spans of the File in [q0, q1). Spans
+// supplied from outside of Edwood replace those of the built-in
+// highlighter.
+func (f *File) spans(q0, q1 int) []Span {
+	switch {
+	case f.extspans != nil:
+		return f.extspans.Spans(q0, q1)
+	case f.highlighter != nil:
+		return f.highlighter.Spans(q0, q1)
+	}
+	return nil
+}
+
+// highlight styles the visible text of a window body with the spans
+// of its File.
+func (t *Text) highlight() {
+	if t.fr == nil || t.what != Body || t.w == nil {
+		return
+	}
+	n := t.fr.GetFrameFillStatus().Nchars
+	var spans []Span
+	if !t.w.nohighlight {
+		spans = t.file.spans(t.org, t.org+n)
+	}
+	if t.w.trailing {
+		spans = overlayspans(spans, t.trailingspans(t.org, t.org+n))
+	}
+	if len(t.match) > 0 {
+		spans = overlayspans(spans, (&spanList{spans: t.match}).Spans(t.org, t.org+n))
+	}
+	if len(spans) == 0 && !t.highlighted {
+		return
+	}
+	t.highlighted = len(spans) > 0
+
+	p := 0
+	for _, s := range spans {
+		p0, p1 := s.Q0-t.org, s.Q1-t.org
+		if p0 > p {
+			t.fr.SetStyle(p, p0, nil)
+		}
+		t.fr.SetStyle(p0, p1, hlstyles[s.Kind])
+		p = p1
+	}
+	if p < n {
+		t.fr.SetStyle(p, n, nil)
+	}
+}
+
+// sethighlighter selects the built-in highlighter for the window's
+// body from its file name.
+func (w *Window) sethighlighter() {
+	f := w.body.file
+	lx := lexerfor(f.name)
+	if h, ok := f.highlighter.(*lexHighlighter); ok && h.lx == lx {
+		return
+	}
+	if lx == nil {
+		f.highlighter = nil
+	} else {
+		f.highlighter = newLexHighlighter(f, lx)
+	}
+	f.AllText(func(t *Text) { t.highlight() })
+}
+
+// spansbytes generates the contents of a window's spans file.
+func spansbytes(w *Window) []byte {
+	w.body.Commit()
+	var sb strings.Builder
+	for _, s := range w.body.file.spans(0, w.body.file.Size()) {
+		fmt.Fprintf(&sb, "%d %d %v\n", s.Q0, s.Q1, s.Kind)
+	}
+	return []byte(sb.String())
+}
+
+// spanswrite processes the lines written to the spans file of window
+// w. A line "q0 q1 kind" highlights runes [q0, q1) as kind, replacing
+// the built-in highlighting of the window's file; kind "none" removes
+// highlighting from the runes. A line "clear" removes every span
+// written and restores the built-in highlighting.
+func spanswrite(w *Window, data string) error {
+	w.Commit(&w.body)
+	f := w.body.file
+	defer f.AllText(func(t *Text) { t.highlight() })
+
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case len(fields) == 1 && fields[0] == "clear":
+			f.extspans = nil
+			continue
+		case len(fields) != 3:
+			return fmt.Errorf("bad span %q", line)
+		}
+		q0, err0 := strconv.Atoi(fields[0])
+		q1, err1 := strconv.Atoi(fields[1])
+		if err0 != nil || err1 != nil || q0 < 0 || q0 > q1 {
+			return fmt.Errorf("bad span %q", line)
+		}
+		if q1 > f.Size() {
+			return ErrAddrRange
+		}
+		k, err := parseTokenKind(fields[2])
+		if err != nil {
+			return err
+		}
+		if f.extspans == nil {
+			f.extspans = new(spanList)
+		}
+		f.extspans.Add(Span{Q0: q0, Q1: q1, Kind: k})
+	}
+	return nil
+}
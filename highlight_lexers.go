@@ -0,0 +1,272 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/rjkroege/edwood/internal/runes"
+)
+
+// States of a codeLexer at the start of a line.
+const (
+	lexNormal = iota
+	lexBlockComment
+	lexRawString
+)
+
+// codeLexer is a lexer for programming languages in the C tradition,
+// configured with the syntax of a particular language.
+type codeLexer struct {
+	keywords     map[string]bool
+	lineComment  string    // starts a comment ending at the end of the line
+	wordComment  bool      // lineComment must start a word
+	blockComment [2]string // start and end of a comment that can span lines
+	quotes       string    // each starts a string ending on the same line
+	rawQuote     rune      // starts a string, without escapes, that can span lines
+	preproc      bool      // lines starting with # are preprocessor directives
+	variables    bool      // $name, ${name} and $1 are variables
+}
+
+func keywords(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, k := range strings.Fields(s) {
+		m[k] = true
+	}
+	return m
+}
+
+var (
+	golexer = &codeLexer{
+		keywords: keywords(`break case chan const continue default defer else
+			fallthrough for func go goto if import interface map package range
+			return select struct switch type var`),
+		lineComment:  "//",
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+		rawQuote:     '`',
+	}
+	clexer = &codeLexer{
+		keywords: keywords(`auto break case char const continue default do
+			double else enum extern float for goto if inline int long register
+			restrict return short signed sizeof static struct switch typedef
+			union unsigned void volatile while`),
+		lineComment:  "//",
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+		preproc:      true,
+	}
+	shlexer = &codeLexer{
+		keywords: keywords(`if then else elif fi case esac for while until do
+			done in function select time return exit break continue local
+			export readonly`),
+		lineComment: "#",
+		wordComment: true,
+		quotes:      `"'`,
+		variables:   true,
+	}
+)
+
+func isidentrune(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// linelen returns the length of line without its newline.
+func linelen(line []rune) int {
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		return n - 1
+	}
+	return len(line)
+}
+
+func (l *codeLexer) lexLine(line []rune, state int, emit func(p0, p1 int, k TokenKind)) int {
+	n := linelen(line)
+	for i := 0; i < n; {
+		switch state {
+		case lexBlockComment:
+			end := []rune(l.blockComment[1])
+			j := runes.Index(line[i:n], end)
+			if j < 0 {
+				emit(i, n, TokenComment)
+				return state
+			}
+			emit(i, i+j+len(end), TokenComment)
+			i += j + len(end)
+			state = lexNormal
+			continue
+		case lexRawString:
+			j := runes.IndexRune(line[i:n], l.rawQuote)
+			if j < 0 {
+				emit(i, n, TokenString)
+				return state
+			}
+			emit(i, i+j+1, TokenString)
+			i += j + 1
+			state = lexNormal
+			continue
+		}
+
+		c := line[i]
+		switch {
+		case l.preproc && c == '#' && len(runes.TrimLeft(line[:i], " \t")) == 0:
+			emit(i, n, TokenPreproc)
+			return state
+
+		case l.lineComment != "" && runes.HasPrefix(line[i:n], []rune(l.lineComment)) &&
+			(!l.wordComment || i == 0 || strings.ContainsRune(" \t;|&(", line[i-1])):
+			emit(i, n, TokenComment)
+			return state
+
+		case l.blockComment[0] != "" && runes.HasPrefix(line[i:n], []rune(l.blockComment[0])):
+			j := i + len([]rune(l.blockComment[0]))
+			emit(i, j, TokenComment)
+			i = j
+			state = lexBlockComment
+
+		case l.rawQuote != 0 && c == l.rawQuote:
+			emit(i, i+1, TokenString)
+			i++
+			state = lexRawString
+
+		case strings.ContainsRune(l.quotes, c):
+			j := i + 1
+			for j < n && line[j] != c {
+				if line[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j < n {
+				j++
+			}
+			if j > n {
+				j = n
+			}
+			emit(i, j, TokenString)
+			i = j
+
+		case l.variables && c == '$':
+			j := i + 1
+			switch {
+			case j < n && line[j] == '{':
+				if k := runes.IndexRune(line[j:n], '}'); k >= 0 {
+					j += k + 1
+				}
+			case j < n && strings.ContainsRune("?!#$*@-0123456789", line[j]):
+				j++
+			default:
+				for j < n && isidentrune(line[j]) {
+					j++
+				}
+			}
+			if j > i+1 {
+				emit(i, j, TokenVariable)
+			}
+			i = j
+
+		case unicode.IsDigit(c):
+			j := i + 1
+			for j < n && (isidentrune(line[j]) || line[j] == '.') {
+				j++
+			}
+			emit(i, j, TokenNumber)
+			i = j
+
+		case isidentrune(c):
+			j := i + 1
+			for j < n && isidentrune(line[j]) {
+				j++
+			}
+			if l.keywords[string(line[i:j])] {
+				emit(i, j, TokenKeyword)
+			}
+			i = j
+
+		default:
+			i++
+		}
+	}
+	return state
+}
+
+// States of a markdownlexer at the start of a line.
+const (
+	mdNormal = iota
+	mdFence
+)
+
+// markdownlexer is a lexer for Markdown. It highlights headings, code
+// and emphasis.
+type markdownlexer struct{}
+
+func (markdownlexer) lexLine(line []rune, state int, emit func(p0, p1 int, k TokenKind)) int {
+	n := linelen(line)
+	text := runes.TrimLeft(line[:n], " \t")
+	switch {
+	case runes.HasPrefix(text, []rune("```")) || runes.HasPrefix(text, []rune("~~~")):
+		emit(0, n, TokenCode)
+		if state == mdFence {
+			return mdNormal
+		}
+		return mdFence
+	case state == mdFence:
+		emit(0, n, TokenCode)
+		return state
+	case n > 0 && line[0] == '#':
+		emit(0, n, TokenHeading)
+		return state
+	}
+
+	for i := 0; i < n; {
+		c := line[i]
+		switch c {
+		case '`':
+			if j := runes.IndexRune(line[i+1:n], '`'); j >= 0 {
+				emit(i, i+j+2, TokenCode)
+				i += j + 2
+				continue
+			}
+		case '*', '_':
+			delim := line[i : i+1]
+			if i+1 < n && line[i+1] == c {
+				delim = line[i : i+2]
+			}
+			start := i + len(delim)
+			if start < n && line[start] != ' ' {
+				if j := runes.Index(line[start:n], delim); j > 0 {
+					end := start + j + len(delim)
+					emit(i, end, TokenEmphasis)
+					i = end
+					continue
+				}
+			}
+			i = start
+			continue
+		}
+		i++
+	}
+	return state
+}
+
+// difflexer is a lexer for the output of diff -u.
+type difflexer struct{}
+
+func (difflexer) lexLine(line []rune, state int, emit func(p0, p1 int, k TokenKind)) int {
+	n := linelen(line)
+	for _, p := range []struct {
+		prefix string
+		kind   TokenKind
+	}{
+		{"+++", TokenHeading},
+		{"---", TokenHeading},
+		{"diff ", TokenHeading},
+		{"@@", TokenHunk},
+		{"+", TokenInserted},
+		{"-", TokenDeleted},
+	} {
+		if runes.HasPrefix(line[:n], []rune(p.prefix)) {
+			emit(0, n, p.kind)
+			break
+		}
+	}
+	return state
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// runeText is a runeSource for testing.
+type runeText []rune
+
+func (r *runeText) ReadC(q int) rune { return (*r)[q] }
+func (r *runeText) Size() int        { return len(*r) }
+
+func (r *runeText) insert(h Highlighter, q0 int, s string) {
+	t := append([]rune{}, (*r)[:q0]...)
+	t = append(t, []rune(s)...)
+	*r = append(t, (*r)[q0:]...)
+	h.Inserted(q0, len([]rune(s)))
+}
+
+func (r *runeText) delete(h Highlighter, q0, q1 int) {
+	*r = append((*r)[:q0], (*r)[q1:]...)
+	h.Deleted(q0, q1)
+}
+
+// tokens returns the spans of text as "kind:text" strings.
+func tokens(text runeText, spans []Span) []string {
+	var toks []string
+	for _, s := range spans {
+		toks = append(toks, fmt.Sprintf("%v:%v", s.Kind, string(text[s.Q0:s.Q1])))
+	}
+	return toks
+}
+
+func TestLexers(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "x.go",
+			text: "package main // hi\nvar s = \"a\\\"b\" + 'c' + 42\n/* one\ntwo */ return `raw\nstring` x1",
+			want: []string{
+				"keyword:package", "comment:// hi",
+				"keyword:var", `string:"a\"b"`, "string:'c'", "number:42",
+				"comment:/*", "comment: one", "comment:two */", "keyword:return",
+				"string:`", "string:raw", "string:string`",
+			},
+		},
+		{
+			name: "x.c",
+			text: "  #include <stdio.h>\nint x = 0x1f; // c\n",
+			want: []string{"preproc:#include <stdio.h>", "keyword:int", "number:0x1f", "comment:// c"},
+		},
+		{
+			name: "x.sh",
+			text: "for f in $x ${y} $1; do echo a#b # c\ndone\n",
+			want: []string{
+				"keyword:for", "keyword:in", "variable:$x", "variable:${y}", "variable:$1",
+				"keyword:do", "comment:# c", "keyword:done",
+			},
+		},
+		{
+			name: "README.md",
+			text: "# Title\nSome `code` and *emph* and **strong** a * b\n```\n# not a heading\n```\n",
+			want: []string{
+				"heading:# Title", "code:`code`", "emphasis:*emph*", "emphasis:**strong**",
+				"code:```", "code:# not a heading", "code:```",
+			},
+		},
+		{
+			name: "x.diff",
+			text: "diff a b\n--- a\n+++ b\n@@ -1 +1 @@\n-old\n+new\n same\n",
+			want: []string{
+				"heading:diff a b", "heading:--- a", "heading:+++ b", "hunk:@@ -1 +1 @@",
+				"deleted:-old", "inserted:+new",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			text := runeText(tc.text)
+			h := newLexHighlighter(&text, lexerfor(tc.name))
+			if diff := cmp.Diff(tc.want, tokens(text, h.Spans(0, len(text)))); diff != "" {
+				t.Errorf("tokens mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLexerFor(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want lexer
+	}{
+		{"/a/b.go", golexer},
+		{"/a/B.H", clexer},
+		{"/a/b.sh", shlexer},
+		{"/a/b.md", markdownlexer{}},
+		{"/a/b.patch", difflexer{}},
+		{"/a/b.txt", nil},
+		{"/a/b.go/", nil},
+		{"", nil},
+	} {
+		if got := lexerfor(tc.name); got != tc.want {
+			t.Errorf("lexerfor(%q) is %v; want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestLexHighlighterIncremental(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&sb, "x := %d // line %d\n", i, i)
+	}
+	text := runeText(sb.String())
+	h := newLexHighlighter(&text, golexer)
+	end := func() []string {
+		return tokens(text, h.Spans(len(text)-20, len(text)))
+	}
+
+	h.Spans(0, 10)
+	if got, want := h.nlexed, 1; got != want {
+		t.Errorf("lexed %v lines to highlight the first line; want %v", got, want)
+	}
+	want := []string{"number:99", "comment:// line 99"}
+	if diff := cmp.Diff(want, end()); diff != "" {
+		t.Errorf("tokens mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := h.nlexed, 100; got != want {
+		t.Errorf("lexed %v lines to highlight the last line; want %v", got, want)
+	}
+
+	// Spans of lines already lexed are reused.
+	h.nlexed = 0
+	h.Spans(0, len(text))
+	if got, want := h.nlexed, 0; got != want {
+		t.Errorf("lexed %v lines to highlight them again; want %v", got, want)
+	}
+
+	// Only the edited line is lexed again.
+	q := strings.Index(string(text), "x := 50")
+	text.insert(h, q, "y")
+	h.nlexed = 0
+	if diff := cmp.Diff([]string{"number:50", "comment:// line 50"}, tokens(text, h.Spans(q, q+20))); diff != "" {
+		t.Errorf("tokens of edited line mismatch (-want +got):\n%s", diff)
+	}
+	end()
+	if got, want := h.nlexed, 1; got != want {
+		t.Errorf("lexed %v lines after an edit; want %v", got, want)
+	}
+
+	// A change of state is carried through the following lines.
+	text.insert(h, q, "/*")
+	if diff := cmp.Diff([]string{"comment:x := 99 // line 99"}, end()); diff != "" {
+		t.Errorf("tokens mismatch (-want +got):\n%s", diff)
+	}
+	text.delete(h, q, q+2)
+	h.nlexed = 0
+	if diff := cmp.Diff(want, end()); diff != "" {
+		t.Errorf("tokens mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := h.nlexed, 50; got != want {
+		t.Errorf("lexed %v lines after closing comment; want %v", got, want)
+	}
+}
+
+func TestLexHighlighterRandomEdits(t *testing.T) {
+	frags := []string{"/*", "*/", "`", "\n", "x", "// c", "\"s\"", "func"}
+	rnd := rand.New(rand.NewSource(1))
+	text := runeText(strings.Repeat("a /* b\nfunc `c\n*/ d\n", 10))
+	h := newLexHighlighter(&text, golexer)
+	for i := 0; i < 500; i++ {
+		q0 := rnd.Intn(len(text) + 1)
+		if rnd.Intn(2) == 0 && q0 < len(text) {
+			text.delete(h, q0, q0+rnd.Intn(min(len(text)-q0, 10)+1))
+		} else {
+			text.insert(h, q0, frags[rnd.Intn(len(frags))])
+		}
+		if i%3 == 0 {
+			continue
+		}
+		q0 = rnd.Intn(len(text) + 1)
+		q1 := q0 + rnd.Intn(len(text)-q0+1)
+		want := newLexHighlighter(&text, golexer).Spans(q0, q1)
+		if diff := cmp.Diff(want, h.Spans(q0, q1)); diff != "" {
+			t.Fatalf("edit %v: spans of %q [%v, %v) mismatch (-want +got):\n%s", i, string(text), q0, q1, diff)
+		}
+	}
+}
+
+func TestSpanList(t *testing.T) {
+	var l spanList
+	l.Add(Span{0, 10, TokenComment})
+	l.Add(Span{4, 6, TokenKeyword})
+	l.Add(Span{20, 30, TokenString})
+	want := []Span{{0, 4, TokenComment}, {4, 6, TokenKeyword}, {6, 10, TokenComment}, {20, 30, TokenString}}
+	if diff := cmp.Diff(want, l.Spans(0, 100)); diff != "" {
+		t.Errorf("spans mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]Span{{5, 6, TokenKeyword}, {6, 8, TokenComment}}, l.Spans(5, 8)); diff != "" {
+		t.Errorf("clipped spans mismatch (-want +got):\n%s", diff)
+	}
+
+	l.Add(Span{2, 8, TokenNone})
+	l.Inserted(25, 5)
+	l.Inserted(20, 1)
+	l.Deleted(1, 9)
+	want = []Span{{0, 1, TokenComment}, {1, 2, TokenComment}, {13, 28, TokenString}}
+	if diff := cmp.Diff(want, l.Spans(0, 100)); diff != "" {
+		t.Errorf("spans after edits mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWindowHighlight(t *testing.T) {
+	w := NewWindow().initHeadless(nil)
+	w.body.what = Body
+	w.body.fr = &MockFrame{}
+	w.body.file.SetName("/a/b.go")
+	w.sethighlighter()
+	if _, ok := w.body.file.highlighter.(*lexHighlighter); !ok {
+		t.Fatalf("no highlighter for Go file: %v", w.body.file.highlighter)
+	}
+	w.body.file.InsertAt(0, []rune("package main\n"))
+	if got, want := w.body.file.spans(0, 100), []Span{{0, 7, TokenKeyword}}; !cmp.Equal(got, want) {
+		t.Errorf("spans are %v; want %v", got, want)
+	}
+
+	if err := spanswrite(w, "8 12 string\n"); err != nil {
+		t.Fatalf("spanswrite failed: %v", err)
+	}
+	if got, want := string(spansbytes(w)), "8 12 string\n"; got != want {
+		t.Errorf("spans file is %q; want %q", got, want)
+	}
+	w.body.file.InsertAt(0, []rune("//"))
+	if got, want := string(spansbytes(w)), "10 14 string\n"; got != want {
+		t.Errorf("spans file after insert is %q; want %q", got, want)
+	}
+	for _, s := range []string{"1 2", "2 1 string", "1 2 purple", "1 100 string"} {
+		if err := spanswrite(w, s); err == nil {
+			t.Errorf("spanswrite of %q succeeded", s)
+		}
+	}
+	if err := spanswrite(w, "clear\n"); err != nil {
+		t.Fatalf("spanswrite failed: %v", err)
+	}
+	if got, want := string(spansbytes(w)), "0 14 comment\n"; got != want {
+		t.Errorf("spans file after clear is %q; want %q", got, want)
+	}
+
+	w.body.file.SetName("/a/b.txt")
+	w.sethighlighter()
+	if w.body.file.highlighter != nil {
+		t.Errorf("highlighter for text file: %v", w.body.file.highlighter)
+	}
+}
@@ -0,0 +1,579 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"9fans.net/go/plan9"
+	"9fans.net/go/plan9/client"
+)
+
+// httpAPI maps REST/JSON requests onto the 9P file system. It is a 9P
+// client of an in-process fileServer, so every request is handled by
+// the same xfid code (and has the same behaviour) as it would be for
+// any other client of the file system.
+//
+// The API is:
+//
+//	GET  /windows                  list windows (from acme/index)
+//	POST /windows                  create a window
+//	GET  /windows/<id>             describe window <id>
+//	GET  /windows/<id>/body        read the body; ?addr=<address> reads only the address
+//	PUT  /windows/<id>/body        replace the body; ?addr=<address> replaces only the address
+//	POST /windows/<id>/body        append to the body
+//	GET  /windows/<id>/tag         read the tag
+//	POST /windows/<id>/tag         append to the tag
+//	GET  /windows/<id>/ctl         read the ctl file
+//	POST /windows/<id>/ctl         write control messages
+//	GET  /windows/<id>/events      stream events as server-sent events
+//	POST /windows/<id>/events      write an event back (e.g. to execute it)
+//
+// Text is sent and received as a JSON httpText object.
+//
+// Since the API can be used to run commands, a request must present
+// the token, if not nil, in an "Authorization: Bearer" header. Without
+// a token, only requests from a loopback address made by something
+// other than a web page (which would send an Origin header) are
+// served.
+type httpAPI struct {
+	conn  *client.Conn
+	fsys  *client.Fsys
+	token []byte
+	xfids *xfidAllocator // for the in-process file servers
+	wg    sync.WaitGroup // running in-process file servers
+}
+
+// httpText is the JSON representation of text read or written through the API.
+// Q0 and Q1 give the address of the text within the body, where applicable.
+type httpText struct {
+	Text string `json:"text"`
+	Q0   *int   `json:"q0,omitempty"`
+	Q1   *int   `json:"q1,omitempty"`
+}
+
+// httpWindow is the JSON representation of an entry in acme/index.
+type httpWindow struct {
+	ID         int    `json:"id"`
+	TagLength  int    `json:"taglength"`
+	BodyLength int    `json:"bodylength"`
+	IsDir      bool   `json:"isdir"`
+	Dirty      bool   `json:"dirty"`
+	Tag        string `json:"tag"`
+}
+
+// httpEvent is the JSON representation of a message in a window's event file.
+type httpEvent struct {
+	Origin string `json:"origin"` // e.g. "M" for mouse
+	Type   string `json:"type"`   // e.g. "x" for execute in the tag
+	Q0     int    `json:"q0"`
+	Q1     int    `json:"q1"`
+	Flag   int    `json:"flag"`
+	Text   string `json:"text"`
+}
+
+// httpError reports a failed request.
+type httpError struct {
+	Error string `json:"error"`
+}
+
+// newHTTPAPI returns an httpAPI connected to a new in-process file server,
+// which gets its Xfids from xa.
+func newHTTPAPI(xa *xfidAllocator) (*httpAPI, error) {
+	api := &httpAPI{xfids: xa}
+	conn, fsys, err := api.dial()
+	if err != nil {
+		return nil, err
+	}
+	api.conn = conn
+	api.fsys = fsys
+	return api, nil
+}
+
+// Close disconnects from the file server and waits until the file
+// servers of all connections, including those of event streams, have
+// released their fids.
+func (api *httpAPI) Close() {
+	api.conn.Close()
+	api.wg.Wait()
+}
+
+// dial connects and attaches to a new in-process file server.
+func (api *httpAPI) dial() (*client.Conn, *client.Fsys, error) {
+	c1, c2 := net.Pipe()
+	api.wg.Add(1)
+	go func() {
+		defer api.wg.Done()
+		serve9p(c2, nil, api.xfids)
+	}()
+	conn, err := client.NewConn(c1)
+	if err != nil {
+		c1.Close()
+		return nil, nil, err
+	}
+	fsys, err := conn.Attach(nil, getuser(), "")
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, fsys, nil
+}
+
+// serveHTTPAPI serves the HTTP/JSON API on address addr to clients
+// presenting token. If token is nil, addr must be a loopback address.
+func serveHTTPAPI(addr string, token []byte) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if ta, ok := l.Addr().(*net.TCPAddr); token == nil && (!ok || !ta.IP.IsLoopback()) {
+		l.Close()
+		return fmt.Errorf("HTTP API on non-loopback address %v requires -fsys.token", addr)
+	}
+	api, err := newHTTPAPI(xfids)
+	if err != nil {
+		l.Close()
+		return err
+	}
+	api.token = token
+	return http.Serve(l, api)
+}
+
+// authorized reports whether request r may be served (see httpAPI).
+func (api *httpAPI) authorized(r *http.Request) bool {
+	if api.token != nil {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			return false
+		}
+		return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), api.token) == 1
+	}
+	if r.Header.Get("Origin") != "" {
+		return false
+	}
+	// Guard against DNS rebinding: the client must have
+	// connected to a loopback address by that name.
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (api *httpAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !api.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		replyError(w, http.StatusUnauthorized, ErrPermission)
+		return
+	}
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if path[0] != "windows" {
+		replyError(w, http.StatusNotFound, ErrNotExist)
+		return
+	}
+	switch len(path) {
+	case 1:
+		switch r.Method {
+		case http.MethodGet:
+			api.listWindows(w)
+		case http.MethodPost:
+			api.newWindow(w)
+		default:
+			replyError(w, http.StatusMethodNotAllowed, fmt.Errorf("bad method %v", r.Method))
+		}
+		return
+	case 2, 3:
+	default:
+		replyError(w, http.StatusNotFound, ErrNotExist)
+		return
+	}
+
+	id, err := strconv.Atoi(path[1])
+	if err != nil {
+		replyError(w, http.StatusNotFound, ErrNotExist)
+		return
+	}
+	if len(path) == 2 {
+		if r.Method != http.MethodGet {
+			replyError(w, http.StatusMethodNotAllowed, fmt.Errorf("bad method %v", r.Method))
+			return
+		}
+		api.describeWindow(w, id)
+		return
+	}
+
+	switch file := path[2]; {
+	case file == "events" && r.Method == http.MethodGet:
+		api.streamEvents(w, r, id)
+	case file == "events" && r.Method == http.MethodPost:
+		var ev httpEvent
+		if !readJSON(w, r, &ev) {
+			return
+		}
+		if len(ev.Origin) != 1 || len(ev.Type) != 1 {
+			replyError(w, http.StatusBadRequest, ErrBadEvent)
+			return
+		}
+		msg := fmt.Sprintf("%s%s%d %d \n", ev.Origin, ev.Type, ev.Q0, ev.Q1)
+		api.write(w, id, "event", []byte(msg))
+	case file == "body" && r.Method == http.MethodGet:
+		if addr := r.URL.Query().Get("addr"); addr != "" {
+			api.readAddr(w, id, addr)
+			return
+		}
+		api.read(w, id, "body")
+	case file == "body" && r.Method == http.MethodPut:
+		var t httpText
+		if !readJSON(w, r, &t) {
+			return
+		}
+		addr := r.URL.Query().Get("addr")
+		if addr == "" {
+			addr = ","
+		}
+		api.writeAddr(w, id, addr, t.Text)
+	case (file == "body" || file == "tag" || file == "ctl") && r.Method == http.MethodPost:
+		var t httpText
+		if !readJSON(w, r, &t) {
+			return
+		}
+		api.write(w, id, file, []byte(t.Text))
+	case (file == "tag" || file == "ctl") && r.Method == http.MethodGet:
+		api.read(w, id, file)
+	case file == "body" || file == "tag" || file == "ctl" || file == "events":
+		replyError(w, http.StatusMethodNotAllowed, fmt.Errorf("bad method %v", r.Method))
+	default:
+		replyError(w, http.StatusNotFound, ErrNotExist)
+	}
+}
+
+// readindex returns the windows listed in acme/index.
+func (api *httpAPI) readindex() ([]httpWindow, error) {
+	b, err := api.readfile("index")
+	if err != nil {
+		return nil, err
+	}
+	wins := []httpWindow{}
+	for _, line := range strings.SplitAfter(string(b), "\n") {
+		if len(line) < Ctlsize {
+			continue
+		}
+		f := strings.Fields(line[:Ctlsize])
+		n := make([]int, len(f))
+		for i := range f {
+			n[i], _ = strconv.Atoi(f[i])
+		}
+		wins = append(wins, httpWindow{
+			ID:         n[0],
+			TagLength:  n[1],
+			BodyLength: n[2],
+			IsDir:      n[3] != 0,
+			Dirty:      n[4] != 0,
+			Tag:        strings.TrimSuffix(line[Ctlsize:], "\n"),
+		})
+	}
+	return wins, nil
+}
+
+func (api *httpAPI) listWindows(w http.ResponseWriter) {
+	wins, err := api.readindex()
+	if err != nil {
+		replyError(w, http.StatusInternalServerError, err)
+		return
+	}
+	replyJSON(w, http.StatusOK, wins)
+}
+
+func (api *httpAPI) describeWindow(w http.ResponseWriter, id int) {
+	wins, err := api.readindex()
+	if err != nil {
+		replyError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for _, win := range wins {
+		if win.ID == id {
+			replyJSON(w, http.StatusOK, win)
+			return
+		}
+	}
+	replyError(w, http.StatusNotFound, ErrNotExist)
+}
+
+func (api *httpAPI) newWindow(w http.ResponseWriter) {
+	fid, err := api.fsys.Open("new/ctl", plan9.OREAD)
+	if err != nil {
+		replyError(w, http.StatusInternalServerError, err)
+		return
+	}
+	b, err := ioutil.ReadAll(fid)
+	fid.Close()
+	if err != nil {
+		replyError(w, http.StatusInternalServerError, err)
+		return
+	}
+	id, _ := strconv.Atoi(strings.TrimSpace(string(b[:min(len(b), 12)])))
+	api.describeWindow(w, id)
+}
+
+// readfile returns the contents of file name.
+func (api *httpAPI) readfile(name string) ([]byte, error) {
+	fid, err := api.fsys.Open(name, plan9.OREAD)
+	if err != nil {
+		return nil, err
+	}
+	defer fid.Close()
+	return ioutil.ReadAll(fid)
+}
+
+func (api *httpAPI) read(w http.ResponseWriter, id int, file string) {
+	b, err := api.readfile(fmt.Sprintf("%d/%s", id, file))
+	if err != nil {
+		replyError(w, errorStatus(err), err)
+		return
+	}
+	replyJSON(w, http.StatusOK, httpText{Text: string(b)})
+}
+
+func (api *httpAPI) write(w http.ResponseWriter, id int, file string, b []byte) {
+	fid, err := api.fsys.Open(fmt.Sprintf("%d/%s", id, file), plan9.OWRITE)
+	if err != nil {
+		replyError(w, errorStatus(err), err)
+		return
+	}
+	defer fid.Close()
+	if _, err := fid.Write(b); err != nil {
+		replyError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// openaddr opens the addr file of window id and sets it to addr.
+// The addr file must be kept open while using the address.
+func (api *httpAPI) openaddr(id int, addr string) (*client.Fid, error) {
+	fid, err := api.fsys.Open(fmt.Sprintf("%d/addr", id), plan9.ORDWR)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fid.Write([]byte(addr)); err != nil {
+		fid.Close()
+		return nil, err
+	}
+	return fid, nil
+}
+
+// readaddrfile returns the address in the open addr file fid.
+func readaddrfile(fid *client.Fid) (q0, q1 int, err error) {
+	b := make([]byte, 2*12)
+	n, err := fid.ReadAt(b, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := fmt.Sscan(string(b[:n]), &q0, &q1); err != nil {
+		return 0, 0, err
+	}
+	return q0, q1, nil
+}
+
+func (api *httpAPI) readAddr(w http.ResponseWriter, id int, addr string) {
+	afid, err := api.openaddr(id, addr)
+	if err != nil {
+		replyError(w, errorStatus(err), err)
+		return
+	}
+	defer afid.Close()
+	q0, q1, err := readaddrfile(afid)
+	if err != nil {
+		replyError(w, http.StatusInternalServerError, err)
+		return
+	}
+	b, err := api.readfile(fmt.Sprintf("%d/xdata", id))
+	if err != nil {
+		replyError(w, errorStatus(err), err)
+		return
+	}
+	replyJSON(w, http.StatusOK, httpText{Text: string(b), Q0: &q0, Q1: &q1})
+}
+
+func (api *httpAPI) writeAddr(w http.ResponseWriter, id int, addr string, s string) {
+	afid, err := api.openaddr(id, addr)
+	if err != nil {
+		replyError(w, errorStatus(err), err)
+		return
+	}
+	defer afid.Close()
+	q0, _, err := readaddrfile(afid)
+	if err != nil {
+		replyError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	fid, err := api.fsys.Open(fmt.Sprintf("%d/data", id), plan9.OWRITE)
+	if err != nil {
+		replyError(w, errorStatus(err), err)
+		return
+	}
+	defer fid.Close()
+	// Writes to data don't handle runes split between writes,
+	// so write whole runes only.
+	first := true
+	for len(s) > 0 || first {
+		n := len(s)
+		if n > MaxBlock {
+			n = MaxBlock
+			for n > 0 && !utf8.RuneStart(s[n]) {
+				n--
+			}
+		}
+		if _, err := fid.Write([]byte(s[:n])); err != nil {
+			replyError(w, http.StatusBadRequest, err)
+			return
+		}
+		s = s[n:]
+		first = false
+	}
+	_, q1, err := readaddrfile(afid)
+	if err != nil {
+		replyError(w, http.StatusInternalServerError, err)
+		return
+	}
+	replyJSON(w, http.StatusOK, httpText{Q0: &q0, Q1: &q1})
+}
+
+// streamEvents sends the events of window id as server-sent events,
+// one JSON httpEvent per message, until the client goes away. While
+// the stream is open, Edwood treats the window as being controlled
+// by an external program, just as if the event file was opened.
+func (api *httpAPI) streamEvents(w http.ResponseWriter, r *http.Request, id int) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		replyError(w, http.StatusInternalServerError, fmt.Errorf("streaming unsupported"))
+		return
+	}
+	// Use a separate connection, which can be closed to
+	// abandon the blocked read of the event file.
+	conn, fsys, err := api.dial()
+	if err != nil {
+		replyError(w, http.StatusInternalServerError, err)
+		return
+	}
+	fid, err := fsys.Open(fmt.Sprintf("%d/event", id), plan9.OREAD)
+	if err != nil {
+		conn.Close()
+		replyError(w, errorStatus(err), err)
+		return
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+		conn.Close() // the file server releases the fids
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	rd := bufio.NewReader(fid)
+	for {
+		ev, err := readEvent(rd)
+		if err != nil {
+			return
+		}
+		b, _ := json.Marshal(ev)
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// readEvent reads one message in the format of a window's event file:
+// a character for the origin, a character for the type, four
+// blank-terminated decimal numbers, optional text and a newline.
+func readEvent(rd *bufio.Reader) (*httpEvent, error) {
+	var ev httpEvent
+	c, _, err := rd.ReadRune()
+	if err != nil {
+		return nil, err
+	}
+	ev.Origin = string(c)
+	c, _, err = rd.ReadRune()
+	if err != nil {
+		return nil, err
+	}
+	ev.Type = string(c)
+	var n [4]int
+	for i := range n {
+		s, err := rd.ReadString(' ')
+		if err != nil {
+			return nil, err
+		}
+		n[i], err = strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return nil, ErrBadEvent
+		}
+	}
+	ev.Q0, ev.Q1, ev.Flag = n[0], n[1], n[2]
+	var sb strings.Builder
+	for i := 0; i < n[3]; i++ {
+		c, _, err = rd.ReadRune()
+		if err != nil {
+			return nil, err
+		}
+		sb.WriteRune(c)
+	}
+	ev.Text = sb.String()
+	if c, _, err = rd.ReadRune(); err != nil || c != '\n' {
+		return nil, ErrBadEvent
+	}
+	return &ev, nil
+}
+
+// errorStatus returns the HTTP status code for the file server error err.
+func errorStatus(err error) int {
+	switch err.Error() {
+	case ErrNotExist.Error(), ErrDeletedWin.Error():
+		return http.StatusNotFound
+	case ErrInUse.Error():
+		return http.StatusConflict
+	case ErrBadAddr.Error(), ErrAddrRange.Error():
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<30)).Decode(v); err != nil {
+		replyError(w, http.StatusBadRequest, err)
+		return false
+	}
+	return true
+}
+
+func replyJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func replyError(w http.ResponseWriter, code int, err error) {
+	replyJSON(w, code, httpError{Error: err.Error()})
+}
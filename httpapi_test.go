@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// startHTTPAPI loads dump file testdata/name.dump and serves the HTTP/JSON API for it.
+func startHTTPAPI(t *testing.T, name string) (*httptest.Server, func()) {
+	t.Helper()
+
+	filename := editDumpFileForTesting(t, filepath.Join("testdata", name+".dump"))
+	defer os.Remove(filename)
+	setGlobalsForLoadTesting()
+	if err := row.Load(nil, filename, true); err != nil {
+		t.Fatalf("Row.Load failed: %v", err)
+	}
+
+	xa, stop := startXfidallocthread()
+	api, err := newHTTPAPI(xa)
+	if err != nil {
+		stop()
+		t.Fatalf("newHTTPAPI failed: %v", err)
+	}
+	ts := httptest.NewServer(api)
+	return ts, func() {
+		ts.Close()
+		api.Close()
+		stop()
+	}
+}
+
+func httpDo(t *testing.T, method, url string, req, resp interface{}) int {
+	t.Helper()
+
+	var body bytes.Buffer
+	if req != nil {
+		json.NewEncoder(&body).Encode(req)
+	}
+	r, err := http.NewRequest(method, url, &body)
+	if err != nil {
+		t.Fatalf("NewRequest failed: %v", err)
+	}
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatalf("%v %v failed: %v", method, url, err)
+	}
+	defer res.Body.Close()
+	if resp != nil && res.StatusCode < 300 {
+		if err := json.NewDecoder(res.Body).Decode(resp); err != nil {
+			t.Fatalf("%v %v: bad response: %v", method, url, err)
+		}
+	}
+	return res.StatusCode
+}
+
+func TestHTTPAPIAuthorized(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		token  string
+		host   string
+		header map[string]string
+		want   bool
+	}{
+		{"Loopback", "", "127.0.0.1:8080", nil, true},
+		{"Localhost", "", "localhost:8080", nil, true},
+		{"IPv6Loopback", "", "[::1]:8080", nil, true},
+		{"Rebound", "", "evil.example:8080", nil, false},
+		{"WebPage", "", "localhost:8080", map[string]string{"Origin": "http://evil.example"}, false},
+		{"NoToken", "sesame", "localhost:8080", nil, false},
+		{"WrongToken", "sesame", "localhost:8080", map[string]string{"Authorization": "Bearer open sesame"}, false},
+		{"Token", "sesame", "edwood.example:8080", map[string]string{"Authorization": "Bearer sesame"}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			api := &httpAPI{}
+			if tc.token != "" {
+				api.token = []byte(tc.token)
+			}
+			r := httptest.NewRequest("GET", "/windows", nil)
+			r.Host = tc.host
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+			if got := api.authorized(r); got != tc.want {
+				t.Errorf("authorized is %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestServeHTTPAPINonLoopback(t *testing.T) {
+	if err := serveHTTPAPI(":0", nil); err == nil {
+		t.Errorf("served HTTP API on all addresses without a token")
+	}
+}
+
+func TestHTTPAPIWindows(t *testing.T) {
+	ts, stop := startHTTPAPI(t, "example")
+	defer stop()
+
+	var wins []httpWindow
+	if code := httpDo(t, "GET", ts.URL+"/windows", nil, &wins); code != http.StatusOK {
+		t.Fatalf("got status %v", code)
+	}
+	if got, want := len(wins), 5; got != want {
+		t.Fatalf("got %v windows; want %v", got, want)
+	}
+	if got, want := wins[0].Tag, row.LookupWin(1).tag.file.b.String(); got != want {
+		t.Errorf("tag of first window is %q; want %q", got, want)
+	}
+
+	var win httpWindow
+	if code := httpDo(t, "GET", ts.URL+"/windows/1", nil, &win); code != http.StatusOK {
+		t.Fatalf("got status %v", code)
+	}
+	if diff := cmp.Diff(wins[0], win); diff != "" {
+		t.Errorf("window mismatch (-want +got):\n%s", diff)
+	}
+	if code := httpDo(t, "GET", ts.URL+"/windows/100", nil, nil); code != http.StatusNotFound {
+		t.Errorf("got status %v for non-existent window; want %v", code, http.StatusNotFound)
+	}
+}
+
+func TestHTTPAPIBody(t *testing.T) {
+	ts, stop := startHTTPAPI(t, "example")
+	defer stop()
+
+	body := row.LookupWin(1).body.file.b.String()
+	lines := strings.SplitAfter(body, "\n")
+	q0 := utf8.RuneCountInString(lines[0] + lines[1])
+	q1 := q0 + utf8.RuneCountInString(lines[2])
+
+	var text httpText
+	if code := httpDo(t, "GET", ts.URL+"/windows/1/body", nil, &text); code != http.StatusOK {
+		t.Fatalf("got status %v", code)
+	}
+	if got, want := text.Text, body; got != want {
+		t.Errorf("body is %q; want %q", got, want)
+	}
+
+	text = httpText{}
+	if code := httpDo(t, "GET", ts.URL+"/windows/1/body?addr=3", nil, &text); code != http.StatusOK {
+		t.Fatalf("got status %v", code)
+	}
+	if got, want := text.Text, lines[2]; got != want {
+		t.Errorf("line 3 is %q; want %q", got, want)
+	}
+	if text.Q0 == nil || text.Q1 == nil || *text.Q0 != q0 || *text.Q1 != q1 {
+		t.Errorf("address of line 3 is %v; want %v,%v", text, q0, q1)
+	}
+
+	text = httpText{}
+	req := httpText{Text: "世界\n"}
+	if code := httpDo(t, "PUT", ts.URL+"/windows/1/body?addr=3", &req, &text); code != http.StatusOK {
+		t.Fatalf("got status %v", code)
+	}
+	if text.Q0 == nil || text.Q1 == nil || *text.Q0 != q0 || *text.Q1 != q0+3 {
+		t.Errorf("address after write is %v; want %v,%v", text, q0, q0+3)
+	}
+	if got, want := row.LookupWin(1).body.file.b.String(), lines[0]+lines[1]+"世界\n"+lines[3]; got != want {
+		t.Errorf("body after write is %q; want %q", got, want)
+	}
+
+	if code := httpDo(t, "GET", ts.URL+"/windows/1/body?addr=/nomatch/", nil, nil); code != http.StatusBadRequest {
+		t.Errorf("got status %v for bad address; want %v", code, http.StatusBadRequest)
+	}
+}
+
+func TestHTTPAPICtl(t *testing.T) {
+	ts, stop := startHTTPAPI(t, "example")
+	defer stop()
+
+	req := httpText{Text: "name /tmp/glass\n"}
+	if code := httpDo(t, "POST", ts.URL+"/windows/1/ctl", &req, nil); code != http.StatusNoContent {
+		t.Fatalf("got status %v", code)
+	}
+	if got, want := row.LookupWin(1).body.file.name, "/tmp/glass"; got != want {
+		t.Errorf("file name is %q; want %q", got, want)
+	}
+	req = httpText{Text: "bogus\n"}
+	if code := httpDo(t, "POST", ts.URL+"/windows/1/ctl", &req, nil); code != http.StatusBadRequest {
+		t.Errorf("got status %v for bad ctl message; want %v", code, http.StatusBadRequest)
+	}
+}
+
+func TestHTTPAPIEvents(t *testing.T) {
+	ts, stop := startHTTPAPI(t, "example")
+	defer stop()
+
+	res, err := http.Get(ts.URL + "/windows/1/events")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer res.Body.Close()
+	if got, want := res.Header.Get("Content-Type"), "text/event-stream"; got != want {
+		t.Fatalf("got content type %q; want %q", got, want)
+	}
+
+	nr := row.LookupWin(1).body.file.Nr()
+	req := httpText{Text: "hello\n"}
+	if code := httpDo(t, "POST", ts.URL+"/windows/1/body", &req, nil); code != http.StatusNoContent {
+		t.Fatalf("got status %v", code)
+	}
+
+	lines := make(chan string)
+	go func() {
+		sc := bufio.NewScanner(res.Body)
+		for sc.Scan() {
+			if sc.Text() != "" {
+				lines <- sc.Text()
+			}
+		}
+		close(lines)
+	}()
+	select {
+	case line := <-lines:
+		var ev httpEvent
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev); err != nil {
+			t.Fatalf("bad event %q: %v", line, err)
+		}
+		want := httpEvent{Origin: "E", Type: "I", Q0: nr, Q1: nr + 6, Text: "hello\n"}
+		if diff := cmp.Diff(want, ev); diff != "" {
+			t.Errorf("event mismatch (-want +got):\n%s", diff)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out waiting for event")
+	}
+}
+
+func TestReadEvent(t *testing.T) {
+	rd := bufio.NewReader(strings.NewReader("MX10 15 0 5 hello\nKI0 2 0 2 世界\nxx"))
+	for _, want := range []httpEvent{
+		{Origin: "M", Type: "X", Q0: 10, Q1: 15, Text: "hello"},
+		{Origin: "K", Type: "I", Q0: 0, Q1: 2, Text: "世界"},
+	} {
+		ev, err := readEvent(rd)
+		if err != nil {
+			t.Fatalf("readEvent failed: %v", err)
+		}
+		if diff := cmp.Diff(&want, ev); diff != "" {
+			t.Errorf("event mismatch (-want +got):\n%s", diff)
+		}
+	}
+	if _, err := readEvent(rd); err == nil {
+		t.Errorf("readEvent of bad event succeeded")
+	}
+}
@@ -3,6 +3,11 @@
 package draw
 
 import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
 	draw "9fans.net/go/draw"
 )
 
@@ -60,3 +65,27 @@ func (dev *Device) NewDisplay(errch chan<- error, fontname, label, winsize strin
 	}
 	return &displayImpl{d}, nil
 }
+
+// fontranges returns the ranges of runes covered by font f, read from
+// its description as OpenFont does, or nil if they can't be read.
+func fontranges(f *drawFont) []RuneRange {
+	name := f.Name
+	if hasfontscale(name) {
+		name = name[strings.Index(name, "*")+1:]
+	}
+	desc, err := ioutil.ReadFile(name)
+	if err != nil && strings.HasPrefix(name, "/lib/font/bit/") {
+		root := os.Getenv("PLAN9")
+		if root == "" {
+			root = "/usr/local/plan9"
+		}
+		desc, err = ioutil.ReadFile(root + "/font/" + name[len("/lib/font/bit/"):])
+	}
+	if err != nil && strings.HasPrefix(name, "/mnt/font/") {
+		desc, err = exec.Command("fontsrv", "-pp", name[len("/mnt/font/"):]).Output()
+	}
+	if err != nil {
+		return nil
+	}
+	return ParseFontRanges(desc)
+}
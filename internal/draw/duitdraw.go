@@ -65,3 +65,9 @@ func (dev *Device) NewDisplay(errch chan<- error, fontname, label, winsize strin
 	}
 	return &displayImpl{d}, nil
 }
+
+// fontranges returns nil: the glyphs of a TrueType font opened by
+// duitdraw can't be found out, so every rune is taken to have one.
+func fontranges(f *drawFont) []RuneRange {
+	return nil
+}
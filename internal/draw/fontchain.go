@@ -0,0 +1,169 @@
+package draw
+
+import (
+	"image"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FontChainSep separates the names of the fonts of a font chain, as in
+// /lib/font/bit/lucsans/euro.8.font,/mnt/font/NotoEmoji/14a/font.
+const FontChainSep = ","
+
+// fontChain is a Font made of a list of fonts. Each rune is drawn and
+// measured in the first font of the list that has a glyph for it or,
+// if none has, in the first font.
+type fontChain struct {
+	fonts  []Font
+	height int
+}
+
+var _ = Font((*fontChain)(nil))
+
+// NewFontChain returns a Font that takes each rune from the first of
+// fonts that has a glyph for it. Lines are as high as the highest of
+// fonts. A chain of one font is that font.
+func NewFontChain(fonts ...Font) Font {
+	if len(fonts) == 1 {
+		return fonts[0]
+	}
+	c := &fontChain{fonts: fonts}
+	for _, f := range fonts {
+		if h := f.Height(); h > c.height {
+			c.height = h
+		}
+	}
+	return c
+}
+
+func (c *fontChain) Name() string {
+	names := make([]string, 0, len(c.fonts))
+	for _, f := range c.fonts {
+		names = append(names, f.Name())
+	}
+	return strings.Join(names, FontChainSep)
+}
+
+func (c *fontChain) Height() int { return c.height }
+
+func (c *fontChain) Has(r rune) bool {
+	for _, f := range c.fonts {
+		if f.Has(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// fontfor returns the font of c in which r is drawn.
+func (c *fontChain) fontfor(r rune) Font {
+	for _, f := range c.fonts {
+		if f.Has(r) {
+			return f
+		}
+	}
+	return c.fonts[0]
+}
+
+// runs calls fn for each of the longest runs of b drawn in the same
+// font of c.
+func (c *fontChain) runs(b []byte, fn func(f Font, run []byte)) {
+	var cur Font
+	start := 0
+	for i := 0; i < len(b); {
+		r, n := utf8.DecodeRune(b[i:])
+		if f := c.fontfor(r); f != cur {
+			if i > start {
+				fn(cur, b[start:i])
+			}
+			cur, start = f, i
+		}
+		i += n
+	}
+	if len(b) > start {
+		fn(cur, b[start:])
+	}
+}
+
+func (c *fontChain) BytesWidth(b []byte) int {
+	w := 0
+	c.runs(b, func(f Font, run []byte) { w += f.BytesWidth(run) })
+	return w
+}
+
+func (c *fontChain) RunesWidth(r []rune) int {
+	return c.BytesWidth([]byte(string(r)))
+}
+
+func (c *fontChain) StringWidth(s string) int {
+	return c.BytesWidth([]byte(s))
+}
+
+// bytes draws b at pt in the fonts of c with draw, which draws one run
+// in one font and returns the point where the run ends. Runs in fonts
+// lower than c are moved down to share the bottom of the line.
+func (c *fontChain) bytes(pt image.Point, b []byte, draw func(pt image.Point, f Font, run []byte) image.Point) image.Point {
+	y := pt.Y
+	c.runs(b, func(f Font, run []byte) {
+		pt.Y = y + c.height - f.Height()
+		pt = draw(pt, f, run)
+	})
+	pt.Y = y
+	return pt
+}
+
+// DrawRuns draws b at pt with draw, which draws a run of b in one font
+// and returns the point where the run ends. If f is a font chain, draw
+// is called for each run of b in one of its fonts; otherwise it is
+// called once for all of b. DrawRuns returns the point where b ends.
+// It lets an Image draw text in any Font.
+func DrawRuns(pt image.Point, f Font, b []byte, draw func(pt image.Point, f Font, run []byte) image.Point) image.Point {
+	if c, ok := f.(*fontChain); ok {
+		return c.bytes(pt, b, draw)
+	}
+	return draw(pt, f, b)
+}
+
+// RuneRange is a range of runes, from Lo to Hi inclusive.
+type RuneRange struct {
+	Lo, Hi rune
+}
+
+// ParseFontRanges returns the ranges of runes covered by the subfonts
+// of the font described by desc, in the format of font(6), or nil if
+// desc can't be parsed.
+func ParseFontRanges(desc []byte) []RuneRange {
+	f := strings.Fields(string(desc))
+	if len(f) < 2 {
+		return nil
+	}
+	var ranges []RuneRange
+	for i := 2; i+2 < len(f); {
+		lo, err := strconv.ParseInt(f[i], 0, 32)
+		if err != nil {
+			return nil
+		}
+		hi, err := strconv.ParseInt(f[i+1], 0, 32)
+		if err != nil {
+			return nil
+		}
+		i += 2
+		if c := f[i][0]; '0' <= c && c <= '9' {
+			i++ // offset
+		}
+		i++ // subfont name
+		ranges = append(ranges, RuneRange{rune(lo), rune(hi)})
+	}
+	return ranges
+}
+
+// InRanges returns true if r is in one of ranges.
+func InRanges(ranges []RuneRange, r rune) bool {
+	for _, rr := range ranges {
+		if rr.Lo <= r && r <= rr.Hi {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package draw
+
+import (
+	"image"
+	"reflect"
+	"testing"
+	"unicode/utf8"
+)
+
+// testFont is a fixed-width Font with glyphs for the runes in ranges.
+type testFont struct {
+	name          string
+	width, height int
+	ranges        []RuneRange
+}
+
+func (f *testFont) Name() string             { return f.name }
+func (f *testFont) Height() int              { return f.height }
+func (f *testFont) Has(r rune) bool          { return InRanges(f.ranges, r) }
+func (f *testFont) BytesWidth(b []byte) int  { return f.width * utf8.RuneCount(b) }
+func (f *testFont) RunesWidth(r []rune) int  { return f.width * len(r) }
+func (f *testFont) StringWidth(s string) int { return f.width * utf8.RuneCountInString(s) }
+
+func TestFontChain(t *testing.T) {
+	latin := &testFont{"latin", 10, 13, []RuneRange{{0, 0xFF}}}
+	cjk := &testFont{"cjk", 20, 16, []RuneRange{{0x4E00, 0x9FFF}}}
+	c := NewFontChain(latin, cjk)
+
+	if got, want := c.Name(), "latin,cjk"; got != want {
+		t.Errorf("name is %q; want %q", got, want)
+	}
+	if got, want := c.Height(), 16; got != want {
+		t.Errorf("height is %d; want %d", got, want)
+	}
+	if NewFontChain(latin) != Font(latin) {
+		t.Errorf("chain of one font is not that font")
+	}
+
+	s := "a中文b☃"
+	want := 10 + 20 + 20 + 10 + 10 // ☃ falls back to the first font
+	if got := c.StringWidth(s); got != want {
+		t.Errorf("StringWidth(%q) is %d; want %d", s, got, want)
+	}
+	if got := c.BytesWidth([]byte(s)); got != want {
+		t.Errorf("BytesWidth(%q) is %d; want %d", s, got, want)
+	}
+	if got := c.RunesWidth([]rune(s)); got != want {
+		t.Errorf("RunesWidth(%q) is %d; want %d", s, got, want)
+	}
+	if !c.Has('中') || c.Has('☃') {
+		t.Errorf("chain has the wrong glyphs")
+	}
+
+	type run struct {
+		pt   image.Point
+		font string
+		text string
+	}
+	var runs []run
+	end := c.(*fontChain).bytes(image.Pt(5, 100), []byte(s), func(pt image.Point, f Font, b []byte) image.Point {
+		runs = append(runs, run{pt, f.Name(), string(b)})
+		return pt.Add(image.Pt(f.BytesWidth(b), 0))
+	})
+	wantruns := []run{
+		{image.Pt(5, 103), "latin", "a"},
+		{image.Pt(15, 100), "cjk", "中文"},
+		{image.Pt(55, 103), "latin", "b☃"},
+	}
+	if !reflect.DeepEqual(runs, wantruns) {
+		t.Errorf("drew runs %v; want %v", runs, wantruns)
+	}
+	if got, want := end, image.Pt(75, 100); got != want {
+		t.Errorf("drawing ended at %v; want %v", got, want)
+	}
+}
+
+func TestParseFontRanges(t *testing.T) {
+	desc := `17	14
+0x0000	0x007F	lucsans/latin1.7
+0x0080	0x00FF	0x0080	lucsans/latin1.7
+0x4E00 0x9FFF ../cjk/hanzi.16
+`
+	want := []RuneRange{{0, 0x7F}, {0x80, 0xFF}, {0x4E00, 0x9FFF}}
+	if got := ParseFontRanges([]byte(desc)); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseFontRanges returned %v; want %v", got, want)
+	}
+	for _, s := range []string{"", "17", "17 14\nzero 0x7F latin1.7\n"} {
+		if got := ParseFontRanges([]byte(s)); got != nil {
+			t.Errorf("ParseFontRanges(%q) returned %v; want nil", s, got)
+		}
+	}
+}
@@ -1,6 +1,11 @@
 package draw
 
-import "image"
+import (
+	"fmt"
+	"image"
+	"math"
+	"sync"
+)
 
 type Display interface {
 	ScreenImage() Image
@@ -17,6 +22,7 @@ type Display interface {
 	Attach(ref int) error
 	Flush() error
 	ScaleSize(n int) int
+	SetScale(scale float64)
 	ReadSnarf(buf []byte) (int, int, error)
 	WriteSnarf(data []byte) error
 	MoveTo(pt image.Point) error
@@ -34,9 +40,16 @@ type Image interface {
 	Free() error
 }
 
+// A Snapshotter is an Image whose pixels can be read back.
+type Snapshotter interface {
+	// Snapshot returns a copy of the pixels of the image in r.
+	Snapshot(r image.Rectangle) *image.RGBA
+}
+
 type Font interface {
 	Name() string
 	Height() int
+	Has(r rune) bool
 	BytesWidth(b []byte) int
 	RunesWidth(r []rune) int
 	StringWidth(s string) int
@@ -55,12 +68,80 @@ func (d *displayImpl) Black() Image       { return &imageImpl{d.drawDisplay.Blac
 func (d *displayImpl) Opaque() Image      { return &imageImpl{d.drawDisplay.Opaque} }
 func (d *displayImpl) Transparent() Image { return &imageImpl{d.drawDisplay.Transparent} }
 
+// scales holds the scale factors set with SetScale. They are kept here
+// rather than in displayImpl because a displayImpl is made afresh each
+// time an Image is asked for its Display.
+var scales struct {
+	sync.Mutex
+	m map[*drawDisplay]float64
+}
+
+func (d *displayImpl) scale() float64 {
+	scales.Lock()
+	defer scales.Unlock()
+	return scales.m[d.drawDisplay]
+}
+
+// SetScale sets the factor by which ScaleSize scales sizes, overriding
+// the one given by the resolution of the display. A factor of 0 goes
+// back to using the resolution. Fonts opened afterwards are magnified
+// by as many whole times as the factor exceeds the resolution's.
+func (d *displayImpl) SetScale(scale float64) {
+	scales.Lock()
+	defer scales.Unlock()
+	if scale <= 0 {
+		delete(scales.m, d.drawDisplay)
+		return
+	}
+	if scales.m == nil {
+		scales.m = make(map[*drawDisplay]float64)
+	}
+	scales.m[d.drawDisplay] = scale
+}
+
+func (d *displayImpl) ScaleSize(n int) int {
+	if s := d.scale(); s > 0 {
+		return int(math.Floor(float64(n)*s + 0.5))
+	}
+	return d.drawDisplay.ScaleSize(n)
+}
+
+// fontmag returns how many times fonts are to be magnified to make up
+// the difference between the scale factor set with SetScale and the
+// one of the resolution of the display.
+func (d *displayImpl) fontmag() int {
+	s := d.scale()
+	if s <= 0 {
+		return 1
+	}
+	dpi := float64(d.drawDisplay.ScaleSize(1000)) / 1000
+	if m := int(math.Floor(s/dpi + 0.5)); m > 1 {
+		return m
+	}
+	return 1
+}
+
+// hasfontscale returns true if font name starts with a magnification,
+// as in 2*/lib/font/bit/lucsans/euro.8.font.
+func hasfontscale(name string) bool {
+	i := 0
+	for i < len(name) && '0' <= name[i] && name[i] <= '9' {
+		i++
+	}
+	return i > 0 && i < len(name) && name[i] == '*'
+}
+
 func (d *displayImpl) OpenFont(name string) (Font, error) {
+	if m := d.fontmag(); m > 1 && !hasfontscale(name) {
+		if f, err := d.drawDisplay.OpenFont(fmt.Sprintf("%d*%s", m, name)); err == nil {
+			return &fontImpl{drawFont: f}, nil
+		}
+	}
 	f, err := d.drawDisplay.OpenFont(name)
 	if err != nil {
 		return nil, err
 	}
-	return &fontImpl{f}, nil
+	return &fontImpl{drawFont: f}, nil
 }
 
 func (d *displayImpl) AllocImage(r image.Rectangle, pix Pix, repl bool, val Color) (Image, error) {
@@ -95,7 +176,9 @@ func (dst *imageImpl) Border(r image.Rectangle, n int, color Image, sp image.Poi
 }
 
 func (dst *imageImpl) Bytes(pt image.Point, src Image, sp image.Point, f Font, b []byte) image.Point {
-	return dst.drawImage.Bytes(pt, toDrawImage(src), sp, f.(*fontImpl).drawFont, b)
+	return DrawRuns(pt, f, b, func(pt image.Point, f Font, run []byte) image.Point {
+		return dst.drawImage.Bytes(pt, toDrawImage(src), sp, f.(*fontImpl).drawFont, run)
+	})
 }
 
 func toDrawImage(i Image) *drawImage {
@@ -107,7 +190,17 @@ func toDrawImage(i Image) *drawImage {
 
 type fontImpl struct {
 	*drawFont
+
+	once   sync.Once
+	ranges []RuneRange // runes with glyphs; nil if not known
 }
 
 func (f *fontImpl) Name() string { return f.drawFont.Name }
 func (f *fontImpl) Height() int  { return f.drawFont.Height }
+
+// Has returns true if f has a glyph for r. It assumes f has glyphs for
+// every rune if the runes it covers can't be found out.
+func (f *fontImpl) Has(r rune) bool {
+	f.once.Do(func() { f.ranges = fontranges(f.drawFont) })
+	return f.ranges == nil || InRanges(f.ranges, r)
+}
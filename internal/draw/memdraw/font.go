@@ -0,0 +1,112 @@
+package memdraw
+
+import (
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rjkroege/edwood/internal/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/plan9font"
+	"golang.org/x/image/math/fixed"
+)
+
+// DefaultFont is the name of the font built into every Display, which
+// can be used when no other font can be read.
+const DefaultFont = "*default*"
+
+// Font is a draw.Font: a font of Plan 9 or the default font.
+type Font struct {
+	name   string
+	face   font.Face
+	height int
+	ascent int
+	ranges []draw.RuneRange // runes with glyphs; nil if all
+}
+
+var _ = draw.Font((*Font)(nil))
+
+// readfont reads the description of the font in file name, looking
+// for the fonts of /lib/font/bit in $PLAN9/font as plan9port does. It
+// returns the name of the file it read.
+func readfont(name string) (string, []byte, error) {
+	desc, err := ioutil.ReadFile(name)
+	if err != nil && strings.HasPrefix(name, "/lib/font/bit/") {
+		root := os.Getenv("PLAN9")
+		if root == "" {
+			root = "/usr/local/plan9"
+		}
+		name = filepath.Join(root, "font", name[len("/lib/font/bit/"):])
+		desc, err = ioutil.ReadFile(name)
+	}
+	return name, desc, err
+}
+
+// OpenFont opens the Plan 9 font in file name or, if name is
+// DefaultFont, the default font.
+func (d *Display) OpenFont(name string) (draw.Font, error) {
+	if name == DefaultFont {
+		m := basicfont.Face7x13.Metrics()
+		return &Font{name: name, face: basicfont.Face7x13, height: m.Height.Ceil(), ascent: m.Ascent.Ceil()}, nil
+	}
+	file, desc, err := readfont(name)
+	if err != nil {
+		return nil, err
+	}
+	face, err := plan9font.ParseFont(desc, func(rel string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join(filepath.Dir(file), rel))
+	})
+	if err != nil {
+		return nil, err
+	}
+	m := face.Metrics()
+	return &Font{
+		name:   name,
+		face:   face,
+		height: m.Height.Ceil(),
+		ascent: m.Ascent.Ceil(),
+		ranges: draw.ParseFontRanges(desc),
+	}, nil
+}
+
+func (f *Font) Name() string { return f.name }
+func (f *Font) Height() int  { return f.height }
+
+func (f *Font) Has(r rune) bool {
+	return f.ranges == nil || draw.InRanges(f.ranges, r)
+}
+
+// runewidth returns the width of r, which is that of U+FFFD if f has
+// no glyph for r.
+func (f *Font) runewidth(r rune) int {
+	a, _ := f.face.GlyphAdvance(r)
+	return a.Round()
+}
+
+func (f *Font) StringWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		w += f.runewidth(r)
+	}
+	return w
+}
+
+func (f *Font) BytesWidth(b []byte) int { return f.StringWidth(string(b)) }
+func (f *Font) RunesWidth(r []rune) int { return f.StringWidth(string(r)) }
+
+// bytes draws text b at pt in font f, with sp in src aligned with pt,
+// and returns the point where the text ends.
+func (i *Image) bytes(pt image.Point, src *Image, sp image.Point, f *Font, b []byte) image.Point {
+	pt0 := pt
+	for _, r := range string(b) {
+		dr, mask, mp, _, ok := f.face.Glyph(fixed.P(pt.X, pt.Y+f.ascent), r)
+		if ok {
+			i.draw(dr, src, sp.Add(dr.Min.Sub(pt0)), glyphmask{mask}, mp)
+		}
+		pt.X += f.runewidth(r)
+	}
+	return pt
+}
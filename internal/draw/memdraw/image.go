@@ -0,0 +1,229 @@
+package memdraw
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/rjkroege/edwood/internal/draw"
+)
+
+// Image is a draw.Image kept in memory. Images without an alpha
+// channel are opaque and, used as masks, have alphas of their grey
+// levels, as on a real display.
+type Image struct {
+	display *Display
+	pix     draw.Pix
+	repl    bool
+	alpha   bool
+	rgba    *image.RGBA
+}
+
+var (
+	_ = draw.Image((*Image)(nil))
+	_ = draw.Snapshotter((*Image)(nil))
+)
+
+// rgbaof returns colour val as it is kept in an image of pixel format
+// pix.
+func rgbaof(val draw.Color, pix draw.Pix) color.RGBA {
+	c := color.RGBA{uint8(val >> 24), uint8(val >> 16), uint8(val >> 8), uint8(val)}
+	if isgrey(pix) {
+		y := uint8((299*uint32(c.R) + 587*uint32(c.G) + 114*uint32(c.B) + 500) / 1000)
+		c.R, c.G, c.B = y, y, y
+	}
+	if !hasalpha(pix) {
+		c.A = 0xFF
+	}
+	return c
+}
+
+func (d *Display) allocimage(r image.Rectangle, pix draw.Pix, repl bool, val draw.Color) *Image {
+	i := &Image{
+		display: d,
+		pix:     pix,
+		repl:    repl,
+		alpha:   hasalpha(pix),
+		rgba:    image.NewRGBA(r),
+	}
+	if val != draw.Nofill {
+		c := rgbaof(val, pix)
+		for p := 0; p < len(i.rgba.Pix); p += 4 {
+			i.rgba.Pix[p+0] = c.R
+			i.rgba.Pix[p+1] = c.G
+			i.rgba.Pix[p+2] = c.B
+			i.rgba.Pix[p+3] = c.A
+		}
+	}
+	return i
+}
+
+func (i *Image) Display() draw.Display { return i.display }
+func (i *Image) Pix() draw.Pix         { return i.pix }
+func (i *Image) R() image.Rectangle    { return i.rgba.Rect }
+
+// wrap returns p moved into the rectangle of i if i is replicated.
+func (i *Image) wrap(p image.Point) image.Point {
+	if !i.repl {
+		return p
+	}
+	r := i.rgba.Rect
+	mod := func(a, n int) int {
+		a %= n
+		if a < 0 {
+			a += n
+		}
+		return a
+	}
+	return image.Pt(r.Min.X+mod(p.X-r.Min.X, r.Dx()), r.Min.Y+mod(p.Y-r.Min.Y, r.Dy()))
+}
+
+// at returns the colour of i at p.
+func (i *Image) at(p image.Point) color.RGBA {
+	p = i.wrap(p)
+	return i.rgba.RGBAAt(p.X, p.Y)
+}
+
+// copyof returns a copy of the part r of i.
+func (i *Image) copyof(r image.Rectangle) *Image {
+	c := *i
+	c.repl = false
+	c.rgba = image.NewRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		copy(c.rgba.Pix[c.rgba.PixOffset(r.Min.X, y):c.rgba.PixOffset(r.Max.X, y)], i.rgba.Pix[i.rgba.PixOffset(r.Min.X, y):i.rgba.PixOffset(r.Max.X, y)])
+	}
+	return &c
+}
+
+// A mask gives the alphas through which a source is drawn.
+type mask interface {
+	bounds() (image.Rectangle, bool) // and whether it is replicated
+	alphaat(p image.Point) uint32
+}
+
+func (i *Image) bounds() (image.Rectangle, bool) { return i.rgba.Rect, i.repl }
+
+func (i *Image) alphaat(p image.Point) uint32 {
+	c := i.at(p)
+	if i.alpha {
+		return uint32(c.A)
+	}
+	return (299*uint32(c.R) + 587*uint32(c.G) + 114*uint32(c.B) + 500) / 1000
+}
+
+// glyphmask is the mask of a glyph of a font.
+type glyphmask struct {
+	image.Image
+}
+
+func (m glyphmask) bounds() (image.Rectangle, bool) { return m.Bounds(), false }
+
+func (m glyphmask) alphaat(p image.Point) uint32 {
+	_, _, _, a := m.At(p.X, p.Y).RGBA()
+	return a >> 8
+}
+
+// draw draws src through m onto i in r, with sp in src and mp in m
+// aligned with r.Min. Source and mask are clipped to their rectangles
+// unless replicated.
+func (i *Image) draw(r image.Rectangle, src *Image, sp image.Point, m mask, mp image.Point) {
+	if src == nil {
+		return
+	}
+	r = r.Intersect(i.rgba.Rect)
+	if !src.repl {
+		r = r.Intersect(src.rgba.Rect.Add(r.Min.Sub(sp)))
+	}
+	if m != nil {
+		if mr, repl := m.bounds(); !repl {
+			r = r.Intersect(mr.Add(r.Min.Sub(mp)))
+		}
+	}
+	if r.Empty() {
+		return
+	}
+	sd, md := sp.Sub(r.Min), mp.Sub(r.Min)
+	if src == i && !src.repl {
+		// Copying within i: read from a copy so that the parts
+		// already drawn aren't read again.
+		src = i.copyof(r.Add(sd))
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			p := image.Pt(x, y)
+			a := uint32(0xFF)
+			if m != nil {
+				if a = m.alphaat(p.Add(md)); a == 0 {
+					continue
+				}
+			}
+			s := src.at(p.Add(sd))
+			d := i.rgba.RGBAAt(x, y)
+			sa := uint32(s.A) * a / 0xFF
+			over := func(s, d uint8) uint8 {
+				return uint8((uint32(s)*a + uint32(d)*(0xFF-sa) + 0x7F) / 0xFF)
+			}
+			c := color.RGBA{over(s.R, d.R), over(s.G, d.G), over(s.B, d.B), over(s.A, d.A)}
+			if !i.alpha {
+				c.A = 0xFF
+			}
+			i.rgba.SetRGBA(x, y, c)
+		}
+	}
+}
+
+func (i *Image) Draw(r image.Rectangle, src, m draw.Image, p1 image.Point) {
+	i.display.mu.Lock()
+	defer i.display.mu.Unlock()
+	var mk mask
+	if m != nil {
+		mk = m.(*Image)
+	}
+	i.draw(r, toImage(src), p1, mk, p1)
+}
+
+// Border draws a border of width n inside r, or outside it if n is
+// negative, with sp in src aligned with r.Min.
+func (i *Image) Border(r image.Rectangle, n int, src draw.Image, sp image.Point) {
+	i.display.mu.Lock()
+	defer i.display.mu.Unlock()
+	s := toImage(src)
+	if n < 0 {
+		r = r.Inset(n)
+		sp = sp.Add(image.Pt(n, n))
+		n = -n
+	}
+	i.draw(image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+n), s, sp, nil, image.Point{})
+	p := image.Pt(sp.X, sp.Y+r.Dy()-n)
+	i.draw(image.Rect(r.Min.X, r.Max.Y-n, r.Max.X, r.Max.Y), s, p, nil, image.Point{})
+	p = image.Pt(sp.X, sp.Y+n)
+	i.draw(image.Rect(r.Min.X, r.Min.Y+n, r.Min.X+n, r.Max.Y-n), s, p, nil, image.Point{})
+	p = image.Pt(sp.X+r.Dx()-n, sp.Y+n)
+	i.draw(image.Rect(r.Max.X-n, r.Min.Y+n, r.Max.X, r.Max.Y-n), s, p, nil, image.Point{})
+}
+
+// Bytes draws text b at pt in font f, with sp in src aligned with pt,
+// and returns the point where the text ends.
+func (i *Image) Bytes(pt image.Point, src draw.Image, sp image.Point, f draw.Font, b []byte) image.Point {
+	i.display.mu.Lock()
+	defer i.display.mu.Unlock()
+	s, pt0 := toImage(src), pt
+	return draw.DrawRuns(pt, f, b, func(pt image.Point, f draw.Font, run []byte) image.Point {
+		return i.bytes(pt, s, sp.Add(pt.Sub(pt0)), f.(*Font), run)
+	})
+}
+
+func (i *Image) Free() error { return nil }
+
+// Snapshot returns a copy of the pixels of i in r.
+func (i *Image) Snapshot(r image.Rectangle) *image.RGBA {
+	i.display.mu.Lock()
+	defer i.display.mu.Unlock()
+	return i.copyof(r.Intersect(i.rgba.Rect)).rgba
+}
+
+func toImage(i draw.Image) *Image {
+	if i == nil {
+		return nil
+	}
+	return i.(*Image)
+}
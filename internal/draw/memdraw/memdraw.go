@@ -0,0 +1,181 @@
+// Package memdraw implements draw.Display in memory with the image
+// package. It draws everything a real display would, with the fonts
+// of Plan 9, so that what Edwood draws can be looked at without a
+// devdraw: in tests and when Edwood runs headless.
+package memdraw
+
+import (
+	"errors"
+	"image"
+	"sync"
+
+	"github.com/rjkroege/edwood/internal/draw"
+)
+
+// The channels of a pixel format, in the encoding of draw.Pix.
+const (
+	cred = iota
+	cgreen
+	cblue
+	cgrey
+	calpha
+	cmap
+	cignore
+)
+
+// makepix makes a pixel format from a list of channels and their
+// depths, as draw.MakePix does.
+func makepix(list ...int) draw.Pix {
+	var p draw.Pix
+	for _, x := range list {
+		p <<= 4
+		p |= draw.Pix(x)
+	}
+	return p
+}
+
+// Pixel formats of the images of a Display.
+var (
+	GREY1  = makepix(cgrey, 1)
+	GREY8  = makepix(cgrey, 8)
+	RGBA32 = makepix(cred, 8, cgreen, 8, cblue, 8, calpha, 8)
+	XRGB32 = makepix(cignore, 8, cred, 8, cgreen, 8, cblue, 8)
+)
+
+// hasalpha returns true if pixel format p has an alpha channel.
+func hasalpha(p draw.Pix) bool {
+	for ; p != 0; p >>= 8 {
+		if (p>>4)&15 == calpha {
+			return true
+		}
+	}
+	return false
+}
+
+// isgrey returns true if pixel format p has only a grey channel.
+func isgrey(p draw.Pix) bool {
+	return p&^0xF == cgrey<<4
+}
+
+// Display is a draw.Display kept in memory.
+type Display struct {
+	mu sync.Mutex // guards the pixels of every image
+
+	screen      *Image
+	white       *Image
+	black       *Image
+	scale       float64
+	snarf       []byte
+	mousectl    *draw.Mousectl
+	keyboardctl *draw.Keyboardctl
+	mousec      chan draw.Mouse
+	resizec     chan bool
+	keyboardc   chan rune
+}
+
+var _ = draw.Display((*Display)(nil))
+
+// NewDisplay returns a Display with a white screen covering r.
+func NewDisplay(r image.Rectangle) *Display {
+	d := &Display{
+		mousec:    make(chan draw.Mouse),
+		resizec:   make(chan bool, 2),
+		keyboardc: make(chan rune),
+	}
+	d.white = d.allocimage(image.Rect(0, 0, 1, 1), GREY1, true, draw.White)
+	d.black = d.allocimage(image.Rect(0, 0, 1, 1), GREY1, true, 0x000000FF)
+	d.screen = d.allocimage(r, XRGB32, false, draw.White)
+	d.mousectl = &draw.Mousectl{C: d.mousec, Resize: d.resizec}
+	d.keyboardctl = &draw.Keyboardctl{C: d.keyboardc}
+	return d
+}
+
+func (d *Display) ScreenImage() draw.Image { return d.screen }
+func (d *Display) White() draw.Image       { return d.white }
+func (d *Display) Black() draw.Image       { return d.black }
+
+// Opaque and Transparent are White and Black, which have no alpha
+// channel: when used as masks, their grey levels are their alphas.
+func (d *Display) Opaque() draw.Image      { return d.white }
+func (d *Display) Transparent() draw.Image { return d.black }
+
+// InitMouse returns a Mousectl that delivers the events sent with
+// SendMouse and SendResize.
+func (d *Display) InitMouse() *draw.Mousectl { return d.mousectl }
+
+// InitKeyboard returns a Keyboardctl that delivers the keys sent with
+// SendKey.
+func (d *Display) InitKeyboard() *draw.Keyboardctl { return d.keyboardctl }
+
+// SendMouse delivers m on the Mousectl of d, waiting until it is
+// received. The receiver stores it in Mousectl.Mouse.
+func (d *Display) SendMouse(m draw.Mouse) { d.mousec <- m }
+
+// SendKey delivers r on the Keyboardctl of d, waiting until it is
+// received. Unlike those of a real keyboard, keys aren't buffered, so
+// that each is taken before the next event is sent.
+func (d *Display) SendKey(r rune) { d.keyboardc <- r }
+
+// SendResize signals a resize on the Mousectl of d. The screen keeps
+// its size.
+func (d *Display) SendResize() { d.resizec <- true }
+
+func (d *Display) AllocImage(r image.Rectangle, pix draw.Pix, repl bool, val draw.Color) (draw.Image, error) {
+	if r.Empty() {
+		return nil, errors.New("memdraw: empty image")
+	}
+	return d.allocimage(r, pix, repl, val), nil
+}
+
+// AllocImageMix returns a replicated image of a quarter of color1 and
+// three quarters of color3, as a display deeper than 8 bits makes.
+func (d *Display) AllocImageMix(color1, color3 draw.Color) draw.Image {
+	mix := func(c1, c3 draw.Color, shift uint) draw.Color {
+		a, b := (c1>>shift)&0xFF, (c3>>shift)&0xFF
+		return ((a + 3*b + 2) / 4) << shift
+	}
+	var c draw.Color
+	for _, shift := range []uint{24, 16, 8, 0} {
+		c |= mix(color1, color3, shift)
+	}
+	return d.allocimage(image.Rect(0, 0, 1, 1), d.screen.pix, true, c)
+}
+
+func (d *Display) Attach(ref int) error { return nil }
+func (d *Display) Flush() error         { return nil }
+
+// ScaleSize returns n, as for a display of ordinary resolution, or n
+// scaled by the factor set with SetScale.
+func (d *Display) ScaleSize(n int) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.scale > 0 {
+		return int(float64(n)*d.scale + 0.5)
+	}
+	return n
+}
+
+func (d *Display) SetScale(scale float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.scale = scale
+}
+
+// ReadSnarf reads the snarf buffer into buf, returning the number of
+// bytes read and the size of the snarf buffer.
+func (d *Display) ReadSnarf(buf []byte) (int, int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := copy(buf, d.snarf)
+	return n, len(d.snarf), nil
+}
+
+func (d *Display) WriteSnarf(data []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.snarf = append([]byte(nil), data...)
+	return nil
+}
+
+func (d *Display) MoveTo(pt image.Point) error    { return nil }
+func (d *Display) SetCursor(c *draw.Cursor) error { return nil }
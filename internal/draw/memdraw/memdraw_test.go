@@ -0,0 +1,117 @@
+package memdraw
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/rjkroege/edwood/internal/draw"
+)
+
+func TestDraw(t *testing.T) {
+	d := NewDisplay(image.Rect(0, 0, 8, 8))
+	screen := d.ScreenImage().(*Image)
+	red, _ := d.AllocImage(image.Rect(0, 0, 1, 1), RGBA32, true, draw.Color(0xFF0000FF))
+	half, _ := d.AllocImage(image.Rect(0, 0, 1, 1), GREY8, true, 0x808080FF)
+
+	screen.Draw(image.Rect(0, 0, 4, 4), red, nil, image.Point{})
+	screen.Draw(image.Rect(4, 0, 8, 4), d.Black(), half, image.Point{})
+	screen.Draw(image.Rect(0, 4, 4, 8), red, d.Transparent(), image.Point{})
+	screen.Draw(image.Rect(4, 4, 8, 8), d.Black(), d.Opaque(), image.Point{})
+
+	for _, tc := range []struct {
+		p    image.Point
+		want color.RGBA
+	}{
+		{image.Pt(1, 1), color.RGBA{0xFF, 0, 0, 0xFF}},
+		{image.Pt(5, 1), color.RGBA{0x7F, 0x7F, 0x7F, 0xFF}},
+		{image.Pt(1, 5), color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}},
+		{image.Pt(5, 5), color.RGBA{0, 0, 0, 0xFF}},
+	} {
+		if got := screen.at(tc.p); got != tc.want {
+			t.Errorf("pixel at %v is %v; want %v", tc.p, got, tc.want)
+		}
+	}
+}
+
+func TestDrawOverlapping(t *testing.T) {
+	d := NewDisplay(image.Rect(0, 0, 4, 1))
+	screen := d.ScreenImage().(*Image)
+	screen.Draw(image.Rect(0, 0, 1, 1), d.Black(), nil, image.Point{})
+	// Scrolling right by one must not smear the black pixel.
+	screen.Draw(image.Rect(1, 0, 4, 1), screen, nil, image.Pt(0, 0))
+	for x, want := range []uint8{0, 0, 0xFF, 0xFF} {
+		if got := screen.at(image.Pt(x, 0)).R; got != want {
+			t.Errorf("pixel %d is %#x; want %#x", x, got, want)
+		}
+	}
+}
+
+func TestBorder(t *testing.T) {
+	d := NewDisplay(image.Rect(0, 0, 6, 6))
+	screen := d.ScreenImage().(*Image)
+	screen.Border(image.Rect(1, 1, 5, 5), 1, d.Black(), image.Point{})
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 6; x++ {
+			p := image.Pt(x, y)
+			black := p.In(image.Rect(1, 1, 5, 5)) && !p.In(image.Rect(2, 2, 4, 4))
+			if got := screen.at(p).R == 0; got != black {
+				t.Errorf("pixel at %v black is %v; want %v", p, got, black)
+			}
+		}
+	}
+}
+
+func TestFont(t *testing.T) {
+	d := NewDisplay(image.Rect(0, 0, 100, 20))
+	screen := d.ScreenImage().(*Image)
+	f, err := d.OpenFont("../../../build/font/lucsans/euro.8.font")
+	if err != nil {
+		t.Fatalf("can't open font: %v", err)
+	}
+	if f.Height() <= 0 || f.StringWidth("hello") <= 0 {
+		t.Errorf("font has height %d and width %d", f.Height(), f.StringWidth("hello"))
+	}
+	if !f.Has('a') || f.Has(0x1F600) {
+		t.Errorf("wrong runes in font: Has('a')=%v, Has(U+1F600)=%v", f.Has('a'), f.Has(0x1F600))
+	}
+	pt := screen.Bytes(image.Point{}, d.Black(), image.Point{}, f, []byte("hello"))
+	if want := image.Pt(f.StringWidth("hello"), 0); pt != want {
+		t.Errorf("text ends at %v; want %v", pt, want)
+	}
+	black := 0
+	for y := 0; y < f.Height(); y++ {
+		for x := 0; x < pt.X; x++ {
+			if screen.at(image.Pt(x, y)).R == 0 {
+				black++
+			}
+		}
+	}
+	if black == 0 {
+		t.Errorf("no text drawn")
+	}
+
+	if _, err := d.OpenFont(DefaultFont); err != nil {
+		t.Errorf("can't open default font: %v", err)
+	}
+	if _, err := d.OpenFont("/no/such/font"); err == nil {
+		t.Errorf("opened missing font")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	d := NewDisplay(image.Rect(0, 0, 10, 10))
+	screen := d.ScreenImage().(*Image)
+	screen.Draw(image.Rect(2, 2, 4, 4), d.Black(), nil, image.Point{})
+	s := screen.Snapshot(image.Rect(2, 2, 20, 20))
+	if want := image.Rect(2, 2, 10, 10); s.Rect != want {
+		t.Errorf("snapshot covers %v; want %v", s.Rect, want)
+	}
+	if got := s.RGBAAt(3, 3); got != (color.RGBA{0, 0, 0, 0xFF}) {
+		t.Errorf("snapshot pixel is %v; want black", got)
+	}
+	screen.Draw(screen.R(), d.Black(), nil, image.Point{})
+	if got := s.RGBAAt(9, 9); got != (color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}) {
+		t.Errorf("snapshot changed with the screen")
+	}
+}
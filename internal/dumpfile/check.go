@@ -0,0 +1,129 @@
+package dumpfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxColumns is the most columns Edwood will load.
+const maxColumns = 10
+
+// Validate returns the problems that stop Edwood from loading c, or
+// from restoring all of it, or nil if there are none.
+func (c *Content) Validate() []error {
+	var errs []error
+	bad := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.CurrentDir == "" {
+		bad("no current directory")
+	}
+	if len(c.Columns) > maxColumns {
+		bad("%v columns; at most %v can be loaded", len(c.Columns), maxColumns)
+	}
+	for i, col := range c.Columns {
+		if col.Position < 0 || col.Position >= 100 {
+			bad("column %v: position %v is not within the row", i, col.Position)
+		}
+		if i > 0 && col.Position < c.Columns[i-1].Position {
+			bad("column %v: position %v is left of the column before it", i, col.Position)
+		}
+		if col.Width < 0 {
+			bad("column %v: width %v is negative", i, col.Width)
+		}
+	}
+	for i, w := range c.Windows {
+		if w.Type < Saved || w.Type > Exec {
+			bad("window %v: unknown type %d", i, w.Type)
+			continue
+		}
+		if w.Type == Exec {
+			if w.ExecCommand == "" {
+				bad("window %v: no command to execute", i)
+			}
+			switch w.Restart {
+			case RestartOnLoad, RestartNever, RestartAlways:
+			default:
+				bad("window %v: bad restart %q", i, w.Restart)
+			}
+			for _, kv := range w.ExecEnv {
+				if strings.IndexByte(kv, '=') <= 0 {
+					bad("window %v: bad environment variable %q", i, kv)
+				}
+			}
+			continue
+		}
+		if w.Column < 0 || w.Column >= len(c.Columns) {
+			bad("window %v: no column %v", i, w.Column)
+		}
+		if w.Position < 0 || w.Position >= 100 {
+			bad("window %v: position %v is not within the column", i, w.Position)
+		}
+		if f := strings.SplitN(w.Tag.Buffer, " ", 2); len(f) != 2 || !strings.Contains(f[1], "|") {
+			bad("window %v: tag %q has no name followed by a |", i, w.Tag.Buffer)
+		}
+		if w.Type == Zerox && w.Name() == "" {
+			bad("window %v: zerox of a window with no name", i)
+		}
+		if w.Body.Q0 < 0 || w.Body.Q0 > w.Body.Q1 {
+			bad("window %v: bad selection %v,%v", i, w.Body.Q0, w.Body.Q1)
+		}
+		if n := len([]rune(w.Body.Buffer)); w.Type == Unsaved && w.Body.Q1 > n {
+			bad("window %v: selection %v,%v is beyond the %v runes of the body", i, w.Body.Q0, w.Body.Q1, n)
+		}
+		switch w.Gutter {
+		case "", "off", "abs", "absolute", "rel", "relative":
+		default:
+			bad("window %v: bad gutter %q", i, w.Gutter)
+		}
+		if w.Origin < 0 || w.TabWidth < 0 {
+			bad("window %v: negative origin or tab width", i)
+		}
+	}
+	return errs
+}
+
+// Name returns the name of the file shown in w, the first word of its
+// tag.
+func (w *Window) Name() string {
+	return strings.SplitN(w.Tag.Buffer, " ", 2)[0]
+}
+
+// ReplacePath replaces the directory old, or file old, with new in the
+// paths of c: its current directory, the names of its windows and the
+// directories commands run in. It returns how many paths it replaced.
+func (c *Content) ReplacePath(old, new string) int {
+	n := 0
+	replace := func(path string) string {
+		switch {
+		case path == old:
+		case strings.HasPrefix(path, old) && (strings.HasSuffix(old, "/") || path[len(old)] == '/'):
+		default:
+			return path
+		}
+		n++
+		return new + path[len(old):]
+	}
+
+	c.CurrentDir = replace(c.CurrentDir)
+	for _, w := range c.Windows {
+		w.ExecDir = replace(w.ExecDir)
+		name := w.Name()
+		if name == "" {
+			continue
+		}
+		newname := replace(name)
+		w.Tag.Buffer = newname + w.Tag.Buffer[len(name):]
+
+		// Keep the selection on the same text of the tag.
+		end, d := len([]rune(name)), len([]rune(newname))-len([]rune(name))
+		if w.Tag.Q0 >= end {
+			w.Tag.Q0 += d
+		}
+		if w.Tag.Q1 >= end {
+			w.Tag.Q1 += d
+		}
+	}
+	return n
+}
@@ -0,0 +1,82 @@
+package dumpfile
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	for _, file := range []string{"basic.dump", "onecol.dump", "zerox.dump"} {
+		c, err := LoadLegacy(filepath.Join("testdata", "legacy", file), "/home/gopher")
+		if err != nil {
+			t.Fatalf("can't load %v: %v", file, err)
+		}
+		if errs := c.Validate(); errs != nil {
+			t.Errorf("%v has problems: %v", file, errs)
+		}
+	}
+
+	c := &Content{
+		CurrentDir: "/home/gopher",
+		Columns: []Column{
+			{Position: 50},
+			{Position: 20, Width: -1},
+		},
+		Windows: []*Window{
+			{Type: Saved, Column: 2, Tag: Text{Buffer: "/home/gopher/a.go Del Snarf | Look"}},
+			{Type: Unsaved, Tag: Text{Buffer: "/home/gopher/b Del"}, Body: Text{Buffer: "ab", Q0: 1, Q1: 3}},
+			{Type: Saved, Tag: Text{Buffer: "/home/gopher/c Del | Look"}, Gutter: "sideways"},
+			{Type: Exec},
+			{Type: 7},
+			{Type: Exec, ExecCommand: "win", ExecEnv: []string{"PATH=/bin", "=x"}, Restart: "sometimes"},
+		},
+	}
+	var got []string
+	for _, err := range c.Validate() {
+		got = append(got, err.Error())
+	}
+	want := []string{
+		"column 1: position 20 is left of the column before it",
+		"column 1: width -1 is negative",
+		"window 0: no column 2",
+		`window 1: tag "/home/gopher/b Del" has no name followed by a |`,
+		"window 1: selection 1,3 is beyond the 2 runes of the body",
+		`window 2: bad gutter "sideways"`,
+		"window 3: no command to execute",
+		"window 4: unknown type 7",
+		`window 5: bad restart "sometimes"`,
+		`window 5: bad environment variable "=x"`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("problems are\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestReplacePath(t *testing.T) {
+	c := &Content{
+		CurrentDir: "/home/gopher/src/edwood",
+		Windows: []*Window{
+			{Tag: Text{Buffer: "/home/gopher/src/edwood/ Del Snarf Get | Look", Q0: 24, Q1: 27}},
+			{Tag: Text{Buffer: "/home/gopher/src/edwood/acme.go Del Snarf | Look", Q0: 2, Q1: 33}},
+			{Tag: Text{Buffer: "/home/gopher/src/edwood2/acme.go Del Snarf | Look"}},
+			{Type: Exec, ExecDir: "/home/gopher/src/edwood", ExecCommand: "win"},
+		},
+	}
+	if n := c.ReplacePath("/home/gopher/src/edwood", "/src/ed"); n != 4 {
+		t.Errorf("replaced %v paths; want 4", n)
+	}
+	want := &Content{
+		CurrentDir: "/src/ed",
+		Windows: []*Window{
+			{Tag: Text{Buffer: "/src/ed/ Del Snarf Get | Look", Q0: 8, Q1: 11}},
+			{Tag: Text{Buffer: "/src/ed/acme.go Del Snarf | Look", Q0: 2, Q1: 17}},
+			{Tag: Text{Buffer: "/home/gopher/src/edwood2/acme.go Del Snarf | Look"}},
+			{Type: Exec, ExecDir: "/src/ed", ExecCommand: "win"},
+		},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("content is %#v; want %#v", c, want)
+	}
+}
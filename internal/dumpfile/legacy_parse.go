@@ -184,6 +184,7 @@ func LoadLegacy(file, home string) (*Content, error) {
 		switch {
 		case l == "":
 			// We've reached the end.
+			dc.upgrade1()
 			return dc, nil
 		case l[0] == 'c':
 			// Column header.
@@ -141,6 +141,7 @@ func TestLegacyLoad(t *testing.T) {
 						},
 						ExecDir:     "",
 						ExecCommand: "",
+						TagExpand:   true,
 					},
 				},
 			},
@@ -185,6 +186,8 @@ func TestLegacyLoad(t *testing.T) {
 						},
 						ExecDir:     "",
 						ExecCommand: "",
+						TagExpand:   true,
+						FileMenu:    true,
 					},
 					{
 						Type:     Exec,
@@ -203,6 +206,8 @@ func TestLegacyLoad(t *testing.T) {
 						},
 						ExecDir:     "/Users/rjkroege/tools/gopkg/src/github.com/rjkroege/edwood/",
 						ExecCommand: "win",
+						TagExpand:   true,
+						FileMenu:    true,
 					},
 					{
 						Type:     Saved,
@@ -221,6 +226,8 @@ func TestLegacyLoad(t *testing.T) {
 						},
 						ExecDir:     "",
 						ExecCommand: "",
+						TagExpand:   true,
+						FileMenu:    true,
 					},
 				},
 			},
@@ -245,11 +252,11 @@ func TestLegacyLoad(t *testing.T) {
 						Font: "/lib/font/bit/lucsans/euro.8.font",
 						Tag: Text{
 							Buffer: "/Users/rjkroege/tools/gopkg/src/github.com/rjkroege/edwood/acme.go Del Snarf | Look Edit ",
-							Q0:     0, Q1: 0}, Body: Text{Buffer: "", Q0: 0, Q1: 0}, ExecDir: "", ExecCommand: ""},
-					{Type: 2, Column: 0, Position: 45.716244, Font: "/lib/font/bit/lucsans/euro.8.font", Tag: Text{Buffer: "/Users/rjkroege/tools/gopkg/src/github.com/rjkroege/edwood/acme.go Del Snarf | Look Edit ", Q0: 0, Q1: 0}, Body: Text{Buffer: "", Q0: 0, Q1: 0}, ExecDir: "", ExecCommand: ""},
-					{Type: 2, Column: 0, Position: 87.114462, Font: "/lib/font/bit/lucsans/euro.8.font", Tag: Text{Buffer: "/Users/rjkroege/tools/gopkg/src/github.com/rjkroege/edwood/acme.go Del Snarf | Look Edit ", Q0: 0, Q1: 0}, Body: Text{Buffer: "", Q0: 0, Q1: 0}, ExecDir: "", ExecCommand: ""},
-					{Type: 0, Column: 1, Position: 2.2618232, Font: "/lib/font/bit/lucsans/euro.8.font", Tag: Text{Buffer: "/Users/rjkroege/tools/gopkg/src/github.com/rjkroege/edwood/ Del Snarf Get | Look Edit ", Q0: 0, Q1: 0}, Body: Text{Buffer: "", Q0: 0, Q1: 0}, ExecDir: "", ExecCommand: ""},
-					{Type: 3, Column: 1, Position: 0, Font: "", Tag: Text{Buffer: "", Q0: 0, Q1: 0}, Body: Text{Buffer: "", Q0: 0, Q1: 0}, ExecDir: "/Users/rjkroege/tools/gopkg/src/github.com/rjkroege/edwood/", ExecCommand: "win"},
+							Q0:     0, Q1: 0}, Body: Text{Buffer: "", Q0: 0, Q1: 0}, ExecDir: "", ExecCommand: "", TagExpand: true, FileMenu: true},
+					{Type: 2, Column: 0, Position: 45.716244, Font: "/lib/font/bit/lucsans/euro.8.font", Tag: Text{Buffer: "/Users/rjkroege/tools/gopkg/src/github.com/rjkroege/edwood/acme.go Del Snarf | Look Edit ", Q0: 0, Q1: 0}, Body: Text{Buffer: "", Q0: 0, Q1: 0}, ExecDir: "", ExecCommand: "", TagExpand: true, FileMenu: true},
+					{Type: 2, Column: 0, Position: 87.114462, Font: "/lib/font/bit/lucsans/euro.8.font", Tag: Text{Buffer: "/Users/rjkroege/tools/gopkg/src/github.com/rjkroege/edwood/acme.go Del Snarf | Look Edit ", Q0: 0, Q1: 0}, Body: Text{Buffer: "", Q0: 0, Q1: 0}, ExecDir: "", ExecCommand: "", TagExpand: true, FileMenu: true},
+					{Type: 0, Column: 1, Position: 2.2618232, Font: "/lib/font/bit/lucsans/euro.8.font", Tag: Text{Buffer: "/Users/rjkroege/tools/gopkg/src/github.com/rjkroege/edwood/ Del Snarf Get | Look Edit ", Q0: 0, Q1: 0}, Body: Text{Buffer: "", Q0: 0, Q1: 0}, ExecDir: "", ExecCommand: "", TagExpand: true},
+					{Type: 3, Column: 1, Position: 0, Font: "", Tag: Text{Buffer: "", Q0: 0, Q1: 0}, Body: Text{Buffer: "", Q0: 0, Q1: 0}, ExecDir: "/Users/rjkroege/tools/gopkg/src/github.com/rjkroege/edwood/", ExecCommand: "win", TagExpand: true, FileMenu: true},
 					{Type: 1, Column: 1, Position: 68.6086361, Font: "/lib/font/bit/lucsans/euro.8.font",
 						Tag: Text{
 							Buffer: "/+Errors Del Snarf | Look Edit ",
@@ -257,7 +264,7 @@ func TestLegacyLoad(t *testing.T) {
 						Body: Text{
 							Buffer: "hi\n/Users/rjkroege/tools/gopkg/src/github.com/rjkroege/edwood/-Gubaidulina modified\n",
 							Q0:     3, Q1: 84},
-						ExecDir: "", ExecCommand: ""}}},
+						ExecDir: "", ExecCommand: "", TagExpand: true, FileMenu: true, Scratch: true}}},
 			parseerror: "",
 		},
 
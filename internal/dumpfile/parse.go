@@ -10,9 +10,13 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 )
 
-const version = 1
+// version is the format of dump files written by Save. Format 1 lacked
+// the scroll position, column widths and settings of windows; Load
+// migrates it to the current one.
+const version = 2
 
 // WindowType defines the type of window.
 type WindowType int
@@ -24,6 +28,24 @@ const (
 	Exec                      // Exec is a window controlled by an outside process
 )
 
+// Restart is when Edwood runs the command of an Exec window again.
+type Restart string
+
+const (
+	RestartOnLoad Restart = ""       // RestartOnLoad runs the command when the dump is loaded
+	RestartNever  Restart = "never"  // RestartNever leaves the command not running
+	RestartAlways Restart = "always" // RestartAlways also runs the command again whenever it exits
+)
+
+var windowTypeNames = []string{"saved", "unsaved", "zerox", "exec"}
+
+func (t WindowType) String() string {
+	if t < Saved || t > Exec {
+		return fmt.Sprintf("WindowType(%d)", int(t))
+	}
+	return windowTypeNames[t]
+}
+
 // Content stores the state of Edwood.
 type Content struct {
 	CurrentDir string    // Edwood's current working directory
@@ -37,6 +59,7 @@ type Content struct {
 // Column stores the state of a column in Edwood.
 type Column struct {
 	Position float64 // Position within the row (in percentage)
+	Width    int     `json:",omitempty"` // Width in pixels, used if the row is as wide as when dumped
 	Tag      Text    // Tag above the column (usually "New ... Delcol")
 }
 
@@ -62,8 +85,20 @@ type Window struct {
 	Body Text
 
 	// Used for Type == Exec
-	ExecDir     string `json:",omitempty"` // Execute command in this directory
-	ExecCommand string `json:",omitempty"` // Command to execute
+	ExecDir     string   `json:",omitempty"` // Execute command in this directory
+	ExecCommand string   `json:",omitempty"` // Command to execute
+	ExecEnv     []string `json:",omitempty"` // Environment of the command, as NAME=value, over Edwood's
+	Restart     Restart  `json:",omitempty"` // When to run the command again
+
+	Gutter string `json:",omitempty"` // Line numbers shown beside the body: "absolute" or "relative"
+	NoWrap bool   `json:",omitempty"` // Long lines of the body are clipped instead of folded
+
+	Origin     int  `json:",omitempty"` // Rune position of the body shown at the top of the window
+	TagExpand  bool `json:",omitempty"` // Tag shows all its lines
+	AutoIndent bool `json:",omitempty"` // New lines of the body are indented like the previous
+	TabWidth   int  `json:",omitempty"` // Width of a tab in the body, in zeros; 0 for the default
+	FileMenu   bool `json:",omitempty"` // Tag shows Undo, Redo and Put as the body changes
+	Scratch    bool `json:",omitempty"` // Deleting the window doesn't warn of unsaved changes
 }
 
 // Text is a UTF-8 encoded text with a substring selected
@@ -97,12 +132,28 @@ func decode(r io.Reader) (*Content, error) {
 	if err != nil {
 		return nil, err
 	}
-	if vc.Version != version {
+	switch vc.Version {
+	case 1:
+		vc.Content.upgrade1()
+	case version:
+	default:
 		return nil, fmt.Errorf("dump file format %v; expected %v", vc.Version, version)
 	}
 	return vc.Content, nil
 }
 
+// upgrade1 migrates content of format 1, or of the legacy format, to
+// the current format, giving each window the settings that a window
+// restored from such a dump file would have had.
+func (c *Content) upgrade1() {
+	for _, w := range c.Windows {
+		name := strings.SplitN(w.Tag.Buffer, " ", 2)[0]
+		w.TagExpand = true
+		w.FileMenu = !strings.HasSuffix(name, "/") && !strings.HasSuffix(name, `\`) // not a directory
+		w.Scratch = strings.HasSuffix(name, "/guide") || strings.HasSuffix(name, "+Errors")
+	}
+}
+
 // Save encodes the dump file content and writes it to file.
 func (c *Content) Save(file string) error {
 	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
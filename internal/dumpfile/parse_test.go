@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -80,3 +81,89 @@ func TestSaveLoad(t *testing.T) {
 		t.Errorf("content is %#v; expected %#v\n", c, tc)
 	}
 }
+
+func TestDecodeVersion1(t *testing.T) {
+	const dump = `{
+	"Version": 1,
+	"CurrentDir": "/home/gopher",
+	"Columns": [{"Position": 0, "Tag": {"Buffer": "New Delcol", "Q0": 0, "Q1": 0}}],
+	"Windows": [
+		{"Type": 0, "Column": 0, "Position": 0, "Tag": {"Buffer": "/home/gopher/a.go Del Snarf | Look", "Q0": 0, "Q1": 0}, "Body": {"Q0": 3, "Q1": 5}},
+		{"Type": 1, "Column": 0, "Position": 50, "Tag": {"Buffer": "/home/gopher/+Errors Del Snarf | Look", "Q0": 0, "Q1": 0}, "Body": {"Buffer": "hi\n", "Q0": 0, "Q1": 0}}
+	]
+}`
+	want := &Content{
+		CurrentDir: "/home/gopher",
+		Columns: []Column{
+			{Position: 0, Tag: Text{Buffer: "New Delcol"}},
+		},
+		Windows: []*Window{
+			{
+				Type:      Saved,
+				Tag:       Text{Buffer: "/home/gopher/a.go Del Snarf | Look"},
+				Body:      Text{Q0: 3, Q1: 5},
+				TagExpand: true,
+				FileMenu:  true,
+			},
+			{
+				Type:      Unsaved,
+				Position:  50,
+				Tag:       Text{Buffer: "/home/gopher/+Errors Del Snarf | Look"},
+				Body:      Text{Buffer: "hi\n"},
+				TagExpand: true,
+				FileMenu:  true,
+				Scratch:   true,
+			},
+		},
+	}
+	c, err := decode(strings.NewReader(dump))
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("content is %#v; expected %#v", c, want)
+	}
+
+	_, err = decode(strings.NewReader(`{"Version": 3}`))
+	if err == nil {
+		t.Errorf("decoded unknown version")
+	}
+}
+
+func TestEncodeDecodeVersion2(t *testing.T) {
+	want := &Content{
+		CurrentDir: "/home/gopher",
+		Columns: []Column{
+			{Position: 0, Width: 500, Tag: Text{Buffer: "New Delcol"}},
+		},
+		Windows: []*Window{
+			{
+				Type:       Saved,
+				Tag:        Text{Buffer: "/home/gopher/a.go Del Snarf | Look"},
+				Body:       Text{Q0: 3, Q1: 5},
+				Origin:     120,
+				AutoIndent: true,
+				TabWidth:   8,
+				FileMenu:   true,
+			},
+			{
+				Type:        Exec,
+				ExecDir:     "/home/gopher",
+				ExecCommand: "win",
+				ExecEnv:     []string{"PATH=/bin:/usr/bin", "SHELL=/bin/rc"},
+				Restart:     RestartAlways,
+			},
+		},
+	}
+	var b bytes.Buffer
+	if err := want.encode(&b); err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	c, err := decode(&b)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("content is %#v; expected %#v", c, want)
+	}
+}
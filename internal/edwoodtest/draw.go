@@ -15,6 +15,7 @@ var _ = draw.Display((*mockDisplay)(nil))
 // mockDisplay implements draw.Display.
 type mockDisplay struct {
 	snarfbuf []byte
+	scale    float64
 	mu       sync.Mutex
 }
 
@@ -22,13 +23,19 @@ type mockDisplay struct {
 func NewDisplay() draw.Display {
 	return &mockDisplay{}
 }
-func (d *mockDisplay) ScreenImage() draw.Image                 { return NewImage(image.Rect(0, 0, 800, 600)) }
-func (d *mockDisplay) White() draw.Image                       { return NewImage(image.Rectangle{}) }
-func (d *mockDisplay) Black() draw.Image                       { return NewImage(image.Rectangle{}) }
-func (d *mockDisplay) Opaque() draw.Image                      { return NewImage(image.Rectangle{}) }
-func (d *mockDisplay) Transparent() draw.Image                 { return NewImage(image.Rectangle{}) }
-func (d *mockDisplay) InitKeyboard() *draw.Keyboardctl         { return nil }
-func (d *mockDisplay) InitMouse() *draw.Mousectl               { return nil }
+func (d *mockDisplay) ScreenImage() draw.Image { return NewImage(image.Rect(0, 0, 800, 600)) }
+func (d *mockDisplay) White() draw.Image       { return NewImage(image.Rectangle{}) }
+func (d *mockDisplay) Black() draw.Image       { return NewImage(image.Rectangle{}) }
+func (d *mockDisplay) Opaque() draw.Image      { return NewImage(image.Rectangle{}) }
+func (d *mockDisplay) Transparent() draw.Image { return NewImage(image.Rectangle{}) }
+
+// InitKeyboard returns a Keyboardctl that never delivers a key.
+func (d *mockDisplay) InitKeyboard() *draw.Keyboardctl { return new(draw.Keyboardctl) }
+
+// InitMouse returns a Mousectl that never delivers a mouse event or a
+// resize.
+func (d *mockDisplay) InitMouse() *draw.Mousectl { return new(draw.Mousectl) }
+
 func (d *mockDisplay) OpenFont(name string) (draw.Font, error) { return NewFont(13, 10), nil }
 func (d *mockDisplay) AllocImage(r image.Rectangle, pix draw.Pix, repl bool, val draw.Color) (draw.Image, error) {
 	return &mockImage{r: r}, nil
@@ -38,7 +45,20 @@ func (d *mockDisplay) AllocImageMix(color1, color3 draw.Color) draw.Image {
 }
 func (d *mockDisplay) Attach(ref int) error { return nil }
 func (d *mockDisplay) Flush() error         { return nil }
-func (d *mockDisplay) ScaleSize(n int) int  { return 0 }
+func (d *mockDisplay) ScaleSize(n int) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.scale > 0 {
+		return int(float64(n)*d.scale + 0.5)
+	}
+	return 0
+}
+
+func (d *mockDisplay) SetScale(scale float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.scale = scale
+}
 
 // ReadSnarf reads the snarf buffer into buf, returning the number of bytes read,
 // the total size of the snarf buffer (useful if buf is too short), and any
@@ -106,6 +126,7 @@ func NewFont(width, height int) draw.Font {
 
 func (f *mockFont) Name() string             { return "/lib/font/edwood.font" }
 func (f *mockFont) Height() int              { return f.height }
+func (f *mockFont) Has(r rune) bool          { return true }
 func (f *mockFont) BytesWidth(b []byte) int  { return f.width * utf8.RuneCount(b) }
 func (f *mockFont) RunesWidth(r []rune) int  { return f.width * len(r) }
 func (f *mockFont) StringWidth(s string) int { return f.width * utf8.RuneCountInString(s) }
@@ -0,0 +1,93 @@
+package edwoodtest
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rjkroege/edwood/internal/draw"
+)
+
+// UpdateGoldenEnv is the environment variable that, when set, makes
+// CheckGolden write golden images instead of comparing with them.
+const UpdateGoldenEnv = "EDWOOD_UPDATE_GOLDEN"
+
+// Snapshot returns the pixels of the part r of the screen of display,
+// which must be able to take snapshots, like that of package memdraw.
+func Snapshot(t *testing.T, display draw.Display, r image.Rectangle) *image.RGBA {
+	t.Helper()
+	s, ok := display.ScreenImage().(draw.Snapshotter)
+	if !ok {
+		t.Fatalf("display %T can't take snapshots", display)
+	}
+	return s.Snapshot(r)
+}
+
+// CheckGolden compares the part r of the screen of display with the
+// PNG testdata/name.png, reporting an error if they differ. A copy
+// of what was drawn is then left in testdata/name.got.png. If
+// $EDWOOD_UPDATE_GOLDEN is set, the golden image is rewritten instead.
+func CheckGolden(t *testing.T, display draw.Display, r image.Rectangle, name string) {
+	t.Helper()
+	got := Snapshot(t, display, r)
+	file := filepath.Join("testdata", name+".png")
+	gotfile := filepath.Join("testdata", name+".got.png")
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, got); err != nil {
+		t.Fatalf("can't encode %v: %v", name, err)
+	}
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(gotfile)
+		return
+	}
+	want, err := readpng(file)
+	if err != nil {
+		t.Fatalf("can't read golden image (set $%v to write it): %v", UpdateGoldenEnv, err)
+	}
+	if p, ok := samepixels(got, want); !ok {
+		ioutil.WriteFile(gotfile, buf.Bytes(), 0644)
+		t.Errorf("%v differs from %v at %v; got %v", name, file, p, gotfile)
+		return
+	}
+	os.Remove(gotfile)
+}
+
+func readpng(name string) (image.Image, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+// samepixels returns whether a and b have the same size and colours,
+// and if not, the first point of a where they differ.
+func samepixels(a *image.RGBA, b image.Image) (image.Point, bool) {
+	ar, br := a.Bounds(), b.Bounds()
+	if ar.Size() != br.Size() {
+		return ar.Min, false
+	}
+	d := br.Min.Sub(ar.Min)
+	for y := ar.Min.Y; y < ar.Max.Y; y++ {
+		for x := ar.Min.X; x < ar.Max.X; x++ {
+			r0, g0, b0, a0 := a.At(x, y).RGBA()
+			r1, g1, b1, a1 := b.At(x+d.X, y+d.Y).RGBA()
+			if r0 != r1 || g0 != g1 || b0 != b1 || a0 != a1 {
+				return image.Pt(x, y), false
+			}
+		}
+	}
+	return image.Point{}, true
+}
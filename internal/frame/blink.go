@@ -0,0 +1,73 @@
+package frame
+
+import (
+	"sync"
+	"time"
+)
+
+// blinkpoll is how often the ticks of blinking frames are checked.
+const blinkpoll = 50 * time.Millisecond
+
+// blinker holds the frames whose ticks blink. A single goroutine,
+// started the first time a tick blinks, shows and hides them.
+var blinker struct {
+	sync.Mutex
+	frames map[*frameimpl]bool
+	wake   chan bool
+}
+
+// startblink makes the tick of f blink.
+func startblink(f *frameimpl) {
+	blinker.Lock()
+	defer blinker.Unlock()
+	if blinker.frames == nil {
+		blinker.frames = make(map[*frameimpl]bool)
+		blinker.wake = make(chan bool, 1)
+		go blinkloop()
+	}
+	blinker.frames[f] = true
+	select {
+	case blinker.wake <- true:
+	default:
+	}
+}
+
+// stopblink stops the tick of f blinking.
+func stopblink(f *frameimpl) {
+	blinker.Lock()
+	defer blinker.Unlock()
+	delete(blinker.frames, f)
+}
+
+func blinkloop() {
+	for {
+		blinker.Lock()
+		frames := make([]*frameimpl, 0, len(blinker.frames))
+		for f := range blinker.frames {
+			frames = append(frames, f)
+		}
+		blinker.Unlock()
+
+		if len(frames) == 0 {
+			<-blinker.wake
+			continue
+		}
+		time.Sleep(blinkpoll)
+		for _, f := range frames {
+			f.blinktick(time.Now())
+		}
+	}
+}
+
+// blinktick shows or hides the tick of f if it has been hidden or shown
+// for long enough at time now.
+func (f *frameimpl) blinktick(now time.Time) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	if !f.ticked || f.blink <= 0 || now.Sub(f.ticktime) < f.blink {
+		return
+	}
+	f.drawtick(!f.tickon)
+	f.ticktime = now
+	f.display.Flush()
+}
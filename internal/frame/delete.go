@@ -21,6 +21,9 @@ func (f *frameimpl) deleteimpl(p0, p1 int) int {
 	if p0 >= f.nchars || p0 == p1 || f.background == nil {
 		return 0
 	}
+	if f.nowrap {
+		defer f.redrawnowrap(p0)
+	}
 
 	n0 := f.findbox(0, 0, p0)
 	if n0 == len(f.box) {
@@ -98,7 +101,9 @@ func (f *frameimpl) deleteimpl(p0, p1 int) int {
 		pt1 = f.advance(pt1, b)
 		// newwid updates b with the value computed by newwid0.
 		// TODO(rjk): make the code cleaner with a side-effect free version.
-		pt0.X += f.newwid(pt0, b)
+		w := f.newwid(pt0, b)
+		f.drawblank(pt0, b, f.cols[ColBack])
+		pt0.X += w
 		f.box[n0] = f.box[n1]
 		n0++
 		n1++
@@ -2,6 +2,7 @@ package frame
 
 import (
 	"image"
+	"time"
 
 	"github.com/rjkroege/edwood/internal/draw"
 )
@@ -13,7 +14,13 @@ func (f *frameimpl) drawtext(pt image.Point, text draw.Image, back draw.Image) {
 		// log.Printf("box [%d] %#v pt %v NoRedraw %v nrune %d\n",  nb, string(b.Ptr), pt, f.NoRedraw, b.Nrune)
 
 		if !f.noredraw && b.Nrune >= 0 {
-			f.background.Bytes(pt, text, image.Point{}, f.font, b.Ptr)
+			btext, bback := f.boxcolours(b, text, back)
+			if bback != back {
+				f.fillbox(pt, b.Wid, bback)
+			}
+			f.drawboxtext(pt, b, b.Ptr, b.Wid, btext)
+		} else if !f.noredraw {
+			f.drawblank(pt, b, back)
 		}
 		pt.X += b.Wid
 	}
@@ -149,16 +156,19 @@ func (f *frameimpl) Drawsel0(pt image.Point, p0, p1 int, back draw.Image, text d
 		if b.Nrune < 0 || nr == b.Nrune {
 			w = b.Wid
 		} else {
-			w = f.font.BytesWidth(ptr[0:runeindex(ptr, nr)])
+			w = f.boxfont(b).BytesWidth(ptr[0:runeindex(ptr, nr)])
 		}
 		x = pt.X + w
 		if x > f.rect.Max.X {
 			x = f.rect.Max.X
 		}
 		// f.drawBox(image.Rect(pt.X, pt.Y, x, pt.Y+f.Font.DefaultHeight()), text, back, pt)
-		f.background.Draw(image.Rect(pt.X, pt.Y, x, pt.Y+f.defaultfontheight), back, nil, pt)
+		btext, bback := f.boxcolours(b, text, back)
+		f.background.Draw(image.Rect(pt.X, pt.Y, x, pt.Y+f.defaultfontheight), bback, nil, pt)
 		if b.Nrune >= 0 {
-			f.background.Bytes(pt, text, image.Point{}, f.font, ptr[0:runeindex(ptr, nr)])
+			f.drawboxtext(pt, b, ptr[0:runeindex(ptr, nr)], x-pt.X, btext)
+		} else {
+			f.drawblank(pt, b, bback)
 		}
 		pt.X += w
 		p += nr
@@ -194,30 +204,26 @@ func (f *frameimpl) Redraw(enclosing image.Rectangle) {
 	f.lk.Lock()
 	defer f.lk.Unlock()
 	// log.Printf("Redraw %v %v", f.Rect, enclosing)
-	f.background.Draw(enclosing, f.cols[ColBack], nil, image.Point{})
+	unclip(f.background).Draw(enclosing, f.cols[ColBack], nil, image.Point{})
 }
 
 func (f *frameimpl) tick(pt image.Point, ticked bool) {
 	//	log.Println("_tick")
-	if f.ticked == ticked || f.tickimage == nil || !pt.In(f.rect) {
+	if f.ticked == ticked || f.tickimage == nil || (ticked && !pt.In(f.rect)) {
 		return
 	}
 
-	pt.X -= f.tickscale
-	r := image.Rect(pt.X, pt.Y, pt.X+frtickw*f.tickscale, pt.Y+f.defaultfontheight)
-
-	if r.Max.X > f.rect.Max.X {
-		r.Max.X = f.rect.Max.X
-	}
-
 	if ticked {
-		f.tickback.Draw(f.tickback.R(), f.background, nil, pt)
-		f.background.Draw(r, f.display.Black(), f.tickimage, image.Point{}) // draws an alpha-blended box
-	} else {
-		// There is an issue with tick management
-		f.background.Draw(r, f.tickback, nil, image.Point{})
+		f.tickrect = f.tickrectat(pt)
+		f.ticktime = time.Now()
 	}
+	f.drawtick(ticked)
 	f.ticked = ticked
+	if ticked && f.blink > 0 {
+		startblink(f)
+	} else {
+		stopblink(f)
+	}
 }
 
 // Tick draws (if up is non-zero) or removes (if up is zero) the tick
@@ -3,6 +3,7 @@ package frame
 import (
 	"image"
 	"sync"
+	"time"
 
 	"github.com/rjkroege/edwood/internal/draw"
 )
@@ -82,6 +83,9 @@ type Frame interface {
 	//
 	// Changing the background or font will force the tick to be
 	// recreated.
+	//
+	// With OptNoWrap, each line is laid out on a single row and
+	// clipped at the edges of r instead of being folded.
 	Init(image.Rectangle, ...OptionClosure)
 
 	// Clear frees the internal structures associated with f, permitting
@@ -158,6 +162,12 @@ type Frame interface {
 	// multiple calls to DrawSel with highlighted false will be cheap.
 	// TODO(rjk): DrawSel does more drawing work than necessary.
 	DrawSel(image.Point, int, int, bool)
+
+	// SetStyle redraws the runes between p0 and p1 with style s, or with
+	// the default colours and font if s is nil. The style stays attached
+	// to the runes: it moves with them when text is inserted or deleted
+	// before them, and runes inserted later are unstyled.
+	SetStyle(p0, p1 int, s *Style)
 }
 
 // TODO(rjk): Consider calling this SetMaxtab?
@@ -218,7 +228,7 @@ func (f *frameimpl) IsLastLineFull() bool {
 func (f *frameimpl) Rect() image.Rectangle {
 	f.lk.Lock()
 	defer f.lk.Unlock()
-	return f.rect
+	return f.clip
 }
 
 // TODO(rjk): no need for this to have public fields.
@@ -229,6 +239,7 @@ type frbox struct {
 	Ptr    []byte // UTF-8 string in this box.
 	Bc     rune   // The kind of special layout box: '\n' or '\t'
 	Minwid byte
+	Style  *Style // How to draw the box or nil for the default style.
 }
 
 // Helpful code for debugging reentrancy.
@@ -259,7 +270,8 @@ type frameimpl struct {
 	display    draw.Display           // on which the frame is displayed
 	background draw.Image             // on which the frame appears
 	cols       [NumColours]draw.Image // background and text colours
-	rect       image.Rectangle        // in which the text appears
+	rect       image.Rectangle        // in which the text is laid out
+	clip       image.Rectangle        // in which the text appears
 
 	defaultfontheight int // height of default font
 
@@ -275,15 +287,28 @@ type frameimpl struct {
 	lastlinefull bool
 	modified     bool
 
-	tickimage   draw.Image // typing tick
-	tickback    draw.Image // image under tick
-	ticked      bool       // Is the tick on.
-	highlighton bool       // True if the highlight is painted.
+	tickimage   draw.Image      // typing tick
+	tickback    draw.Image      // image under tick
+	ticked      bool            // Is the tick on.
+	highlighton bool            // True if the highlight is painted.
+	tickstyle   TickStyle       // how the tick is drawn
+	tickrect    image.Rectangle // where the tick is drawn
+	tickon      bool            // Is the tick drawn. It is not while it blinks off.
+	ticktime    time.Time       // when the tick was last drawn or hidden
+	blink       time.Duration   // how long the tick is shown and hidden for when blinking
 
 	// Set this to true to indicate that the Frame should not emit drawing ops.
 	// Use this if the Frame is being used "headless" to measure some text.
 	noredraw  bool
 	tickscale int // tick scaling factor
+
+	// Set nowrap to lay out each line on a single row, scrolled xoff
+	// pixels to the left, instead of folding it.
+	nowrap bool
+	xoff   int
+
+	// Blanks are made visible in colour whitespace unless it is nil.
+	whitespace draw.Image
 }
 
 // NewFrame creates a new Frame with Font ft, background image b, colours cols, and
@@ -312,6 +337,11 @@ func (f *frameimpl) Init(r image.Rectangle, opts ...OptionClosure) {
 	f.box = nil
 	f.lastlinefull = false
 
+	// The frame is drawn afresh after Init so the tick is gone.
+	f.ticked = false
+	f.tickon = false
+	stopblink(f)
+
 	// Update additional options. The values are optional so that the frame
 	// will re-use the existing values if new ones are not provided.
 	ctx := f.Option(opts...)
@@ -321,6 +351,11 @@ func (f *frameimpl) Init(r image.Rectangle, opts ...OptionClosure) {
 	f.maxtab = ctx.computemaxtab(f.maxtab, f.font.StringWidth("0"))
 	f.setrects(r)
 
+	f.background = unclip(f.background)
+	if f.nowrap && f.background != nil {
+		f.background = &clipimage{f.background, f.clip}
+	}
+
 	if ctx.updatetick || (f.tickimage == nil && f.cols[ColBack] != nil) {
 		f.InitTick()
 	}
@@ -332,17 +367,24 @@ func (f *frameimpl) setrects(r image.Rectangle) {
 	f.rect = r
 	f.rect.Max.Y -= (r.Max.Y - r.Min.Y) % height
 	f.maxlines = (r.Max.Y - r.Min.Y) / height
+	f.clip = f.rect
+	if f.nowrap {
+		f.rect.Min.X -= f.xoff
+		f.rect.Max.X = f.rect.Min.X + nowrapwidth
+	}
 }
 
 func (f *frameimpl) Clear(freeall bool) {
 	f.lk.Lock()
 	defer f.lk.Unlock()
 	f.box = make([]*frbox, 0, 25)
-	if freeall {
+	if freeall && f.tickimage != nil { // none without a background colour
 		f.tickimage.Free()
 		f.tickback.Free()
 		f.tickimage = nil
 		f.tickback = nil
 	}
 	f.ticked = false
+	f.tickon = false
+	stopblink(f)
 }
@@ -67,6 +67,16 @@ func OptMaxTab(maxtabchars int) OptionClosure {
 	}
 }
 
+// OptNoWrap sets whether each line is laid out on a single row,
+// clipped at the edges of the frame, instead of being folded. The
+// text is scrolled xoff pixels to the left.
+func OptNoWrap(nowrap bool, xoff int) OptionClosure {
+	return func(f *frameimpl, ctx *optioncontext) {
+		f.nowrap = nowrap
+		f.xoff = xoff
+	}
+}
+
 // computemaxtab returns the new ftw value
 func (ctx *optioncontext) computemaxtab(maxtab, ftw int) int {
 	if ctx.maxtabchars < 0 {
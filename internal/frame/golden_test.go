@@ -0,0 +1,48 @@
+package frame
+
+import (
+	"image"
+	"testing"
+
+	"github.com/rjkroege/edwood/internal/draw"
+	"github.com/rjkroege/edwood/internal/draw/memdraw"
+	"github.com/rjkroege/edwood/internal/edwoodtest"
+)
+
+// newGoldenFrame returns a frame drawing in lucsans on the screen of
+// an in-memory display, in acme's colours.
+func newGoldenFrame(t *testing.T, r image.Rectangle) (*frameimpl, draw.Display) {
+	t.Helper()
+	d := memdraw.NewDisplay(r)
+	ft, err := d.OpenFont("../../build/font/lucsans/euro.8.font")
+	if err != nil {
+		t.Fatalf("can't open font: %v", err)
+	}
+	var cols [NumColours]draw.Image
+	cols[ColBack] = d.AllocImageMix(draw.Paleyellow, draw.White)
+	cols[ColHigh], _ = d.AllocImage(image.Rect(0, 0, 1, 1), d.ScreenImage().Pix(), true, draw.Darkyellow)
+	cols[ColBord], _ = d.AllocImage(image.Rect(0, 0, 1, 1), d.ScreenImage().Pix(), true, draw.Yellowgreen)
+	cols[ColText] = d.Black()
+	cols[ColHText] = d.Black()
+	return NewFrame(r, ft, d.ScreenImage(), cols).(*frameimpl), d
+}
+
+func TestGoldenFrame(t *testing.T) {
+	r := image.Rect(0, 0, 160, 60)
+	for _, tc := range []struct {
+		name   string
+		text   string
+		p0, p1 int
+	}{
+		{"frame-text", "hello, world\n\tindented\n", 0, 0},
+		{"frame-wrap", "a line long enough to be wrapped by the frame", 2, 6},
+		{"frame-selection", "select\nacross lines\n", 3, 10},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, d := newGoldenFrame(t, r)
+			f.Insert([]rune(tc.text), 0)
+			f.DrawSel(f.Ptofchar(tc.p0), tc.p0, tc.p1, true)
+			edwoodtest.CheckGolden(t, d, r, tc.name)
+		})
+	}
+}
@@ -11,7 +11,9 @@ var (
 	TMPSIZE = 256
 )
 
-func (f *frameimpl) bxscan(r []rune, ppt *image.Point) (image.Point, *frameimpl) {
+// bxscan lays out r, in style s, in a new frame as if inserted at *ppt
+// in f.
+func (f *frameimpl) bxscan(r []rune, ppt *image.Point, s *Style) (image.Point, *frameimpl) {
 	var c rune
 
 	frame := &frameimpl{
@@ -21,6 +23,7 @@ func (f *frameimpl) bxscan(r []rune, ppt *image.Point) (image.Point, *frameimpl)
 		font:              f.font,
 		defaultfontheight: f.defaultfontheight,
 		maxtab:            f.maxtab,
+		whitespace:        f.whitespace,
 		nchars:            0,
 		box:               []*frbox{},
 	}
@@ -40,6 +43,7 @@ func (f *frameimpl) bxscan(r []rune, ppt *image.Point) (image.Point, *frameimpl)
 				Wid:    10000,
 				Minwid: byte(frame.font.StringWidth(" ")),
 				Nrune:  -1,
+				Style:  s,
 			})
 
 			frame.nchars++
@@ -50,15 +54,20 @@ func (f *frameimpl) bxscan(r []rune, ppt *image.Point) (image.Point, *frameimpl)
 				Wid:    10000,
 				Minwid: 0,
 				Nrune:  -1,
+				Style:  s,
 			})
 
 			frame.nchars++
 			offs++
 			nl++
 		default:
-			s := 0
+			n := 0
 			nr := 0
 			w := 0
+			font := frame.font
+			if s != nil && s.Font != nil {
+				font = s.Font
+			}
 
 			tmp := make([]byte, TMPSIZE+3)
 			for offs < len(r) {
@@ -66,23 +75,24 @@ func (f *frameimpl) bxscan(r []rune, ppt *image.Point) (image.Point, *frameimpl)
 				if c == '\t' || c == '\n' {
 					break
 				}
-				rw := utf8.EncodeRune(tmp[s:], c)
-				if s+rw >= TMPSIZE {
+				rw := utf8.EncodeRune(tmp[n:], c)
+				if n+rw >= TMPSIZE {
 					break
 				}
-				w += frame.font.RunesWidth(r[offs : offs+1])
+				w += font.RunesWidth(r[offs : offs+1])
 
 				offs++
-				s += rw
+				n += rw
 				nr++
 			}
-			p := make([]byte, s)
-			copy(p, tmp[:s])
+			p := make([]byte, n)
+			copy(p, tmp[:n])
 
 			frame.box = append(frame.box, &frbox{
 				Ptr:   p,
 				Wid:   w,
 				Nrune: nr,
+				Style: s,
 			})
 			frame.nchars += nr
 		}
@@ -125,10 +135,11 @@ var nalloc = 0
 func (f *frameimpl) Insert(r []rune, p0 int) bool {
 	f.lk.Lock()
 	defer f.lk.Unlock()
-	return f.insertimpl(r, p0)
+	return f.insertimpl(r, p0, nil)
 }
 
-func (f *frameimpl) insertimpl(r []rune, p0 int) bool {
+// insertimpl inserts r, in style s, at p0.
+func (f *frameimpl) insertimpl(r []rune, p0 int, s *Style) bool {
 	// log.Printf("frame.Insert. Start: %s", string(r))
 	// defer log.Println("frame.Insert end")
 	//	f.Logboxes("at very start of insert")
@@ -139,6 +150,9 @@ func (f *frameimpl) insertimpl(r []rune, p0 int) bool {
 	if p0 > f.nchars || len(r) == 0 || f.background == nil {
 		return f.lastlinefull
 	}
+	if f.nowrap {
+		defer f.redrawnowrap(p0)
+	}
 
 	col := f.cols[ColBack]
 	tcol := f.cols[ColText]
@@ -158,7 +172,7 @@ func (f *frameimpl) insertimpl(r []rune, p0 int) bool {
 	pt0 := f.ptofcharnb(p0, n0)
 	ppt0 := pt0
 	opt0 := pt0
-	pt1, nframe := f.bxscan(r, &ppt0)
+	pt1, nframe := f.bxscan(r, &ppt0, s)
 	ppt1 := pt1
 
 	if n0 < len(f.box) {
@@ -309,6 +323,7 @@ func (f *frameimpl) insertimpl(r []rune, p0 int) bool {
 			}
 			cn0--
 			f.background.Draw(rect, col, nil, rect.Min)
+			f.drawblank(pt, b, col)
 			y = 0
 			if pt.X == f.rect.Min.X {
 				y = pt.Y
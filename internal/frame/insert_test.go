@@ -65,7 +65,7 @@ func TestBxscan(t *testing.T) {
 			},
 			func(f *frameimpl) (image.Point, image.Point, *frameimpl) {
 				pt1 := image.Pt(10, 15)
-				pt2, f := f.bxscan(mkRu("本"), &pt1)
+				pt2, f := f.bxscan(mkRu("本"), &pt1, nil)
 				return pt1, pt2, f
 			},
 			1,
@@ -82,7 +82,7 @@ func TestBxscan(t *testing.T) {
 			},
 			func(f *frameimpl) (image.Point, image.Point, *frameimpl) {
 				pt1 := image.Pt(56, 15)
-				pt2, f := f.bxscan(mkRu("本"), &pt1)
+				pt2, f := f.bxscan(mkRu("本"), &pt1, nil)
 				return pt1, pt2, f
 			},
 			1,
@@ -99,7 +99,7 @@ func TestBxscan(t *testing.T) {
 			},
 			func(f *frameimpl) (image.Point, image.Point, *frameimpl) {
 				pt1 := image.Pt(58, 15)
-				pt2, f := f.bxscan(mkRu("本"), &pt1)
+				pt2, f := f.bxscan(mkRu("本"), &pt1, nil)
 				return pt1, pt2, f
 			},
 			1,
@@ -116,7 +116,7 @@ func TestBxscan(t *testing.T) {
 			},
 			func(f *frameimpl) (image.Point, image.Point, *frameimpl) {
 				pt1 := image.Pt(56, 15)
-				pt2, f := f.bxscan(mkRu("本a"), &pt1)
+				pt2, f := f.bxscan(mkRu("本a"), &pt1, nil)
 				return pt1, pt2, f
 			},
 			2,
@@ -133,7 +133,7 @@ func TestBxscan(t *testing.T) {
 			},
 			func(f *frameimpl) (image.Point, image.Point, *frameimpl) {
 				pt1 := image.Pt(10, 15)
-				pt2, f := f.bxscan(mkRu(bigstring), &pt1)
+				pt2, f := f.bxscan(mkRu(bigstring), &pt1, nil)
 				return pt1, pt2, f
 			},
 			3,
@@ -0,0 +1,112 @@
+package frame
+
+import (
+	"image"
+
+	"github.com/rjkroege/edwood/internal/draw"
+)
+
+// nowrapwidth is the width of the rectangle in which a Frame that does
+// not wrap lays out its text: wide enough that no line is folded.
+const nowrapwidth = 1 << 24
+
+// clipimage is a draw.Image that draws on Image only inside clip. A
+// Frame that does not wrap lays out its text in a rectangle much wider
+// than the one in which the text appears and draws through a
+// clipimage to keep the text that is scrolled out of view off the
+// screen.
+type clipimage struct {
+	draw.Image
+	clip image.Rectangle
+}
+
+// unclip returns the image that i draws on.
+func unclip(i draw.Image) draw.Image {
+	if c, ok := i.(*clipimage); ok {
+		return c.Image
+	}
+	return i
+}
+
+func (i *clipimage) Draw(r image.Rectangle, src, mask draw.Image, p1 image.Point) {
+	cr := r.Intersect(i.clip)
+	if cr.Empty() {
+		return
+	}
+	i.Image.Draw(cr, unclip(src), unclip(mask), p1.Add(cr.Min.Sub(r.Min)))
+}
+
+func (i *clipimage) Border(r image.Rectangle, n int, color draw.Image, sp image.Point) {
+	if n < 0 {
+		r = r.Inset(n)
+		sp = sp.Add(image.Pt(n, n))
+		n = -n
+	}
+	i.Draw(image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+n), color, nil, sp)
+	i.Draw(image.Rect(r.Min.X, r.Max.Y-n, r.Max.X, r.Max.Y), color, nil, sp.Add(image.Pt(0, r.Dy()-n)))
+	i.Draw(image.Rect(r.Min.X, r.Min.Y+n, r.Min.X+n, r.Max.Y-n), color, nil, sp.Add(image.Pt(0, n)))
+	i.Draw(image.Rect(r.Max.X-n, r.Min.Y+n, r.Max.X, r.Max.Y-n), color, nil, sp.Add(image.Pt(r.Dx()-n, n)))
+}
+
+// Bytes draws text that straddles the edge of the clip rectangle on a
+// scratch image and copies the part that is inside.
+func (i *clipimage) Bytes(pt image.Point, src draw.Image, sp image.Point, f draw.Font, b []byte) image.Point {
+	end := pt.Add(image.Pt(f.BytesWidth(b), 0))
+	r := image.Rectangle{Min: pt, Max: end.Add(image.Pt(0, f.Height()))}
+	cr := r.Intersect(i.clip)
+	switch {
+	case cr.Empty():
+		return end
+	case cr == r:
+		i.Image.Bytes(pt, unclip(src), sp, f, b)
+		return end
+	}
+
+	scratch, err := i.Image.Display().AllocImage(r, i.Image.Pix(), false, draw.Nofill)
+	if err != nil {
+		return end
+	}
+	defer scratch.Free()
+	scratch.Draw(r, i.Image, nil, r.Min)
+	scratch.Bytes(pt, unclip(src), sp, f, b)
+	i.Image.Draw(cr, scratch, nil, cr.Min)
+	return end
+}
+
+// redrawnowrap draws again the lines of a Frame that does not wrap from
+// the one holding rune p0 to the bottom of the Frame. Insert and Delete
+// move text by copying it on the screen and the text that they would
+// move into view may be hidden so the lines that they change are drawn
+// afresh instead.
+func (f *frameimpl) redrawnowrap(p0 int) {
+	if f.noredraw || f.background == nil {
+		return
+	}
+	sp0, sp1 := f.sp0, f.sp1
+	highlighted := f.highlighton || f.ticked
+	f.drawselimpl(f.ptofcharptb(sp0, f.rect.Min, 0), sp0, sp1, false)
+
+	y := f.ptofcharptb(p0, f.rect.Min, 0).Y
+	f.background.Draw(image.Rect(f.clip.Min.X, y, f.clip.Max.X, f.rect.Max.Y), f.cols[ColBack], nil, image.Point{})
+	pt := f.rect.Min
+	for _, b := range f.box {
+		pt = f.cklinewrap(pt, b)
+		if pt.Y >= f.rect.Max.Y {
+			break
+		}
+		if pt.Y >= y && b.Nrune >= 0 {
+			text, back := f.boxcolours(b, f.cols[ColText], f.cols[ColBack])
+			if back != f.cols[ColBack] {
+				f.fillbox(pt, b.Wid, back)
+			}
+			f.drawboxtext(pt, b, b.Ptr, b.Wid, text)
+		} else if pt.Y >= y {
+			f.drawblank(pt, b, f.cols[ColBack])
+		}
+		pt = f.advance(pt, b)
+	}
+
+	if highlighted {
+		f.drawselimpl(f.ptofcharptb(sp0, f.rect.Min, 0), sp0, sp1, true)
+	}
+}
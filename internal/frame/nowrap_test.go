@@ -0,0 +1,79 @@
+package frame
+
+import (
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestNoWrapLayout(t *testing.T) {
+	f, _, restore := newStyleTestFrame(t)
+	defer restore()
+	r := f.Rect()
+	long := strings.Repeat("a", 30)
+
+	f.Init(r, OptNoWrap(true, 0))
+	f.Insert([]rune(long+"\nb"), 0)
+	if got, want := f.Ptofchar(31), image.Pt(0, 13); got != want {
+		t.Errorf("rune after a long line is at %v; want %v", got, want)
+	}
+	if got, want := f.Ptofchar(20), image.Pt(200, 0); got != want {
+		t.Errorf("rune past the right edge is at %v; want %v", got, want)
+	}
+	if got, want := f.GetFrameFillStatus().Nlines, 2; got != want {
+		t.Errorf("nlines is %v; want %v", got, want)
+	}
+	if got := f.Rect(); got != r {
+		t.Errorf("Rect is %v; want %v", got, r)
+	}
+
+	f.Init(r, OptNoWrap(true, 55))
+	f.Insert([]rune(long+"\nb"), 0)
+	if got, want := f.Ptofchar(0), image.Pt(-55, 0); got != want {
+		t.Errorf("rune 0 scrolled left is at %v; want %v", got, want)
+	}
+	if got, want := f.Charofpt(image.Pt(0, 0)), 5; got != want {
+		t.Errorf("rune at the left edge is %v; want %v", got, want)
+	}
+	if got := f.Rect(); got != r {
+		t.Errorf("Rect scrolled left is %v; want %v", got, r)
+	}
+
+	f.Init(r, OptNoWrap(false, 0))
+	f.Insert([]rune(long+"\nb"), 0)
+	if got, want := f.Ptofchar(31), image.Pt(0, 26); got != want {
+		t.Errorf("rune after a folded line is at %v; want %v", got, want)
+	}
+}
+
+func TestNoWrapClipping(t *testing.T) {
+	f, bg, restore := newStyleTestFrame(t)
+	defer restore()
+	r := f.Rect()
+
+	f.Init(r, OptNoWrap(true, 55))
+	f.Insert([]rune("hi\n"+strings.Repeat("a", 30)+"\nb"), 0)
+	f.Delete(3, 4)
+	f.DrawSel(f.Ptofchar(0), 0, 10, true)
+	for _, d := range bg.draw {
+		if !d.In(r) {
+			t.Errorf("drew %v outside %v", d, r)
+		}
+	}
+	for _, d := range bg.text {
+		if d.s == "hi" || d.s == "b" {
+			t.Errorf("drew hidden text %q", d.s)
+		}
+		if strings.HasPrefix(d.s, "a") {
+			t.Errorf("drew text %q straddling the edges at %v", d.s, d.pt)
+		}
+	}
+
+	// The frame draws the background beside it.
+	bg.draw, bg.src = nil, nil
+	enclosing := image.Rect(-20, 0, 200, 65)
+	f.Redraw(enclosing)
+	if len(bg.draw) != 1 || bg.draw[0] != enclosing {
+		t.Errorf("Redraw drew %v; want %v", bg.draw, enclosing)
+	}
+}
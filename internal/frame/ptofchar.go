@@ -18,7 +18,7 @@ func (f *frameimpl) ptofcharptb(p int, pt image.Point, bn int) image.Point {
 				for s := 0; s < len(b.Ptr) && p > 0; s += w {
 					p--
 					r, w = utf8.DecodeRune(b.Ptr[s:])
-					pt.X += f.font.BytesWidth(b.Ptr[s : s+w])
+					pt.X += f.boxfont(b).BytesWidth(b.Ptr[s : s+w])
 					if r == 0 || pt.X > f.rect.Max.X {
 						log.Panicf("frptofchar: r=%v pt.X=%v f.rect.Max.X=%v\n", r, pt.X, f.rect.Max.X)
 					}
@@ -97,7 +97,7 @@ func (f *frameimpl) charofptimpl(pt image.Point) int {
 					if r == 0 {
 						panic("end of string in frcharofpt")
 					}
-					qt.X += f.font.BytesWidth(b.Ptr[s : s+w])
+					qt.X += f.boxfont(b).BytesWidth(b.Ptr[s : s+w])
 					if qt.X > pt.X {
 						break
 					}
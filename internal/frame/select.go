@@ -71,6 +71,7 @@ func (f *frameimpl) selectimpl(mc *draw.Mousectl, downevent *draw.Mouse, getmore
 
 	for {
 		me := <-mc.C
+		mc.Mouse = me // as Mousectl.Read does
 		mp := me.Point
 		mb := me.Buttons
 
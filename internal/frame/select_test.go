@@ -0,0 +1,31 @@
+package frame
+
+import (
+	"testing"
+
+	"github.com/rjkroege/edwood/internal/draw"
+)
+
+func TestSelectStoresMouse(t *testing.T) {
+	f, _, restore := newStyleTestFrame(t)
+	defer restore()
+	f.Insert([]rune("hello world"), 0)
+
+	// Drag from the first rune and release past the fifth.
+	down := draw.Mouse{Point: f.rect.Min, Buttons: 1}
+	mousec := make(chan draw.Mouse, 2)
+	mc := &draw.Mousectl{C: mousec}
+	mousec <- draw.Mouse{Point: f.ptofcharptb(3, f.rect.Min, 0), Buttons: 1}
+	up := draw.Mouse{Point: f.ptofcharptb(5, f.rect.Min, 0), Buttons: 0, Msec: 42}
+	mousec <- up
+
+	p0, p1 := f.Select(mc, &down, func(SelectScrollUpdater, int) {})
+	if p0 != 0 || p1 != 5 {
+		t.Errorf("selected %d, %d; want 0, 5", p0, p1)
+	}
+	// Callers such as Text.Select look at the mouse that ended the
+	// selection, as they do after Mousectl.Read.
+	if mc.Mouse != up {
+		t.Errorf("mouse is %v; want %v", mc.Mouse, up)
+	}
+}
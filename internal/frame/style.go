@@ -0,0 +1,209 @@
+package frame
+
+import (
+	"image"
+
+	"github.com/rjkroege/edwood/internal/draw"
+)
+
+// Style describes how a run of text in a Frame is drawn. The zero value
+// of each field selects the Frame's default. Styles are compared by
+// identity so callers should reuse the same *Style for runs that look
+// the same: adjacent runes are only merged into one box if their
+// styles are identical.
+type Style struct {
+	Fg        draw.Image // text colour in place of ColText
+	Bg        draw.Image // background colour in place of ColBack
+	Underline bool
+	Font      draw.Font // font variant (e.g. bold) with the same height as the Frame's font
+}
+
+// boxfont returns the font used to draw and measure box b.
+func (f *frameimpl) boxfont(b *frbox) draw.Font {
+	if b.Style != nil && b.Style.Font != nil {
+		return b.Style.Font
+	}
+	return f.font
+}
+
+// boxcolours returns the colours for box b where text and back are
+// the colours for an unstyled box. A box's style is only applied when
+// drawing with the default background: it is replaced by the
+// selection colours.
+func (f *frameimpl) boxcolours(b *frbox, text, back draw.Image) (draw.Image, draw.Image) {
+	if b.Style == nil || back != f.cols[ColBack] {
+		return text, back
+	}
+	if b.Style.Fg != nil {
+		text = b.Style.Fg
+	}
+	if b.Style.Bg != nil {
+		back = b.Style.Bg
+	}
+	return text, back
+}
+
+// fillbox fills width w starting at pt with colour col.
+func (f *frameimpl) fillbox(pt image.Point, w int, col draw.Image) {
+	r := image.Rect(pt.X, pt.Y, pt.X+w, pt.Y+f.defaultfontheight)
+	if r.Max.X > f.rect.Max.X {
+		r.Max.X = f.rect.Max.X
+	}
+	f.background.Draw(r, col, nil, r.Min)
+}
+
+// drawboxtext draws ptr, the text from box b that is w pixels wide, at
+// pt in colour text.
+func (f *frameimpl) drawboxtext(pt image.Point, b *frbox, ptr []byte, w int, text draw.Image) {
+	f.background.Bytes(pt, text, image.Point{}, f.boxfont(b), ptr)
+	f.drawspaces(pt, b, ptr)
+	if b.Style != nil && b.Style.Underline {
+		y := pt.Y + f.defaultfontheight - 1
+		r := image.Rect(pt.X, y, pt.X+w, y+1)
+		if r.Max.X > f.rect.Max.X {
+			r.Max.X = f.rect.Max.X
+		}
+		f.background.Draw(r, text, nil, r.Min)
+	}
+}
+
+func (f *frameimpl) SetStyle(p0, p1 int, s *Style) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.setstyleimpl(p0, p1, s)
+}
+
+func (f *frameimpl) setstyleimpl(p0, p1 int, s *Style) {
+	f.validateboxmodel("Frame.SetStyle Start p0=%d p1=%d", p0, p1)
+	defer f.validateboxmodel("Frame.SetStyle End p0=%d p1=%d", p0, p1)
+
+	if p1 > f.nchars {
+		p1 = f.nchars
+	}
+	if p0 < 0 || p0 >= p1 {
+		return
+	}
+
+	n0 := f.findbox(0, 0, p0)
+	n1 := f.findbox(n0, p0, p1)
+	changed := false
+	relayout := false
+	for _, b := range f.box[n0:n1] {
+		if b.Style == s {
+			continue
+		}
+		changed = true
+		if b.Nrune >= 0 && f.boxfont(b) != f.boxfont(&frbox{Style: s}) {
+			relayout = true
+		}
+	}
+	if !changed {
+		return
+	}
+	if relayout {
+		f.restyle(p0, p1, n0, n1, s)
+		return
+	}
+
+	for _, b := range f.box[n0:n1] {
+		b.Style = s
+	}
+	if f.background != nil && !f.noredraw {
+		f.repaint(p0, n0, n1)
+	}
+
+	// Merge the boxes with their neighbours where they now have the same style.
+	if n0 > 0 {
+		n0--
+		p0 -= nrune(f.box[n0])
+	}
+	if n1 < len(f.box) {
+		n1++
+	}
+	f.clean(f.ptofcharptb(p0, f.rect.Min, 0), n0, n1)
+}
+
+// repaint redraws boxes [n0, n1), starting at rune p0, in place.
+// The selection or tick is removed beforehand and restored afterwards.
+func (f *frameimpl) repaint(p0, n0, n1 int) {
+	sp0, sp1 := f.sp0, f.sp1
+	highlighted := f.highlighton || f.ticked
+	f.drawselimpl(f.ptofcharptb(sp0, f.rect.Min, 0), sp0, sp1, false)
+
+	pt := f.ptofcharptb(p0, f.rect.Min, 0)
+	for _, b := range f.box[n0:n1] {
+		pt = f.cklinewrap(pt, b)
+		if pt.Y >= f.rect.Max.Y {
+			break
+		}
+		if b.Nrune >= 0 {
+			text, back := f.boxcolours(b, f.cols[ColText], f.cols[ColBack])
+			f.fillbox(pt, b.Wid, back)
+			f.drawboxtext(pt, b, b.Ptr, b.Wid, text)
+		} else {
+			f.fillbox(pt, b.Wid, f.cols[ColBack])
+			f.drawblank(pt, b, f.cols[ColBack])
+		}
+		pt = f.advance(pt, b)
+	}
+
+	if highlighted {
+		f.drawselimpl(f.ptofcharptb(sp0, f.rect.Min, 0), sp0, sp1, true)
+	}
+}
+
+// restyle changes the style of boxes [n0, n1), holding runes [p0, p1),
+// to s where this changes the width of the boxes. The runes from p0 on
+// are deleted and then inserted again with their new styles so that
+// the frame is laid out afresh.
+func (f *frameimpl) restyle(p0, p1, n0, n1 int, s *Style) {
+	type run struct {
+		r []rune
+		s *Style
+	}
+	var runs []run
+	for i, b := range f.box[n0:] {
+		st := b.Style
+		if n0+i < n1 {
+			st = s
+		}
+		var r []rune
+		if b.Nrune < 0 {
+			r = []rune{b.Bc}
+		} else {
+			r = []rune(string(b.Ptr))
+		}
+		if len(runs) > 0 && runs[len(runs)-1].s == st {
+			runs[len(runs)-1].r = append(runs[len(runs)-1].r, r...)
+		} else {
+			runs = append(runs, run{r, st})
+		}
+	}
+
+	// Delete and insert move the selection so restore it afterwards.
+	sp0, sp1 := f.sp0, f.sp1
+	highlighted := f.highlighton || f.ticked
+	f.drawselimpl(f.ptofcharptb(sp0, f.rect.Min, 0), sp0, sp1, false)
+
+	f.deleteimpl(p0, f.nchars)
+	p := p0
+	for _, r := range runs {
+		if f.lastlinefull && p == f.nchars {
+			break
+		}
+		f.insertimpl(r.r, p, r.s)
+		p += len(r.r)
+	}
+
+	f.drawselimpl(f.ptofcharptb(f.sp0, f.rect.Min, 0), f.sp0, f.sp1, false)
+	if sp1 > f.nchars {
+		sp1 = f.nchars
+	}
+	if sp0 > sp1 {
+		sp0 = sp1
+	}
+	f.sp0, f.sp1 = sp0, sp1
+	if highlighted {
+		f.drawselimpl(f.ptofcharptb(sp0, f.rect.Min, 0), sp0, sp1, true)
+	}
+}
@@ -0,0 +1,232 @@
+package frame
+
+import (
+	"image"
+	"testing"
+
+	"github.com/rjkroege/edwood/internal/draw"
+	"github.com/rjkroege/edwood/internal/edwoodtest"
+)
+
+// recordingImage is a draw.Image that records the text drawn on it.
+type recordingImage struct {
+	draw.Image
+	text []drawnText
+	draw []image.Rectangle
+	src  []draw.Image // source of each rectangle in draw
+}
+
+type drawnText struct {
+	pt   image.Point
+	src  draw.Image
+	font draw.Font
+	s    string
+}
+
+func (i *recordingImage) Draw(r image.Rectangle, src, mask draw.Image, p1 image.Point) {
+	i.draw = append(i.draw, r)
+	i.src = append(i.src, src)
+}
+
+// drawnWith returns the rectangles drawn from src.
+func (i *recordingImage) drawnWith(src draw.Image) []image.Rectangle {
+	var rs []image.Rectangle
+	for j, r := range i.draw {
+		if i.src[j] == src {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+func (i *recordingImage) Bytes(pt image.Point, src draw.Image, sp image.Point, f draw.Font, b []byte) image.Point {
+	i.text = append(i.text, drawnText{pt, src, f, string(b)})
+	return pt
+}
+
+func (i *recordingImage) drewText(s string, src draw.Image) bool {
+	for _, t := range i.text {
+		if t.s == s && t.src == src {
+			return true
+		}
+	}
+	return false
+}
+
+// newStyleTestFrame returns a frame drawing on a recording image,
+// with validation on until the returned function is called.
+func newStyleTestFrame(t *testing.T) (*frameimpl, *recordingImage, func()) {
+	t.Helper()
+
+	old := *validate
+	*validate = true
+
+	var cols [NumColours]draw.Image
+	for i := range cols {
+		cols[i] = edwoodtest.NewImage(image.Rectangle{})
+	}
+	bg := &recordingImage{Image: edwoodtest.NewImage(image.Rect(0, 0, 800, 600))}
+	f := NewFrame(image.Rect(0, 0, 200, 5*13), mockFont(), bg, cols).(*frameimpl)
+	return f, bg, func() { *validate = old }
+}
+
+// styles returns the style of each rune in f.
+func styles(f *frameimpl) []*Style {
+	var s []*Style
+	for _, b := range f.box {
+		for i := 0; i < nrune(b); i++ {
+			s = append(s, b.Style)
+		}
+	}
+	return s
+}
+
+func checkStyles(t *testing.T, f *frameimpl, want map[int]*Style) {
+	t.Helper()
+	for p, s := range styles(f) {
+		if s != want[p] {
+			t.Errorf("style of rune %d is %v; want %v", p, s, want[p])
+		}
+	}
+}
+
+func styleRange(s *Style, p0, p1 int) map[int]*Style {
+	m := make(map[int]*Style)
+	for p := p0; p < p1; p++ {
+		m[p] = s
+	}
+	return m
+}
+
+func TestSetStyleSurvivesEdits(t *testing.T) {
+	f, _, restore := newStyleTestFrame(t)
+	defer restore()
+	kw := &Style{Underline: true}
+
+	f.Insert([]rune("hello world\nfoo"), 0)
+	f.SetStyle(6, 11, kw)
+	checkStyles(t, f, styleRange(kw, 6, 11))
+
+	f.Insert([]rune("XX"), 0)
+	checkStyles(t, f, styleRange(kw, 8, 13))
+
+	f.Delete(0, 4)
+	checkStyles(t, f, styleRange(kw, 4, 9))
+
+	// Runes inserted into a styled run are unstyled.
+	f.Insert([]rune("_"), 6)
+	want := styleRange(kw, 4, 10)
+	want[6] = nil
+	checkStyles(t, f, want)
+
+	// Deleting the styled runes deletes their style.
+	f.Delete(4, 10)
+	checkStyles(t, f, nil)
+	if got, want := f.nchars, len("llo \nfoo"); got != want {
+		t.Errorf("nchars is %v; want %v", got, want)
+	}
+}
+
+func TestSetStyleClear(t *testing.T) {
+	f, _, restore := newStyleTestFrame(t)
+	defer restore()
+	kw := &Style{Underline: true}
+
+	f.Insert([]rune("hello world"), 0)
+	nbox := len(f.box)
+	f.SetStyle(0, 11, kw)
+	f.SetStyle(2, 4, nil)
+	want := styleRange(kw, 0, 11)
+	delete(want, 2)
+	delete(want, 3)
+	checkStyles(t, f, want)
+
+	f.SetStyle(0, 100, nil)
+	checkStyles(t, f, nil)
+
+	// Boxes with the same style are merged again.
+	f.Insert([]rune("!"), 11)
+	if got := len(f.box); got > nbox {
+		t.Errorf("got %v boxes after clearing styles; want at most %v", got, nbox)
+	}
+}
+
+func TestSetStyleDraws(t *testing.T) {
+	f, bg, restore := newStyleTestFrame(t)
+	defer restore()
+	fg := edwoodtest.NewImage(image.Rectangle{})
+	kw := &Style{Fg: fg, Underline: true}
+
+	f.Insert([]rune("hello world"), 0)
+	bg.text = nil
+	bg.draw, bg.src = nil, nil
+	f.SetStyle(6, 11, kw)
+	if !bg.drewText("world", fg) {
+		t.Errorf("styled text not drawn in its foreground colour: %v", bg.text)
+	}
+	underline := image.Rect(60, 12, 110, 13)
+	found := false
+	for _, r := range bg.draw {
+		if r == underline {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no underline %v drawn: %v", underline, bg.draw)
+	}
+
+	// Text inserted next to styled text is drawn in the default colour.
+	bg.text = nil
+	f.Insert([]rune("!"), 11)
+	if !bg.drewText("!", f.cols[ColText]) {
+		t.Errorf("inserted text not drawn in default colour: %v", bg.text)
+	}
+
+	// The selection replaces the style's colours.
+	bg.text = nil
+	f.DrawSel(f.Ptofchar(6), 6, 11, true)
+	if !bg.drewText("world", f.cols[ColHText]) {
+		t.Errorf("selected text not drawn in highlight colour: %v", bg.text)
+	}
+	bg.text = nil
+	f.DrawSel(f.Ptofchar(6), 6, 6, false)
+	if !bg.drewText("world", fg) {
+		t.Errorf("unselected text not drawn in its foreground colour: %v", bg.text)
+	}
+}
+
+func TestSetStyleFont(t *testing.T) {
+	f, bg, restore := newStyleTestFrame(t)
+	defer restore()
+	bold := &Style{Font: edwoodtest.NewFont(2*fixedwidth, 13)}
+
+	f.Insert([]rune("abc def\nghi"), 0)
+	f.DrawSel(f.Ptofchar(9), 9, 10, true)
+	bg.text = nil
+	f.SetStyle(0, 3, bold)
+
+	checkStyles(t, f, styleRange(bold, 0, 3))
+	if got, want := f.Ptofchar(4), image.Pt(3*2*fixedwidth+fixedwidth, 0); got != want {
+		t.Errorf("rune 4 is at %v; want %v", got, want)
+	}
+	if got, want := f.nchars, 11; got != want {
+		t.Errorf("nchars is %v; want %v", got, want)
+	}
+	if p0, p1 := f.GetSelectionExtent(); p0 != 9 || p1 != 10 {
+		t.Errorf("selection is %v,%v; want 9,10", p0, p1)
+	}
+	found := false
+	for _, d := range bg.text {
+		if d.s == "abc" && d.font == bold.Font {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("styled text not drawn in its font: %v", bg.text)
+	}
+
+	f.SetStyle(0, 3, nil)
+	if got, want := f.Ptofchar(4), image.Pt(4*fixedwidth, 0); got != want {
+		t.Errorf("rune 4 is at %v after clearing style; want %v", got, want)
+	}
+}
@@ -1,11 +1,73 @@
 package frame
 
 import (
+	"fmt"
 	"image"
+	"time"
 
 	"github.com/rjkroege/edwood/internal/draw"
 )
 
+// TickStyle selects how the tick (the insertion point) is drawn.
+type TickStyle int
+
+const (
+	TickThin      TickStyle = iota // a thin bar with serifs, as in acme
+	TickBar                        // a thick bar
+	TickBlock                      // a block covering the next rune
+	TickUnderline                  // a line under the next rune
+	NumTickStyles
+)
+
+var ticknames = [NumTickStyles]string{
+	TickThin:      "thin",
+	TickBar:       "bar",
+	TickBlock:     "block",
+	TickUnderline: "underline",
+}
+
+func (s TickStyle) String() string {
+	if s >= 0 && s < NumTickStyles {
+		return ticknames[s]
+	}
+	return fmt.Sprintf("TickStyle(%d)", int(s))
+}
+
+// ParseTickStyle returns the TickStyle called s.
+func ParseTickStyle(s string) (TickStyle, error) {
+	for i, n := range ticknames {
+		if n == s {
+			return TickStyle(i), nil
+		}
+	}
+	return TickThin, fmt.Errorf("unknown tick style %q", s)
+}
+
+// OptTick sets how the tick is drawn and how often it blinks. The tick
+// doesn't blink if blink is not positive.
+func OptTick(style TickStyle, blink time.Duration) OptionClosure {
+	return func(f *frameimpl, ctx *optioncontext) {
+		if f.tickstyle != style {
+			f.tickstyle = style
+			ctx.updatetick = true
+		}
+		f.blink = blink
+	}
+}
+
+// tickwidth returns the width of the tick image.
+func (f *frameimpl) tickwidth() int {
+	switch f.tickstyle {
+	case TickBlock, TickUnderline:
+		// Wide enough for any rune and most tabs.
+		if w := 2 * f.font.Height(); w > f.maxtab {
+			return w
+		}
+		return f.maxtab
+	}
+	return f.tickscale * frtickw
+}
+
 // InitTick sets up the TickImage (e.g. cursor)
 // TODO(rjk): doesn't appear to need to be exposed publically.
 func (f *frameimpl) InitTick() {
@@ -22,9 +84,10 @@ func (f *frameimpl) InitTick() {
 	}
 
 	height := ft.Height()
+	width := f.tickwidth()
 
 	var err error
-	f.tickimage, err = f.display.AllocImage(image.Rect(0, 0, f.tickscale*frtickw, height), b.Pix(), false, draw.Transparent)
+	f.tickimage, err = f.display.AllocImage(image.Rect(0, 0, width, height), b.Pix(), false, draw.Transparent)
 	if err != nil {
 		return
 	}
@@ -38,9 +101,75 @@ func (f *frameimpl) InitTick() {
 	f.tickback.Draw(f.tickback.R(), f.cols[ColBack], nil, image.Point{})
 
 	f.tickimage.Draw(f.tickimage.R(), f.display.Transparent(), nil, image.Pt(0, 0))
-	// vertical line
-	f.tickimage.Draw(image.Rect(f.tickscale*(frtickw/2), 0, f.tickscale*(frtickw/2+1), height), f.display.Opaque(), nil, image.Pt(0, 0))
-	// box on each end
-	f.tickimage.Draw(image.Rect(0, 0, f.tickscale*frtickw, f.tickscale*frtickw), f.display.Opaque(), nil, image.Pt(0, 0))
-	f.tickimage.Draw(image.Rect(0, height-f.tickscale*frtickw, f.tickscale*frtickw, height), f.display.Opaque(), nil, image.Pt(0, 0))
+	switch f.tickstyle {
+	case TickBar:
+		f.tickimage.Draw(f.tickimage.R(), f.display.Opaque(), nil, image.Pt(0, 0))
+	case TickBlock:
+		// A grey mask blends the tick with the rune beneath it.
+		grey, err := f.display.AllocImage(image.Rect(0, 0, 1, 1), b.Pix(), true, draw.Color(0x7F7F7FFF))
+		if err != nil {
+			return
+		}
+		f.tickimage.Draw(f.tickimage.R(), grey, nil, image.Pt(0, 0))
+		grey.Free()
+	case TickUnderline:
+		n := f.tickscale * 2
+		if n < 2 {
+			n = 2
+		}
+		f.tickimage.Draw(image.Rect(0, height-n, width, height), f.display.Opaque(), nil, image.Pt(0, 0))
+	default:
+		// vertical line
+		f.tickimage.Draw(image.Rect(f.tickscale*(frtickw/2), 0, f.tickscale*(frtickw/2+1), height), f.display.Opaque(), nil, image.Pt(0, 0))
+		// box on each end
+		f.tickimage.Draw(image.Rect(0, 0, f.tickscale*frtickw, f.tickscale*frtickw), f.display.Opaque(), nil, image.Pt(0, 0))
+		f.tickimage.Draw(image.Rect(0, height-f.tickscale*frtickw, f.tickscale*frtickw, height), f.display.Opaque(), nil, image.Pt(0, 0))
+	}
+}
+
+// tickrectat returns the rectangle covered by a tick at pt.
+func (f *frameimpl) tickrectat(pt image.Point) image.Rectangle {
+	var r image.Rectangle
+	switch f.tickstyle {
+	case TickBlock, TickUnderline:
+		w := f.runewidthat(pt)
+		if tw := f.tickimage.R().Dx(); w > tw {
+			w = tw
+		}
+		r = image.Rect(pt.X, pt.Y, pt.X+w, pt.Y+f.defaultfontheight)
+	default:
+		pt.X -= f.tickscale
+		r = image.Rect(pt.X, pt.Y, pt.X+frtickw*f.tickscale, pt.Y+f.defaultfontheight)
+	}
+	if r.Max.X > f.rect.Max.X {
+		r.Max.X = f.rect.Max.X
+	}
+	return r
+}
+
+// runewidthat returns the width of the rune drawn at pt or of a space
+// if there isn't one.
+func (f *frameimpl) runewidthat(pt image.Point) int {
+	p := f.charofptimpl(pt)
+	if p < f.nchars && f.ptofcharptb(p, f.rect.Min, 0) == pt {
+		if next := f.ptofcharptb(p+1, f.rect.Min, 0); next.Y == pt.Y && next.X > pt.X {
+			return next.X - pt.X
+		}
+	}
+	return f.font.StringWidth(" ")
+}
+
+// drawtick shows or hides the tick in f.tickrect.
+func (f *frameimpl) drawtick(on bool) {
+	if f.tickon == on || f.tickimage == nil {
+		return
+	}
+	r := f.tickrect
+	if on {
+		f.tickback.Draw(f.tickback.R(), unclip(f.background), nil, r.Min)
+		f.background.Draw(r, f.display.Black(), f.tickimage, image.Point{}) // draws an alpha-blended box
+	} else {
+		f.background.Draw(r, f.tickback, nil, image.Point{})
+	}
+	f.tickon = on
 }
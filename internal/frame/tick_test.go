@@ -0,0 +1,75 @@
+package frame
+
+import (
+	"image"
+	"testing"
+	"time"
+)
+
+func TestTickRect(t *testing.T) {
+	f, _, restore := newStyleTestFrame(t)
+	defer restore()
+	r := f.Rect()
+	for _, tc := range []struct {
+		style TickStyle
+		p     int
+		want  image.Rectangle
+	}{
+		{TickBlock, 0, image.Rect(0, 0, 10, 13)},
+		{TickBlock, 2, image.Rect(20, 0, 40, 13)},
+		{TickUnderline, 4, image.Rect(50, 0, 60, 13)},
+		{TickUnderline, 6, image.Rect(0, 13, 10, 26)},
+	} {
+		f.Init(r, OptTick(tc.style, 0), OptMaxTab(4))
+		f.Insert([]rune("ab\tcd\n"), 0)
+		f.DrawSel(f.Ptofchar(tc.p), tc.p, tc.p, true)
+		if !f.ticked || !f.tickon {
+			t.Errorf("%v tick at %d not shown", tc.style, tc.p)
+		}
+		if f.tickrect != tc.want {
+			t.Errorf("%v tick at %d covers %v; want %v", tc.style, tc.p, f.tickrect, tc.want)
+		}
+		f.DrawSel(f.Ptofchar(tc.p), tc.p, tc.p, false)
+	}
+}
+
+func TestTickBlink(t *testing.T) {
+	f, _, restore := newStyleTestFrame(t)
+	defer restore()
+	f.Init(f.Rect(), OptTick(TickBar, time.Hour))
+	f.Insert([]rune("ab"), 0)
+	blinking := func() bool {
+		blinker.Lock()
+		defer blinker.Unlock()
+		return blinker.frames[f]
+	}
+
+	f.DrawSel(f.Ptofchar(1), 1, 1, true)
+	if !blinking() {
+		t.Fatalf("tick not blinking")
+	}
+	start := f.ticktime
+	f.blinktick(start.Add(time.Minute))
+	if !f.tickon {
+		t.Errorf("tick hidden before blinking")
+	}
+	f.blinktick(start.Add(time.Hour))
+	if f.tickon {
+		t.Errorf("tick not hidden after blinking")
+	}
+	f.blinktick(start.Add(2 * time.Hour))
+	if !f.tickon {
+		t.Errorf("tick not shown after blinking twice")
+	}
+
+	f.DrawSel(f.Ptofchar(1), 1, 1, false)
+	if f.ticked || f.tickon || blinking() {
+		t.Errorf("tick still showing or blinking after removal")
+	}
+	if got, want := ParseTickStyle("block"); got != TickBlock || want != nil {
+		t.Errorf("ParseTickStyle(block) is %v, %v", got, want)
+	}
+	if _, err := ParseTickStyle("wavy"); err == nil {
+		t.Errorf("ParseTickStyle(wavy) succeeded")
+	}
+}
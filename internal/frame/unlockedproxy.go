@@ -37,7 +37,7 @@ func (up *selectscrollupdaterimpl) Delete(p0, p1 int) int {
 func (up *selectscrollupdaterimpl) Insert(r []rune, p0 int) bool {
 	// log.Println("selectscrollupdaterimpl.Insert")
 	f := (*frameimpl)(up)
-	return f.insertimpl(r, p0)
+	return f.insertimpl(r, p0, nil)
 }
 
 func (up *selectscrollupdaterimpl) IsLastLineFull() bool {
@@ -49,7 +49,7 @@ func (up *selectscrollupdaterimpl) IsLastLineFull() bool {
 func (up *selectscrollupdaterimpl) Rect() image.Rectangle {
 	// log.Println("selectscrollupdaterimpl.Rect")
 	f := (*frameimpl)(up)
-	return f.rect
+	return f.clip
 }
 
 func (up *selectscrollupdaterimpl) TextOccupiedHeight(r image.Rectangle) int {
@@ -31,7 +31,7 @@ func (f *frameimpl) canfit(pt image.Point, b *frbox) (int, bool) {
 	o := 0
 	for nr := 0; nr < b.Nrune; nr++ {
 		_, w = utf8.DecodeRune(b.Ptr[o:])
-		left -= f.font.StringWidth(string(b.Ptr[o : o+w]))
+		left -= f.boxfont(b).StringWidth(string(b.Ptr[o : o+w]))
 		if left < 0 {
 			return nr, nr != 0
 		}
@@ -125,6 +125,7 @@ func (f *frameimpl) clean(pt image.Point, n0, n1 int) {
 		for f.box[nb].Nrune >= 0 &&
 			nb < n1-1 &&
 			f.box[nb+1].Nrune >= 0 &&
+			f.box[nb].Style == f.box[nb+1].Style &&
 			pt.X+f.box[nb].Wid+f.box[nb+1].Wid < c {
 			f.mergebox(nb)
 			n1--
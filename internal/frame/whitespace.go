@@ -0,0 +1,94 @@
+package frame
+
+import (
+	"image"
+
+	"github.com/rjkroege/edwood/internal/draw"
+)
+
+// OptWhitespace sets the colour in which blanks are made visible: a
+// dot in the middle of each space, an arrow across each tab and a
+// marker at the end of each line. The markers are drawn over the text
+// and don't change where any rune is laid out. A nil colour hides the
+// blanks again.
+func OptWhitespace(col draw.Image) OptionClosure {
+	return func(f *frameimpl, ctx *optioncontext) {
+		f.whitespace = col
+	}
+}
+
+// whitespacesize returns the width of the lines used to draw the
+// markers of blanks.
+func (f *frameimpl) whitespacesize() int {
+	if n := f.defaultfontheight / 12; n > 1 {
+		return n
+	}
+	return 1
+}
+
+// drawmark draws rectangle r of a marker, clipped to the frame.
+func (f *frameimpl) drawmark(r image.Rectangle) {
+	if r.Max.X > f.rect.Max.X {
+		r.Max.X = f.rect.Max.X
+	}
+	if r.Empty() {
+		return
+	}
+	f.background.Draw(r, f.whitespace, nil, image.Point{})
+}
+
+// drawspaces draws a dot in the middle of each space of ptr, text from
+// box b drawn at pt.
+func (f *frameimpl) drawspaces(pt image.Point, b *frbox, ptr []byte) {
+	if f.whitespace == nil {
+		return
+	}
+	ft := f.boxfont(b)
+	sw := ft.BytesWidth([]byte(" "))
+	n := f.whitespacesize() + 1
+	y := pt.Y + (f.defaultfontheight-n)/2
+	for i, c := range ptr {
+		if c != ' ' {
+			continue
+		}
+		x := pt.X + ft.BytesWidth(ptr[:i]) + (sw-n)/2
+		f.drawmark(image.Rect(x, y, x+n, y+n))
+	}
+}
+
+// drawblank draws non-text box b, a tab or a newline, at pt: its
+// background if its style has one and its marker if blanks are
+// visible.
+func (f *frameimpl) drawblank(pt image.Point, b *frbox, back draw.Image) {
+	if b.Nrune >= 0 || pt.X >= f.rect.Max.X {
+		return
+	}
+	if _, bback := f.boxcolours(b, nil, back); bback != back && b.Bc == '\t' {
+		f.fillbox(pt, b.Wid, bback)
+	}
+	if f.whitespace == nil {
+		return
+	}
+
+	n := f.whitespacesize()
+	h := f.defaultfontheight
+	y := pt.Y + (h-n)/2
+	sw := f.font.BytesWidth([]byte(" "))
+	switch b.Bc {
+	case '\t':
+		// An arrow across the tab.
+		x0, x1 := pt.X+sw/4, pt.X+b.Wid-sw/4
+		f.drawmark(image.Rect(x0, y, x1, y+n))
+		for i := 1; i <= h/5 && x1-i > x0; i++ {
+			f.drawmark(image.Rect(x1-i-n, y-i, x1-i, y+n+i))
+		}
+	case '\n':
+		// A hook down and to the left at the end of the line.
+		x0, x1 := pt.X+1, pt.X+sw
+		if x1-n <= x0 {
+			x1 = x0 + n + 1
+		}
+		f.drawmark(image.Rect(x1-n, pt.Y+h/4, x1, y+n))
+		f.drawmark(image.Rect(x0, y, x1, y+n))
+	}
+}
@@ -0,0 +1,68 @@
+package frame
+
+import (
+	"image"
+	"testing"
+
+	"github.com/rjkroege/edwood/internal/edwoodtest"
+)
+
+func TestWhitespace(t *testing.T) {
+	f, bg, restore := newStyleTestFrame(t)
+	defer restore()
+	r := f.Rect()
+	ws := edwoodtest.NewImage(image.Rectangle{})
+	text := []rune("a b\tc\nd")
+
+	f.Insert(text, 0)
+	var want []image.Point
+	for p := range text {
+		want = append(want, f.Ptofchar(p))
+	}
+	if marks := bg.drawnWith(ws); len(marks) != 0 {
+		t.Errorf("drew marks %v with blanks hidden", marks)
+	}
+
+	f.Init(r, OptWhitespace(ws))
+	f.Insert(text, 0)
+	for p := range text {
+		if got := f.Ptofchar(p); got != want[p] {
+			t.Errorf("rune %d at %v with visible blanks; want %v", p, got, want[p])
+		}
+	}
+
+	// Each blank has a mark inside its box and nothing else does.
+	marks := bg.drawnWith(ws)
+	for _, blank := range []struct {
+		name string
+		r    image.Rectangle
+	}{
+		{"space", image.Rect(10, 0, 20, 13)},
+		{"tab", image.Rect(30, 0, 80, 13)},
+		{"newline", image.Rect(90, 0, 100, 13)},
+	} {
+		found := false
+		for _, m := range marks {
+			found = found || m.In(blank.r)
+		}
+		if !found {
+			t.Errorf("no mark drawn for %s in %v", blank.name, blank.r)
+		}
+	}
+	for _, m := range marks {
+		if !m.In(image.Rect(10, 0, 20, 13)) && !m.In(image.Rect(30, 0, 100, 13)) {
+			t.Errorf("drew mark %v outside the blanks", m)
+		}
+	}
+
+	// Deleting the b draws the mark of the tab again where it moved.
+	bg.draw, bg.src = nil, nil
+	f.Delete(2, 3)
+	found := false
+	for _, m := range bg.drawnWith(ws) {
+		found = found || m.In(image.Rect(20, 0, 80, 13))
+	}
+	if !found {
+		t.Errorf("no mark drawn for tab after deletion")
+	}
+}
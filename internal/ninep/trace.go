@@ -0,0 +1,286 @@
+package ninep
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"9fans.net/go/plan9"
+)
+
+// Trace records 9P messages exchanged on one or more connections.
+// Each message is written as a line of tab-separated fields:
+//
+//	conn time dir latency message summary
+//
+// where conn is the connection number, time is the seconds since
+// the trace was started, dir is "<-" for a request and "->" for a
+// response, latency is the time taken to respond to the request
+// ("-" for a request), message is the base64 encoding of the message
+// and summary is the human-readable form of the message.
+type Trace struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+	nconn int
+}
+
+// NewTrace returns a Trace that writes to w.
+func NewTrace(w io.Writer) *Trace {
+	return &Trace{
+		w:     w,
+		start: time.Now(),
+	}
+}
+
+// Conn returns a TraceConn used to record the messages of a new connection.
+func (t *Trace) Conn() *TraceConn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.nconn++
+	return &TraceConn{
+		t:       t,
+		id:      t.nconn,
+		pending: make(map[uint16]time.Time),
+	}
+}
+
+func (t *Trace) write(id int, now time.Time, dir string, latency string, fc *plan9.Fcall) {
+	b, err := fc.Bytes()
+	if err != nil {
+		return
+	}
+	summary := strings.Replace(fc.String(), "\n", `\n`, -1)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.w, "%d\t%.6f\t%s\t%s\t%s\t%s\n", id, now.Sub(t.start).Seconds(), dir, latency,
+		base64.StdEncoding.EncodeToString(b), summary)
+}
+
+// TraceConn records the messages of a single connection. It is safe
+// for concurrent use, since responses are often sent from a different
+// goroutine than the one reading requests.
+type TraceConn struct {
+	t       *Trace
+	id      int
+	mu      sync.Mutex
+	pending map[uint16]time.Time // tag -> time request was received
+}
+
+// Request records request fc.
+func (c *TraceConn) Request(fc *plan9.Fcall) {
+	now := time.Now()
+	c.mu.Lock()
+	c.pending[fc.Tag] = now
+	c.mu.Unlock()
+	c.t.write(c.id, now, "<-", "-", fc)
+}
+
+// Response records response fc, along with the time taken since its request.
+func (c *TraceConn) Response(fc *plan9.Fcall) {
+	now := time.Now()
+	latency := "-"
+	c.mu.Lock()
+	if t, ok := c.pending[fc.Tag]; ok {
+		latency = now.Sub(t).String()
+		delete(c.pending, fc.Tag)
+	}
+	c.mu.Unlock()
+	c.t.write(c.id, now, "->", latency, fc)
+}
+
+// TraceEntry is a message read from a trace.
+type TraceEntry struct {
+	Conn    int           // connection number
+	Time    time.Duration // since start of trace
+	Latency time.Duration // time taken to respond (responses only)
+	Fcall   *plan9.Fcall
+}
+
+// IsRequest returns true if the entry is a request (a T-message).
+func (e *TraceEntry) IsRequest() bool {
+	return e.Fcall.Type%2 == 0
+}
+
+// ReadTrace reads the entries of a trace written by Trace.
+func ReadTrace(r io.Reader) ([]*TraceEntry, error) {
+	var entries []*TraceEntry
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		e, err := parseTraceEntry(sc.Text())
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", n, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func parseTraceEntry(line string) (*TraceEntry, error) {
+	f := strings.SplitN(line, "\t", 6)
+	if len(f) < 5 {
+		return nil, fmt.Errorf("too few fields")
+	}
+	var e TraceEntry
+	var err error
+	if e.Conn, err = strconv.Atoi(f[0]); err != nil {
+		return nil, fmt.Errorf("bad connection number: %v", err)
+	}
+	secs, err := strconv.ParseFloat(f[1], 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad time: %v", err)
+	}
+	e.Time = time.Duration(secs * float64(time.Second))
+	if f[3] != "-" {
+		if e.Latency, err = time.ParseDuration(f[3]); err != nil {
+			return nil, fmt.Errorf("bad latency: %v", err)
+		}
+	}
+	b, err := base64.StdEncoding.DecodeString(f[4])
+	if err != nil {
+		return nil, fmt.Errorf("bad message: %v", err)
+	}
+	if e.Fcall, err = plan9.UnmarshalFcall(b); err != nil {
+		return nil, fmt.Errorf("bad message: %v", err)
+	}
+	want := "->"
+	if e.IsRequest() {
+		want = "<-"
+	}
+	if f[2] != want {
+		return nil, fmt.Errorf("direction %q of %v is not %q", f[2], e.Fcall, want)
+	}
+	return &e, nil
+}
+
+// Replayer re-sends the requests recorded in a trace to a server.
+type Replayer struct {
+	// Dial connects to the server. It is called once for each
+	// connection in the trace.
+	Dial func() (io.ReadWriteCloser, error)
+
+	// Secret is written to auth fids instead of the data in the
+	// trace, which a file server may have redacted.
+	Secret []byte
+}
+
+type replayConn struct {
+	rwc      io.ReadWriteCloser
+	requests map[uint16]*plan9.Fcall // outstanding requests by tag
+	early    map[uint16]*plan9.Fcall // responses read before they were expected
+	afids    map[uint32]bool
+}
+
+// Replay sends the requests in trace in order, waiting for each
+// response at the point where it was recorded, so that requests which
+// were outstanding concurrently (e.g. a blocked read of an event
+// file) are also outstanding during the replay. It returns a
+// description of each response that differs from the recorded one.
+// Stat times are ignored when comparing responses.
+func (r *Replayer) Replay(trace []*TraceEntry) ([]string, error) {
+	var diffs []string
+
+	conns := make(map[int]*replayConn)
+	defer func() {
+		for _, c := range conns {
+			c.rwc.Close()
+		}
+	}()
+	for _, e := range trace {
+		c, ok := conns[e.Conn]
+		if !ok {
+			rwc, err := r.Dial()
+			if err != nil {
+				return diffs, err
+			}
+			c = &replayConn{
+				rwc:      rwc,
+				requests: make(map[uint16]*plan9.Fcall),
+				early:    make(map[uint16]*plan9.Fcall),
+				afids:    make(map[uint32]bool),
+			}
+			conns[e.Conn] = c
+		}
+		if e.IsRequest() {
+			fc := *e.Fcall
+			switch fc.Type {
+			case plan9.Tauth:
+				c.afids[fc.Afid] = true
+			case plan9.Twrite:
+				if c.afids[fc.Fid] && r.Secret != nil {
+					fc.Data = r.Secret
+				}
+			}
+			if err := plan9.WriteFcall(c.rwc, &fc); err != nil {
+				return diffs, fmt.Errorf("conn %v: %v", e.Conn, err)
+			}
+			c.requests[fc.Tag] = &fc
+			continue
+		}
+		got, err := c.response(e.Fcall.Tag)
+		if err != nil {
+			return diffs, fmt.Errorf("conn %v: %v", e.Conn, err)
+		}
+		if !sameResponse(e.Fcall, got) {
+			diffs = append(diffs, fmt.Sprintf("conn %v: %v: got %v; want %v",
+				e.Conn, c.requests[got.Tag], got, e.Fcall))
+		}
+		delete(c.requests, got.Tag)
+	}
+	return diffs, nil
+}
+
+// response returns the next response with the given tag.
+func (c *replayConn) response(tag uint16) (*plan9.Fcall, error) {
+	if fc, ok := c.early[tag]; ok {
+		delete(c.early, tag)
+		return fc, nil
+	}
+	for {
+		fc, err := plan9.ReadFcall(c.rwc)
+		if err != nil {
+			return nil, err
+		}
+		if fc.Tag == tag {
+			return fc, nil
+		}
+		c.early[fc.Tag] = fc
+	}
+}
+
+// sameResponse returns true if the responses are the same, ignoring stat times.
+func sameResponse(want, got *plan9.Fcall) bool {
+	if want.Type == plan9.Rstat && got.Type == plan9.Rstat {
+		return bytes.Equal(zeroStatTimes(want.Stat), zeroStatTimes(got.Stat))
+	}
+	wb, err1 := want.Bytes()
+	gb, err2 := got.Bytes()
+	return err1 == nil && err2 == nil && bytes.Equal(wb, gb)
+}
+
+func zeroStatTimes(stat []byte) []byte {
+	d, err := plan9.UnmarshalDir(stat)
+	if err != nil {
+		return stat
+	}
+	d.Atime = 0
+	d.Mtime = 0
+	b, err := d.Bytes()
+	if err != nil {
+		return stat
+	}
+	return b
+}
@@ -0,0 +1,178 @@
+package ninep
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"9fans.net/go/plan9"
+	"github.com/google/go-cmp/cmp"
+)
+
+var traceMessages = []struct {
+	conn int
+	fc   plan9.Fcall
+}{
+	{1, plan9.Fcall{Type: plan9.Tversion, Tag: plan9.NOTAG, Msize: 8192, Version: "9P2000"}},
+	{1, plan9.Fcall{Type: plan9.Rversion, Tag: plan9.NOTAG, Msize: 8192, Version: "9P2000"}},
+	{2, plan9.Fcall{Type: plan9.Tread, Tag: 1, Fid: 1, Offset: 0, Count: 100}},
+	{1, plan9.Fcall{Type: plan9.Twrite, Tag: 1, Fid: 1, Data: []byte("hello\nworld")}},
+	{1, plan9.Fcall{Type: plan9.Rwrite, Tag: 1, Count: 11}},
+	{2, plan9.Fcall{Type: plan9.Tread, Tag: 2, Fid: 2, Offset: 0, Count: 100}},
+	{2, plan9.Fcall{Type: plan9.Rread, Tag: 1, Count: 5, Data: []byte("hello")}},
+	{2, plan9.Fcall{Type: plan9.Rread, Tag: 2, Count: 5, Data: []byte("hello")}},
+}
+
+func writeTestTrace() *bytes.Buffer {
+	var buf bytes.Buffer
+	tr := NewTrace(&buf)
+	conns := map[int]*TraceConn{}
+	for _, m := range traceMessages {
+		c, ok := conns[m.conn]
+		if !ok {
+			c = tr.Conn()
+			conns[m.conn] = c
+		}
+		fc := m.fc
+		if fc.Type%2 == 0 {
+			c.Request(&fc)
+		} else {
+			c.Response(&fc)
+		}
+	}
+	return &buf
+}
+
+func TestTrace(t *testing.T) {
+	buf := writeTestTrace()
+	if got, want := strings.Count(buf.String(), "\n"), len(traceMessages); got != want {
+		t.Fatalf("trace has %v lines; want %v", got, want)
+	}
+	entries, err := ReadTrace(buf)
+	if err != nil {
+		t.Fatalf("ReadTrace failed: %v", err)
+	}
+	if got, want := len(entries), len(traceMessages); got != want {
+		t.Fatalf("got %v entries; want %v", got, want)
+	}
+	for i, e := range entries {
+		m := traceMessages[i]
+		if e.Conn != m.conn {
+			t.Errorf("entry %v is for connection %v; want %v", i, e.Conn, m.conn)
+		}
+		if got, want := e.IsRequest(), m.fc.Type%2 == 0; got != want {
+			t.Errorf("entry %v: IsRequest is %v; want %v", i, got, want)
+		}
+		if e.IsRequest() && e.Latency != 0 {
+			t.Errorf("entry %v: request has latency %v", i, e.Latency)
+		}
+		if i > 0 && e.Time < entries[i-1].Time {
+			t.Errorf("entry %v: time %v is before previous entry", i, e.Time)
+		}
+		want, _ := m.fc.Bytes()
+		got, _ := e.Fcall.Bytes()
+		if !bytes.Equal(got, want) {
+			t.Errorf("entry %v is %v; want %v", i, e.Fcall, &m.fc)
+		}
+	}
+}
+
+func TestReadTraceErrors(t *testing.T) {
+	for _, line := range []string{
+		"1\t0.1\t<-\t-",
+		"x\t0.1\t<-\t-\tAAAA\tsummary",
+		"1\t0.1\t<-\t-\t!!!!\tsummary",
+		"1\t0.1\t<-\tsoon\tAAAA\tsummary",
+	} {
+		if _, err := ReadTrace(strings.NewReader(line + "\n")); err == nil {
+			t.Errorf("ReadTrace of %q succeeded", line)
+		}
+	}
+}
+
+// serveTest responds to each request read from c with data for
+// reads and the count written for writes. It responds to the read
+// with tag 1 only after responding to the next request, like a
+// blocked read.
+func serveTest(c io.ReadWriteCloser) {
+	var blocked *plan9.Fcall
+	for {
+		fc, err := plan9.ReadFcall(c)
+		if err != nil {
+			return
+		}
+		r := &plan9.Fcall{Type: fc.Type + 1, Tag: fc.Tag}
+		switch fc.Type {
+		case plan9.Tversion:
+			r.Msize = fc.Msize
+			r.Version = fc.Version
+		case plan9.Tread:
+			r.Data = []byte("hello")
+			r.Count = 5
+			if fc.Tag == 1 {
+				blocked = r
+				continue
+			}
+		case plan9.Twrite:
+			r.Count = uint32(len(fc.Data))
+		}
+		plan9.WriteFcall(c, r)
+		if blocked != nil {
+			plan9.WriteFcall(c, blocked)
+			blocked = nil
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	entries, err := ReadTrace(writeTestTrace())
+	if err != nil {
+		t.Fatalf("ReadTrace failed: %v", err)
+	}
+	r := &Replayer{
+		Dial: func() (io.ReadWriteCloser, error) {
+			c1, c2 := net.Pipe()
+			go serveTest(c2)
+			return c1, nil
+		},
+	}
+	diffs, err := r.Replay(entries)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("got differences: %v", diffs)
+	}
+
+	entries[1].Fcall.Msize = 4096
+	diffs, err = r.Replay(entries)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if got, want := len(diffs), 1; got != want {
+		t.Fatalf("got %v differences; want %v", got, want)
+	}
+	if !strings.Contains(diffs[0], "Tversion") {
+		t.Errorf("difference %q does not mention the request", diffs[0])
+	}
+}
+
+func TestSameResponse(t *testing.T) {
+	d := plan9.Dir{Name: "body", Uid: "glenda", Gid: "glenda", Muid: "glenda", Mode: 0600, Atime: 1, Mtime: 2}
+	b1, _ := d.Bytes()
+	d.Atime, d.Mtime = 3, 4
+	b2, _ := d.Bytes()
+	if !sameResponse(&plan9.Fcall{Type: plan9.Rstat, Stat: b1}, &plan9.Fcall{Type: plan9.Rstat, Stat: b2}) {
+		t.Errorf("stats differing only in times are not the same")
+	}
+	d.Length = 10
+	b2, _ = d.Bytes()
+	if sameResponse(&plan9.Fcall{Type: plan9.Rstat, Stat: b1}, &plan9.Fcall{Type: plan9.Rstat, Stat: b2}) {
+		t.Errorf("stats differing in length are the same")
+	}
+	if diff := cmp.Diff(zeroStatTimes([]byte("bad")), []byte("bad")); diff != "" {
+		t.Errorf("bad stat changed (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"image"
+
+	"github.com/rjkroege/edwood/internal/frame"
+)
+
+// Besides growing windows with the mouse, the layout of the row can be
+// tidied with commands: Balance makes the windows of a column all as
+// tall, Equalise makes the columns all as wide, and Zoom gives a window
+// all of its column it can, until Zoom in it again puts the column back
+// as it was.
+
+// layout resizes the windows of c, from top to bottom, to share the
+// column in proportion to weights, each window at least showing its
+// tag.
+func (c *Column) layout(weights []int) {
+	r := c.r
+	r.Min.Y = c.tag.fr.Rect().Max.Y
+	c.display.ScreenImage().Draw(r, textcolors[frame.ColBack], nil, image.Point{})
+	b := c.display.ScaleSize(Border)
+	minh := c.minheight()
+	total := 0
+	for _, wt := range weights {
+		total += wt
+	}
+	spare := max(r.Dy()-len(c.w)*minh, 0)
+	y := r.Min.Y
+	for i, w := range c.w {
+		r.Min.Y = y
+		if i == len(c.w)-1 {
+			r.Max.Y = c.r.Max.Y
+		} else {
+			r.Max.Y = r.Min.Y + minh
+			if total > 0 {
+				r.Max.Y += spare * weights[i] / total
+			}
+		}
+		r1 := r
+		r1.Max.Y = r1.Min.Y + b
+		c.display.ScreenImage().Draw(r1, c.display.Black(), nil, image.Point{})
+		r.Min.Y = r1.Max.Y
+		y = w.Resize(r, false, i == len(c.w)-1)
+	}
+	c.safe = true
+}
+
+// minheight returns the height a window of c takes in the column,
+// including the border above it, to show just its tag.
+func (c *Column) minheight() int {
+	return c.display.ScaleSize(Border) + fontget(tagfont, c.display).Height()
+}
+
+// Balance makes the windows of c all as tall.
+func (c *Column) Balance() {
+	if len(c.w) == 0 {
+		return
+	}
+	weights := make([]int, len(c.w))
+	for i := range weights {
+		weights[i] = 1
+	}
+	c.layout(weights)
+	c.zoomed, c.unzoomed = nil, nil
+}
+
+// Zoom makes w as tall as it can be in c, leaving only the tags of the
+// other windows, or if w is already zoomed, puts the windows back as
+// they were before.
+func (c *Column) Zoom(w *Window) {
+	if c.zoomed == w {
+		c.unzoom()
+		return
+	}
+	if c.zoomed == nil {
+		// Keep the layout from before the first window was zoomed, as
+		// the weights that give the windows the heights they have.
+		c.unzoomed = make(map[*Window]int, len(c.w))
+		for _, v := range c.w {
+			c.unzoomed[v] = max(v.r.Dy()+c.display.ScaleSize(Border)-c.minheight(), 0)
+		}
+	}
+	weights := make([]int, len(c.w))
+	for i, v := range c.w {
+		if v == w {
+			weights[i] = 1
+		}
+	}
+	c.layout(weights)
+	c.zoomed = w
+	w.MouseBut()
+}
+
+// unzoom puts the windows of c back to the heights they had before a
+// window was zoomed. Windows added since share the column as the
+// average of the others.
+func (c *Column) unzoom() {
+	weights := make([]int, len(c.w))
+	n, total := 0, 0
+	for i, w := range c.w {
+		if wt, ok := c.unzoomed[w]; ok {
+			weights[i] = wt
+			n++
+			total += wt
+		}
+	}
+	for i, w := range c.w {
+		if _, ok := c.unzoomed[w]; !ok {
+			weights[i] = 1
+			if n > 0 {
+				weights[i] = total / n
+			}
+		}
+	}
+	w := c.zoomed
+	c.zoomed, c.unzoomed = nil, nil
+	c.layout(weights)
+	w.MouseBut()
+}
+
+// Equalise makes the columns of the row all as wide.
+func (row *Row) Equalise() {
+	if len(row.col) == 0 {
+		return
+	}
+	rect := row.r
+	rect.Min.Y = row.col[0].r.Min.Y
+	r1 := rect
+	r1.Max.X = r1.Min.X
+	for i, c := range row.col {
+		r1.Min.X = r1.Max.X
+		r1.Max.X = rect.Min.X + (i+1)*rect.Dx()/len(row.col)
+		if i > 0 {
+			r2 := r1
+			r2.Max.X = r2.Min.X + row.display.ScaleSize(Border)
+			row.display.ScreenImage().Draw(r2, row.display.Black(), nil, image.Point{})
+			r1.Min.X = r2.Max.X
+		}
+		c.Resize(r1)
+	}
+}
+
+// balancex balances the column it is run in, or every column when run
+// in the row tag.
+func balancex(et, _, _ *Text, _, _ bool, _ string) {
+	if et.col != nil {
+		et.col.Balance()
+		return
+	}
+	for _, c := range row.col {
+		c.Balance()
+	}
+}
+
+func equalisex(_, _, _ *Text, _, _ bool, _ string) {
+	row.Equalise()
+}
+
+// zoomx zooms the window it is run in, or puts its column back.
+func zoomx(et, _, _ *Text, _, _ bool, _ string) {
+	if et.w != nil && et.col != nil {
+		et.col.Zoom(et.w)
+	}
+}
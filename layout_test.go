@@ -0,0 +1,110 @@
+package main
+
+import (
+	"image"
+	"reflect"
+	"testing"
+
+	"github.com/rjkroege/edwood/internal/draw"
+)
+
+// windowrects returns the rectangles of the windows of c.
+func windowrects(c *Column) []image.Rectangle {
+	var rs []image.Rectangle
+	for _, w := range c.w {
+		rs = append(rs, w.r)
+	}
+	return rs
+}
+
+// checkTiled checks that the windows of c fill the column, one below
+// the other, but for less than a line left at the bottom by a window
+// that only has room for its tag.
+func checkTiled(t *testing.T, c *Column) {
+	t.Helper()
+	b := c.display.ScaleSize(Border)
+	for i, w := range c.w {
+		if i > 0 && w.r.Min.Y != c.w[i-1].r.Max.Y+b {
+			t.Errorf("window %v starts at %v; want %v", i, w.r.Min.Y, c.w[i-1].r.Max.Y+b)
+		}
+	}
+	w := c.w[len(c.w)-1]
+	if h := w.body.fr.DefaultFontHeight(); w.r.Max.Y > c.r.Max.Y || w.r.Max.Y <= c.r.Max.Y-h {
+		t.Errorf("last window ends at %v; want %v", w.r.Max.Y, c.r.Max.Y)
+	}
+}
+
+func TestBalance(t *testing.T) {
+	setGlobalsForLoadTesting()
+	c := row.Add(nil, -1)
+	for _, y := range []int{-1, 100, 120} {
+		c.Add(nil, nil, y)
+	}
+	c.Balance()
+	checkTiled(t, c)
+	h := c.w[0].body.fr.DefaultFontHeight()
+	for i, w := range c.w {
+		if d := w.r.Dy() - c.w[0].r.Dy(); d < -h || d > h {
+			t.Errorf("window %v is %v tall; want about %v", i, w.r.Dy(), c.w[0].r.Dy())
+		}
+	}
+}
+
+func TestZoom(t *testing.T) {
+	setGlobalsForLoadTesting()
+	c := row.Add(nil, -1)
+	for _, y := range []int{-1, 100, 300} {
+		c.Add(nil, nil, y)
+	}
+	before := windowrects(c)
+
+	c.w[1].Type(&c.w[1].body, draw.KeyCmd+'f')
+	checkTiled(t, c)
+	minh := c.minheight() - c.display.ScaleSize(Border)
+	for i, w := range c.w {
+		if i != 1 && w.r.Dy() != minh {
+			t.Errorf("window %v is %v tall; want %v", i, w.r.Dy(), minh)
+		}
+	}
+	if c.zoomed != c.w[1] {
+		t.Errorf("zoomed window is %p; want %p", c.zoomed, c.w[1])
+	}
+
+	// Zooming another window then the first keeps the layout from before.
+	c.Zoom(c.w[2])
+	c.Zoom(c.w[2])
+	if got := windowrects(c); !reflect.DeepEqual(got, before) {
+		t.Errorf("windows are at %v after zoom; want %v", got, before)
+	}
+	if c.zoomed != nil || c.unzoomed != nil {
+		t.Errorf("column still zoomed")
+	}
+
+	c.Zoom(c.w[0])
+	c.Close(c.w[0], true)
+	if c.zoomed != nil || c.unzoomed != nil {
+		t.Errorf("column still zoomed after closing the zoomed window")
+	}
+}
+
+func TestEqualise(t *testing.T) {
+	setGlobalsForLoadTesting()
+	for _, x := range []int{-1, 100, 200} {
+		row.Add(nil, x)
+	}
+	if err := acmectlwrite("equalise"); err != nil {
+		t.Fatalf("equalise control message failed: %v", err)
+	}
+	b := row.display.ScaleSize(Border)
+	for i, c := range row.col {
+		if i > 0 && c.r.Min.X != row.col[i-1].r.Max.X+b {
+			t.Errorf("column %v starts at %v; want %v", i, c.r.Min.X, row.col[i-1].r.Max.X+b)
+		}
+		if d := c.r.Dx() - row.col[0].r.Dx(); d < -b-1 || d > b+1 {
+			t.Errorf("column %v is %v wide; want about %v", i, c.r.Dx(), row.col[0].r.Dx())
+		}
+	}
+	if c := row.col[len(row.col)-1]; c.r.Max.X != row.r.Max.X {
+		t.Errorf("last column ends at %v; want %v", c.r.Max.X, row.r.Max.X)
+	}
+}
@@ -146,6 +146,9 @@ func look3(t *Text, q0 int, q1 int, external bool) {
 		}
 		return
 	}
+	if t.w != nil && t.what == Body && issessionswin(t.w) && lookSession(t, q0, q1) {
+		return
+	}
 	if plumbsendfid != nil {
 		m, err := look3Message(t, q0, q1)
 		if err != nil {
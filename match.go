@@ -0,0 +1,149 @@
+package main
+
+import (
+	"github.com/rjkroege/edwood/internal/runes"
+)
+
+// matchlimit is how far the bracket matching the one beside the
+// insertion point is looked for.
+const matchlimit = 20000
+
+// isescaped returns true if the rune at q follows a backslash.
+func (t *Text) isescaped(q int) bool {
+	return q > 0 && t.file.ReadC(q-1) == '\\'
+}
+
+// quotematch returns the position of the quote matching the one at q.
+// Quotes pair up along a line: the first opens a quotation, the second
+// closes it and so on.
+func (t *Text) quotematch(q int) (int, bool) {
+	c := t.file.ReadC(q)
+	if t.isescaped(q) {
+		return 0, false
+	}
+	ls := t.linestart(q)
+	n := 0
+	for p := ls; p < q; p++ {
+		if t.file.ReadC(p) == c && !t.isescaped(p) {
+			n++
+		}
+	}
+	if n%2 == 1 {
+		for p := q - 1; p >= ls; p-- {
+			if t.file.ReadC(p) == c && !t.isescaped(p) {
+				return p, true
+			}
+		}
+		return 0, false
+	}
+	for p := q + 1; p < t.file.Size(); p++ {
+		switch t.file.ReadC(p) {
+		case '\n':
+			return 0, false
+		case c:
+			if !t.isescaped(p) {
+				return p, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// runematch returns the position of the bracket or quote matching the
+// one at q.
+func (t *Text) runematch(q int) (int, bool) {
+	c := t.file.ReadC(q)
+	if runes.IndexRune(left3, c) != -1 {
+		return t.quotematch(q)
+	}
+	if p := runes.IndexRune(left1, c); p != -1 {
+		m, ok := t.clickmatch(c, right1[p], 1, q+1, matchlimit)
+		return m - 1, ok
+	}
+	if p := runes.IndexRune(right1, c); p != -1 {
+		return t.clickmatch(c, left1[p], -1, q, matchlimit)
+	}
+	return 0, false
+}
+
+// matchat finds the bracket, quote or HTML tag beside position q,
+// preferring the one to the left, and the one matching it. It returns
+// the ranges of the two.
+func (t *Text) matchat(q int) (this, other Range, ok bool) {
+	if q0, q1, ok := t.ClickHTMLMatch(q); ok {
+		start, _ := t.ishtmlend(q0)
+		end, _ := t.ishtmlstart(q1)
+		if q == q0 {
+			// After an opening tag.
+			return Range{start, q0}, Range{q1, end}, true
+		}
+		// Before a closing tag.
+		return Range{q1, end}, Range{start, q0}, true
+	}
+	for _, p := range []int{q - 1, q} {
+		if p < 0 || p >= t.file.Size() {
+			continue
+		}
+		if m, ok := t.runematch(p); ok {
+			return Range{p, p + 1}, Range{m, m + 1}, true
+		}
+	}
+	return Range{}, Range{}, false
+}
+
+// showmatch highlights the bracket, quote or HTML tag beside the
+// insertion point of a window body and the one matching it.
+func (t *Text) showmatch() {
+	if t.what != Body || t.w == nil || t.fr == nil {
+		return
+	}
+	var match []Span
+	if !t.w.nomatch && t.q0 == t.q1 {
+		if this, other, ok := t.matchat(t.q0); ok {
+			match = []Span{{this.q0, this.q1, TokenMatch}, {other.q0, other.q1, TokenMatch}}
+			if other.q0 < this.q0 {
+				match[0], match[1] = match[1], match[0]
+			}
+		}
+	}
+	if len(match) == 0 && len(t.match) == 0 {
+		return
+	}
+	if len(match) == len(t.match) && match[0] == t.match[0] && match[1] == t.match[1] {
+		return
+	}
+	t.match = match
+	t.highlight()
+}
+
+// JumpMatch moves the insertion point to the other side of the
+// bracket, quote or HTML tag matching the one beside it: from just
+// inside one bracket to just inside its match and from just outside to
+// just outside. It returns false if there is nothing to match.
+func (t *Text) JumpMatch() bool {
+	if t.q0 != t.q1 {
+		return false
+	}
+	this, other, ok := t.matchat(t.q0)
+	if !ok {
+		return false
+	}
+	q := other.q1
+	if t.q0 == this.q1 {
+		q = other.q0
+	}
+	t.Show(q, q, true)
+	return true
+}
+
+// matchx implements the Match command, which moves the insertion point
+// of the window's body to the match of the bracket, quote or HTML tag
+// beside it.
+func matchx(et *Text, _ *Text, _ *Text, _, _ bool, _ string) {
+	if et == nil || et.w == nil {
+		return
+	}
+	t := &et.w.body
+	et.w.Commit(t)
+	t.JumpMatch()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMatchAt(t *testing.T) {
+	w := loadWindowForTesting(t, `f(a[1], "x\"y")`+"\n<b>hi</b>\n")
+
+	for _, tc := range []struct {
+		q           int
+		this, other Range
+		jump        int
+	}{
+		{2, Range{1, 2}, Range{14, 15}, 14},
+		{15, Range{14, 15}, Range{1, 2}, 1},
+		{6, Range{5, 6}, Range{3, 4}, 3},
+		{3, Range{3, 4}, Range{5, 6}, 6},
+		{14, Range{13, 14}, Range{8, 9}, 8},
+		{9, Range{8, 9}, Range{13, 14}, 13},
+		{19, Range{16, 19}, Range{21, 25}, 21},
+		{21, Range{21, 25}, Range{16, 19}, 19},
+	} {
+		this, other, ok := w.body.matchat(tc.q)
+		if !ok || this != tc.this || other != tc.other {
+			t.Errorf("matchat(%v) is %v, %v, %v; want %v, %v", tc.q, this, other, ok, tc.this, tc.other)
+		}
+		w.body.SetSelect(tc.q, tc.q)
+		if !w.body.JumpMatch() || w.body.q0 != tc.jump || w.body.q1 != tc.jump {
+			t.Errorf("JumpMatch from %v moved to %v; want %v", tc.q, w.body.q0, tc.jump)
+		}
+	}
+	for _, q := range []int{0, 11, 20} {
+		if this, other, ok := w.body.matchat(q); ok {
+			t.Errorf("matchat(%v) found %v, %v", q, this, other)
+		}
+	}
+}
+
+func TestShowMatch(t *testing.T) {
+	w := loadWindowForTesting(t, "{ab}\n")
+
+	w.body.SetSelect(1, 1)
+	want := []Span{{0, 1, TokenMatch}, {3, 4, TokenMatch}}
+	if diff := cmp.Diff(want, w.body.match); diff != "" {
+		t.Errorf("match mismatch (-want +got):\n%s", diff)
+	}
+	w.body.SetSelect(1, 2)
+	if w.body.match != nil {
+		t.Errorf("match is %v with a selection; want none", w.body.match)
+	}
+	w.nomatch = true
+	w.body.SetSelect(4, 4)
+	if w.body.match != nil {
+		t.Errorf("match is %v when turned off; want none", w.body.match)
+	}
+}
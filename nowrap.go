@@ -0,0 +1,130 @@
+package main
+
+import (
+	"strings"
+)
+
+// SetNoWrap sets whether the lines of w's body are folded at the right
+// edge of the window (the default) or clipped there and scrolled
+// horizontally.
+func (w *Window) SetNoWrap(nowrap bool) {
+	t := &w.body
+	if t.nowrap == nowrap {
+		return
+	}
+	t.nowrap = nowrap
+	t.xoff = 0
+	if nowrap && t.org > 0 {
+		t.org = t.linestart(t.org)
+	}
+	if t.fr == nil || t.display == nil {
+		return
+	}
+	t.relayout()
+	t.ScrDraw(t.fr.GetFrameFillStatus().Nchars)
+}
+
+// linestart returns the position of the start of the line containing
+// q. Like Text.setorigin, it doesn't look further than 256 chars for
+// it, so a long line starts where the search gave up.
+func (t *Text) linestart(q int) int {
+	for i := 0; i < 256 && q > 0 && t.file.ReadC(q-1) != '\n'; i++ {
+		q--
+	}
+	return q
+}
+
+// textwidth returns the width in pixels of the widest line shown in
+// the frame of a Text that does not wrap.
+func (t *Text) textwidth() int {
+	left := t.fr.Rect().Min.X - t.xoff
+	nchars := t.fr.GetFrameFillStatus().Nchars
+	wid := t.fr.Ptofchar(nchars).X - left
+	for p := 0; p < nchars; p++ {
+		if t.file.ReadC(t.org+p) == '\n' {
+			if x := t.fr.Ptofchar(p).X - left; x > wid {
+				wid = x
+			}
+		}
+	}
+	return wid
+}
+
+// SetXOffset scrolls the text of a Text that does not wrap so that x
+// pixels of each line are hidden to the left of the frame. The text
+// can be scrolled until the end of the widest line shown is half way
+// across the frame.
+func (t *Text) SetXOffset(x int) {
+	if !t.nowrap || t.fr == nil {
+		return
+	}
+	if xmax := t.textwidth() - t.fr.Rect().Dx()/2; x > xmax {
+		x = xmax
+	}
+	if x < 0 {
+		x = 0
+	}
+	if x == t.xoff {
+		return
+	}
+	t.xoff = x
+	t.relayout()
+	t.ScrDraw(t.fr.GetFrameFillStatus().Nchars)
+}
+
+// ScrollX scrolls the text of a Text that does not wrap by n quarters
+// of the width of its frame, to the right if n is positive.
+func (t *Text) ScrollX(n int) {
+	if !t.nowrap || t.fr == nil {
+		return
+	}
+	t.SetXOffset(t.xoff + n*t.fr.Rect().Dx()/4)
+}
+
+// showx scrolls the text of a Text that does not wrap horizontally so
+// that the position q, which must be in the frame, is visible. It only
+// does so once q has left the columns shown, so typing within them
+// doesn't lay out the frame again.
+func (t *Text) showx(q int) {
+	if !t.nowrap || t.fr == nil || q < t.org || q > t.org+t.fr.GetFrameFillStatus().Nchars {
+		return
+	}
+	r := t.fr.Rect()
+	x := t.fr.Ptofchar(q - t.org).X
+	switch {
+	case x < r.Min.X:
+		t.xoff -= r.Min.X - x + r.Dx()/4
+	case x >= r.Max.X:
+		t.xoff += x - r.Max.X + r.Dx()/4
+	default:
+		return
+	}
+	if t.xoff < 0 {
+		t.xoff = 0
+	}
+	t.relayout()
+	t.ScrDraw(t.fr.GetFrameFillStatus().Nchars)
+}
+
+// wrapx implements the Wrap command. With an argument of on or off, it
+// sets whether the lines of the window's body are folded at the right
+// edge of the window; without one it switches between the two.
+func wrapx(et *Text, _ *Text, argt *Text, _, _ bool, arg string) {
+	if et == nil || et.w == nil {
+		return
+	}
+	r, _ := getarg(argt, false, true)
+	if r == "" {
+		r = strings.TrimSpace(arg)
+	}
+	switch r {
+	case "":
+		et.w.SetNoWrap(!et.w.body.nowrap)
+	case "on":
+		et.w.SetNoWrap(false)
+	case "off":
+		et.w.SetNoWrap(true)
+	default:
+		warning(nil, "Wrap: bad argument %q\n", r)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNoWrap(t *testing.T) {
+	long := strings.Repeat("x", 500) + "\n"
+	w := loadWindowForTesting(t, "a\n"+long+strings.Repeat("b\n", 20))
+	fr := w.body.fr.Rect()
+	height := w.body.fr.DefaultFontHeight()
+
+	w.SetNoWrap(true)
+	if got := w.body.fr.Rect(); got != fr {
+		t.Errorf("frame is %v without wrapping; want %v", got, fr)
+	}
+	// The line after the long line is on the third row.
+	if got, want := w.body.fr.Ptofchar(2+len(long)).Y, fr.Min.Y+2*height; got != want {
+		t.Errorf("line after long line at y=%v; want %v", got, want)
+	}
+
+	// Showing the end of the long line scrolls it into view.
+	q := 2 + len(long) - 1
+	w.body.Show(q, q, true)
+	if w.body.xoff == 0 {
+		t.Fatalf("Show of %v did not scroll", q)
+	}
+	if pt := w.body.fr.Ptofchar(q - w.body.org); !pt.In(fr) {
+		t.Errorf("dot at %v is outside %v", pt, fr)
+	}
+	w.body.Show(0, 0, true)
+	if got := w.body.xoff; got != 0 {
+		t.Errorf("xoff is %v after showing start; want 0", got)
+	}
+
+	w.body.Type(Kscrolloneright)
+	xoff := w.body.xoff
+	if xoff <= 0 {
+		t.Errorf("xoff is %v after scrolling right; want > 0", xoff)
+	}
+	if got, want := w.body.fr.Ptofchar(0).X, fr.Min.X-xoff; got != want {
+		t.Errorf("rune 0 at x=%v; want %v", got, want)
+	}
+	for i := 0; i < 100; i++ {
+		w.body.Type(Kscrolloneright)
+	}
+	if got, want := w.body.xoff, w.body.textwidth()-fr.Dx()/2; got != want {
+		t.Errorf("xoff is %v after scrolling far right; want %v", got, want)
+	}
+	w.body.Type(Kscrolloneleft)
+	if got := w.body.xoff; got >= w.body.textwidth()-fr.Dx()/2 {
+		t.Errorf("xoff is %v after scrolling left; want less", got)
+	}
+
+	// Rows start at the start of a line.
+	w.body.SetOrigin(100, true)
+	if got, want := w.body.org, 2; got != want {
+		t.Errorf("origin is %v; want %v", got, want)
+	}
+
+	dump, err := row.dump()
+	if err != nil {
+		t.Fatalf("dump failed: %v", err)
+	}
+	if !dump.Windows[0].NoWrap || dump.Windows[1].NoWrap {
+		t.Errorf("dumped NoWrap is %v, %v; want true, false", dump.Windows[0].NoWrap, dump.Windows[1].NoWrap)
+	}
+
+	w.SetNoWrap(false)
+	if got := w.body.xoff; got != 0 {
+		t.Errorf("xoff is %v after wrapping; want 0", got)
+	}
+	if got, want := w.body.fr.Ptofchar(2+len(long)-w.body.org).Y, fr.Min.Y+3*height; got < want {
+		t.Errorf("line after long line at y=%v when wrapped; want at least %v", got, want)
+	}
+}
+
+func TestNoWrapLongLine(t *testing.T) {
+	w := loadWindowForTesting(t, strings.Repeat("x", 10000))
+	w.SetNoWrap(true)
+
+	// The start of the row is looked for no further than 256 runes
+	// from where it is asked for.
+	for _, exact := range []bool{false, true} {
+		w.body.SetOrigin(5000, exact)
+		if got := w.body.org; got < 5000-256 || got > 5000+256 {
+			t.Errorf("origin of exact=%v is %v; want within 256 of 5000", exact, got)
+		}
+	}
+}
+
+func TestNoWrapType(t *testing.T) {
+	long := strings.Repeat("x", 500) + "\n"
+	w := loadWindowForTesting(t, "a\n"+long)
+	w.SetNoWrap(true)
+	fr := w.body.fr.Rect()
+
+	// Typing within the columns shown doesn't scroll.
+	w.body.SetSelect(1, 1)
+	w.body.Type('b')
+	if got := w.body.xoff; got != 0 {
+		t.Errorf("xoff is %v after typing at the start; want 0", got)
+	}
+
+	// Typing past the right edge scrolls to keep dot in view.
+	q := 3
+	for w.body.fr.Ptofchar(q-w.body.org).X < fr.Max.X {
+		q++
+	}
+	w.body.TypeCommit()
+	w.body.SetSelect(q-1, q-1)
+	w.body.Type('y')
+	w.body.Type('y')
+	if w.body.xoff == 0 {
+		t.Fatalf("typing past the right edge did not scroll")
+	}
+	if pt := w.body.fr.Ptofchar(w.body.q0 - w.body.org); !pt.In(fr) {
+		t.Errorf("dot at %v is outside %v", pt, fr)
+	}
+
+	// Erasing past the left edge scrolls back.
+	w.body.Type(0x15) // ^U
+	if got, want := w.body.q0, 3; got != want {
+		t.Fatalf("dot is at %v after erasing the line; want %v", got, want)
+	}
+	if got := w.body.xoff; got != 0 {
+		t.Errorf("xoff is %v after erasing to the start of the line; want 0", got)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rjkroege/edwood/internal/draw"
+)
+
+// Mouse and keyboard events are recorded one to a line, after the
+// milliseconds since recording started:
+//
+//	ms mouse x y buttons msec
+//	ms key rune
+//	ms resize
+//
+// where rune is the number of the rune typed. Blank lines and lines
+// starting with # are ignored.
+
+// An inputevent is a mouse event, a key or a resize.
+type inputevent struct {
+	t     time.Duration // since recording started
+	kind  string        // "mouse", "key" or "resize"
+	mouse draw.Mouse
+	key   rune
+}
+
+func (e *inputevent) String() string {
+	ms := int64(e.t / time.Millisecond)
+	switch e.kind {
+	case "mouse":
+		m := e.mouse
+		return fmt.Sprintf("%d mouse %d %d %d %d", ms, m.X, m.Y, m.Buttons, m.Msec)
+	case "key":
+		return fmt.Sprintf("%d key %d", ms, e.key)
+	}
+	return fmt.Sprintf("%d %s", ms, e.kind)
+}
+
+// parseinputevent parses a line of a recording.
+func parseinputevent(line string) (*inputevent, error) {
+	f := strings.Fields(line)
+	if len(f) < 2 {
+		return nil, fmt.Errorf("bad event %q", line)
+	}
+	var n []int
+	for _, s := range f[2:] {
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("bad event %q", line)
+		}
+		n = append(n, i)
+	}
+	ms, err := strconv.Atoi(f[0])
+	if err != nil || ms < 0 {
+		return nil, fmt.Errorf("bad time in event %q", line)
+	}
+	e := &inputevent{t: time.Duration(ms) * time.Millisecond, kind: f[1]}
+	switch {
+	case e.kind == "mouse" && len(n) == 4:
+		e.mouse.X, e.mouse.Y, e.mouse.Buttons, e.mouse.Msec = n[0], n[1], n[2], uint32(n[3])
+	case e.kind == "key" && len(n) == 1:
+		e.key = rune(n[0])
+	case e.kind == "resize" && len(n) == 0:
+	default:
+		return nil, fmt.Errorf("bad event %q", line)
+	}
+	return e, nil
+}
+
+// readinput reads the events of a recording.
+func readinput(r io.Reader) ([]*inputevent, error) {
+	var events []*inputevent
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		e, err := parseinputevent(line)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, sc.Err()
+}
+
+// recordinput returns a Mousectl and a Keyboardctl that deliver the
+// events of mc and kc after writing each to w, in the order they
+// arrive.
+func recordinput(mc *draw.Mousectl, kc *draw.Keyboardctl, w io.Writer) (*draw.Mousectl, *draw.Keyboardctl) {
+	mousec := make(chan draw.Mouse)
+	resizec := make(chan bool, 2)
+	keyboardc := make(chan rune, 20)
+
+	rmc := &draw.Mousectl{C: mousec, Resize: resizec}
+	var once sync.Once
+	start := time.Now()
+	write := func(e *inputevent) {
+		e.t = time.Since(start)
+		if _, err := fmt.Fprintln(w, e); err != nil {
+			once.Do(func() { warning(nil, "recording input failed: %v\n", err) })
+		}
+	}
+	go func() {
+		for {
+			select {
+			case m := <-mc.C:
+				write(&inputevent{kind: "mouse", mouse: m})
+				mousec <- m
+			case <-mc.Resize:
+				write(&inputevent{kind: "resize"})
+				resizec <- true
+			case r := <-kc.C:
+				write(&inputevent{kind: "key", key: r})
+				keyboardc <- r
+			}
+		}
+	}()
+	return rmc, &draw.Keyboardctl{C: keyboardc}
+}
+
+// An inputsender takes events as if from the mouse and keyboard, like
+// the display of -headless.
+type inputsender interface {
+	SendMouse(m draw.Mouse)
+	SendKey(r rune)
+	SendResize()
+}
+
+// replayinput sends the events recorded in r to display, keeping the
+// intervals between them. It returns once the last has been received.
+func replayinput(display draw.Display, r io.Reader) error {
+	s, ok := display.(inputsender)
+	if !ok {
+		return fmt.Errorf("can't replay input into this display")
+	}
+	events, err := readinput(r)
+	if err != nil {
+		return err
+	}
+	var last *inputevent
+	start := time.Now()
+	for _, e := range events {
+		time.Sleep(time.Until(start.Add(e.t)))
+		switch e.kind {
+		case "mouse":
+			s.SendMouse(e.mouse)
+			last = e
+		case "key":
+			s.SendKey(e.key)
+		case "resize":
+			s.SendResize()
+		}
+	}
+	// Sending the mouse again once its buttons are up waits for the
+	// last mouse event to have been handled.
+	if last != nil && last.mouse.Buttons == 0 {
+		s.SendMouse(last.mouse)
+	}
+	return nil
+}
+
+// replayfile replays the events recorded in file name into display.
+func replayfile(display draw.Display, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return replayinput(display, f)
+}
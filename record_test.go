@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rjkroege/edwood/internal/draw"
+	"github.com/rjkroege/edwood/internal/draw/memdraw"
+	"github.com/rjkroege/edwood/internal/edwoodtest"
+)
+
+func TestRecordInput(t *testing.T) {
+	mousec := make(chan draw.Mouse)
+	resizec := make(chan bool)
+	keyboardc := make(chan rune)
+	var buf bytes.Buffer
+	mc, kc := recordinput(&draw.Mousectl{C: mousec, Resize: resizec}, &draw.Keyboardctl{C: keyboardc}, &buf)
+
+	m := draw.Mouse{Point: image.Pt(10, 20), Buttons: 1, Msec: 1234}
+	mousec <- m
+	if got := <-mc.C; got != m {
+		t.Errorf("got mouse %v; want %v", got, m)
+	}
+	keyboardc <- 'α'
+	if got := <-kc.C; got != 'α' {
+		t.Errorf("got key %q; want %q", got, 'α')
+	}
+	resizec <- true
+	<-mc.Resize
+
+	events, err := readinput(&buf)
+	if err != nil {
+		t.Fatalf("can't read recording %q: %v", buf.String(), err)
+	}
+	var got []string
+	for _, e := range events {
+		e.t = 0
+		got = append(got, e.String())
+	}
+	want := []string{"0 mouse 10 20 1 1234", "0 key 945", "0 resize"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("recorded events differ (-want +got):\n%s", diff)
+	}
+}
+
+func TestReadInput(t *testing.T) {
+	events, err := readinput(strings.NewReader("# a comment\n\n5 mouse 1 2 4 100\n12 key 97\n"))
+	if err != nil {
+		t.Fatalf("readinput failed: %v", err)
+	}
+	var got []string
+	for _, e := range events {
+		got = append(got, e.String())
+	}
+	want := []string{"5 mouse 1 2 4 100", "12 key 97"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("events differ (-want +got):\n%s", diff)
+	}
+
+	for _, s := range []string{
+		"mouse 1 2 3 4",
+		"1 mouse 1 2 3",
+		"1 key a",
+		"1 resize 2",
+		"1 scroll 1",
+		"-1 resize",
+	} {
+		if _, err := readinput(strings.NewReader(s)); err == nil {
+			t.Errorf("read bad event %q", s)
+		}
+	}
+}
+
+func TestReplayInput(t *testing.T) {
+	d := memdraw.NewDisplay(image.Rect(0, 0, 100, 100))
+	mc, kc := d.InitMouse(), d.InitKeyboard()
+	var got []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for len(got) < 3 {
+			select {
+			case m := <-mc.C:
+				got = append(got, (&inputevent{kind: "mouse", mouse: m}).String())
+			case r := <-kc.C:
+				got = append(got, (&inputevent{kind: "key", key: r}).String())
+			}
+		}
+	}()
+	if err := replayinput(d, strings.NewReader("0 mouse 1 2 0 3\n1 key 120\n")); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	<-done
+	// The last mouse event is sent again to wait for it to be handled.
+	want := []string{"0 mouse 1 2 0 3", "0 key 120", "0 mouse 1 2 0 3"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("replayed events differ (-want +got):\n%s", diff)
+	}
+
+	if err := replayinput(edwoodtest.NewDisplay(), strings.NewReader("")); err == nil {
+		t.Errorf("replayed into a display that can't take events")
+	}
+}
@@ -142,12 +142,12 @@ func (row *Row) DragCol(c *Column, _ int) {
 	b = mouse.Buttons
 	op = mouse.Point
 	for mouse.Buttons == b {
-		mousectl.Read()
+		readmouse(row.display)
 	}
 	row.display.SetCursor(nil)
 	if mouse.Buttons != 0 {
 		for mouse.Buttons != 0 {
-			mousectl.Read()
+			readmouse(row.display)
 		}
 		return
 	}
@@ -330,6 +330,13 @@ func (r *Row) Dump(file string) error {
 }
 
 func (r *Row) dump() (*dumpfile.Content, error) {
+	return r.dumplocking(false)
+}
+
+// dumplocking is dump, but if lockwin is true, it locks each window
+// while copying its state, since the file server may be changing it.
+// The Dump command can't do so: it may run with a window locked.
+func (r *Row) dumplocking(lockwin bool) (*dumpfile.Content, error) {
 	dump := &dumpfile.Content{
 		CurrentDir: wdir,
 		VarFont:    *varfontflag,
@@ -348,6 +355,7 @@ func (r *Row) dump() (*dumpfile.Content, error) {
 	for i, c := range r.col {
 		dump.Columns[i] = dumpfile.Column{
 			Position: 100.0 * float64(c.r.Min.X-row.r.Min.X) / float64(r.r.Dx()),
+			Width:    c.r.Dx(),
 			Tag: dumpfile.Text{
 				Buffer: string(c.tag.file.b),
 				Q0:     c.tag.q0,
@@ -355,69 +363,99 @@ func (r *Row) dump() (*dumpfile.Content, error) {
 			},
 		}
 		for _, w := range c.w {
+			if lockwin {
+				w.Lock('D')
+			}
 			if w.nopen[QWevent] != 0 {
 				// Mark zeroxes of external windows specially.
 				dumpid[w.body.file] = -1
 			}
+			if lockwin {
+				w.Unlock()
+			}
 		}
 	}
 
-	for i, c := range r.col {
-		for _, w := range c.w {
-			// Do we need to Commit on the other tags?
-			w.Commit(&w.tag)
-			t := &w.body
-
-			// External windows can't be recreated so skip them.
-			if w.nopen[QWevent] > 0 {
-				if w.dumpstr == "" {
-					continue
-				}
-			}
+	dumpwindow := func(i int, c *Column, w *Window) {
+		// Do we need to Commit on the other tags?
+		w.Commit(&w.tag)
+		t := &w.body
+		w.Commit(t) // or what was just typed isn't dumped
 
-			// zeroxes of external windows are tossed
-			if dumpid[t.file] < 0 && w.nopen[QWevent] == 0 {
-				continue
+		// External windows can't be recreated so skip them.
+		if w.nopen[QWevent] > 0 {
+			if w.dumpstr == "" {
+				return
 			}
+		}
+
+		// zeroxes of external windows are tossed
+		if dumpid[t.file] < 0 && w.nopen[QWevent] == 0 {
+			return
+		}
 
-			// We always include the font name.
-			fontname := t.font
-
-			dump.Windows = append(dump.Windows, &dumpfile.Window{
-				Column: i,
-				Body: dumpfile.Text{
-					Buffer: "", // filled in later if Unsaved
-					Q0:     w.body.q0,
-					Q1:     w.body.q1,
-				},
-				Position: 100.0 * float64(w.r.Min.Y-c.r.Min.Y) / float64(c.r.Dy()),
-				Font:     fontname,
-			})
-			dw := dump.Windows[len(dump.Windows)-1]
-
-			switch {
-			case dumpid[t.file] > 0:
-				dw.Type = dumpfile.Zerox
-
-			case w.dumpstr != "":
-				dw.Type = dumpfile.Exec
-				dw.ExecDir = w.dumpdir
-				dw.ExecCommand = w.dumpstr
-
-			case !w.body.file.Dirty() && access(t.file.name) || w.body.file.IsDir():
-				dumpid[t.file] = w.id
-				dw.Type = dumpfile.Saved
-
-			default:
-				dumpid[t.file] = w.id
-				// TODO(rjk): Conceivably this is a bit of a layering violation?
-				dw.Type = dumpfile.Unsaved
-				dw.Body.Buffer = string(t.file.b)
+		// We always include the font name.
+		fontname := t.font
+
+		dump.Windows = append(dump.Windows, &dumpfile.Window{
+			Column: i,
+			Body: dumpfile.Text{
+				Buffer: "", // filled in later if Unsaved
+				Q0:     w.body.q0,
+				Q1:     w.body.q1,
+			},
+			Position: 100.0 * float64(w.r.Min.Y-c.r.Min.Y) / float64(c.r.Dy()),
+			Font:     fontname,
+		})
+		dw := dump.Windows[len(dump.Windows)-1]
+		if w.body.gutter.mode != GutterNone {
+			dw.Gutter = w.body.gutter.mode.String()
+		}
+		dw.NoWrap = w.body.nowrap
+		dw.Origin = w.body.org
+		dw.TagExpand = w.tagexpand
+		dw.AutoIndent = w.autoindent
+		if w.body.tabstop != int(maxtab) {
+			dw.TabWidth = w.body.tabstop
+		}
+		dw.FileMenu = w.filemenu
+		dw.Scratch = w.body.file.isscratch
+
+		switch {
+		case dumpid[t.file] > 0:
+			dw.Type = dumpfile.Zerox
+
+		case w.dumpstr != "":
+			dw.Type = dumpfile.Exec
+			dw.ExecDir = w.dumpdir
+			dw.ExecCommand = w.dumpstr
+			dw.ExecEnv = append([]string(nil), w.dumpenv...)
+			dw.Restart = w.restart
+
+		case !w.body.file.Dirty() && access(t.file.name) || w.body.file.IsDir():
+			dumpid[t.file] = w.id
+			dw.Type = dumpfile.Saved
+
+		default:
+			dumpid[t.file] = w.id
+			// TODO(rjk): Conceivably this is a bit of a layering violation?
+			dw.Type = dumpfile.Unsaved
+			dw.Body.Buffer = string(t.file.b)
+		}
+		dw.Tag = dumpfile.Text{
+			Buffer: string(w.tag.file.b),
+			Q0:     w.tag.q0,
+			Q1:     w.tag.q1,
+		}
+	}
+	for i, c := range r.col {
+		for _, w := range c.w {
+			if lockwin {
+				w.Lock('D')
 			}
-			dw.Tag = dumpfile.Text{
-				Buffer: string(w.tag.file.b),
-				Q0:     w.tag.q0,
-				Q1:     w.tag.q1,
+			dumpwindow(i, c, w)
+			if lockwin {
+				w.Unlock()
 			}
 		}
 	}
@@ -459,6 +497,16 @@ func (row *Row) loadhelper(win *dumpfile.Window) error {
 		w.SetName(subl[0])
 	}
 
+	// Window settings. Autoindent is only turned on so that -a still
+	// applies to windows dumped without it.
+	w.tagexpand = win.TagExpand
+	if win.AutoIndent {
+		w.autoindent = true
+	}
+	if win.TabWidth > 0 {
+		w.body.tabstop = win.TabWidth
+	}
+
 	// TODO(rjk): I feel that the code for managing tags could be extracted and unified.
 	// Maybe later. Window.setTag1 would seem fixable.
 	afterbar := strings.SplitN(subl[1], "|", 2)
@@ -483,6 +531,14 @@ func (row *Row) loadhelper(win *dumpfile.Window) error {
 	if win.Font != "" {
 		fontx(&w.body, nil, nil, false, false, win.Font)
 	}
+	if mode, err := parseGutterMode(win.Gutter); err == nil {
+		w.SetGutter(mode)
+	}
+	w.SetNoWrap(win.NoWrap)
+
+	w.filemenu = win.FileMenu // after Get, which sets it
+	w.body.file.isscratch = win.Scratch
+	w.Resize(w.r, false, true) // for the tab width and tag expansion
 
 	q0 := win.Body.Q0
 	q1 := win.Body.Q1
@@ -490,8 +546,12 @@ func (row *Row) loadhelper(win *dumpfile.Window) error {
 		q0 = 0
 		q1 = 0
 	}
-	// Update the selection on the Text.
+	// Update the selection on the Text, then scroll back to where the
+	// window was.
 	w.body.Show(q0, q1, true)
+	if org := win.Origin; org > 0 && org <= len(w.body.file.b) {
+		w.body.SetOrigin(org, true)
+	}
 	ffs := w.body.fr.GetFrameFillStatus()
 	w.maxlines = min(ffs.Nlines, max(w.maxlines, ffs.Nlines))
 
@@ -550,15 +610,11 @@ func (row *Row) loadimpl(dump *dumpfile.Content, initing bool) error {
 		return fmt.Errorf("Load: bad number of columns %d", len(dump.Columns))
 	}
 
-	// TODO(rjk): put column width parsing in a separate function.
-	for i, col := range dump.Columns {
-		percent := col.Position
-		if percent < 0 || percent >= 100 {
-			return fmt.Errorf("Load: column width %f is invalid", percent)
-		}
-
-		x := int(float64(row.r.Min.X) + percent*float64(row.r.Dx())/100.0 + 0.5)
-
+	xs, err := row.columnpositions(dump.Columns)
+	if err != nil {
+		return err
+	}
+	for i, x := range xs {
 		// TODO(rjk): Sigh. A more explicit MVC would simplify thinking about this code.
 		if i < len(row.col) {
 			if i == 0 {
@@ -568,18 +624,19 @@ func (row *Row) loadimpl(dump *dumpfile.Content, initing bool) error {
 			c2 := row.col[i]
 			r1 := c1.r
 			r2 := c2.r
-			if x < Border {
-				x = Border
+			b := row.display.ScaleSize(Border)
+			if x < b {
+				x = b
 			}
-			r1.Max.X = x - Border
-			r2.Max.X = x
-			if r1.Dx() < 50 || r2.Dx() < 50 {
+			r1.Max.X = x - b
+			r2.Min.X = x
+			if r1.Dx() < row.display.ScaleSize(50) || r2.Dx() < row.display.ScaleSize(50) {
 				continue
 			}
 			row.display.ScreenImage().Draw(image.Rectangle{r1.Min, r2.Max}, row.display.White(), nil, image.Point{})
 			c1.Resize(r1)
 			c2.Resize(r2)
-			r2.Min.X = x - Border
+			r2.Min.X = x - b
 			r2.Max.X = x
 			row.display.ScreenImage().Draw(r2, row.display.Black(), nil, image.Point{})
 		}
@@ -607,12 +664,9 @@ func (row *Row) loadimpl(dump *dumpfile.Content, initing bool) error {
 	for _, win := range dump.Windows {
 		switch win.Type {
 		case dumpfile.Exec: // command block
-			dirline := win.ExecDir
-			if dirline == "" {
-				dirline = home
+			if win.Restart != dumpfile.RestartNever {
+				rundump(win)
 			}
-			// log.Println("cmdline", cmdline, "dirline", dirline)
-			run(nil, win.ExecCommand, dirline, true, "", "", false)
 
 		case dumpfile.Saved, dumpfile.Unsaved, dumpfile.Zerox:
 			if err := row.loadhelper(win); err != nil {
@@ -626,6 +680,31 @@ func (row *Row) loadimpl(dump *dumpfile.Content, initing bool) error {
 	return nil
 }
 
+// columnpositions returns where the columns of a dump file start: at
+// the same x as when dumped if the row is as wide as it was then, or
+// else at the same fraction of its width.
+func (row *Row) columnpositions(cols []dumpfile.Column) ([]int, error) {
+	xs := make([]int, len(cols))
+	b := row.display.ScaleSize(Border)
+	x, haswidths := row.r.Min.X, true
+	for i, col := range cols {
+		percent := col.Position
+		if percent < 0 || percent >= 100 {
+			return nil, fmt.Errorf("Load: column width %f is invalid", percent)
+		}
+		xs[i] = x
+		x += col.Width + b
+		haswidths = haswidths && col.Width > 0
+	}
+	if haswidths && x-b == row.r.Max.X {
+		return xs, nil
+	}
+	for i, col := range cols {
+		xs[i] = int(float64(row.r.Min.X) + col.Position*float64(row.r.Dx())/100.0 + 0.5)
+	}
+	return xs, nil
+}
+
 func (r *Row) AllWindows(f func(*Window)) {
 	for _, c := range r.col {
 		for _, w := range c.w {
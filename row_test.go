@@ -181,6 +181,9 @@ func checkDump(t *testing.T, got, want *dumpfile.Content) {
 		if math.Abs(got.Columns[i].Position-c.Position) < 1 {
 			got.Columns[i].Position = c.Position
 		}
+		if c.Width == 0 { // dump file format 1 has no widths
+			got.Columns[i].Width = 0
+		}
 	}
 	for i, w := range want.Windows {
 		g := got.Windows[i]
@@ -215,6 +218,115 @@ func checkDump(t *testing.T, got, want *dumpfile.Content) {
 	}
 }
 
+func TestRowDumpLoadVersion2(t *testing.T) {
+	setGlobalsForLoadTesting()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get current working directory: %v", err)
+	}
+	var body strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&body, "line %d\n", i)
+	}
+	org := strings.Index(body.String(), "line 40\n")
+
+	// The positions of the columns disagree with their widths, which
+	// are used because the row is as wide as when they were dumped.
+	b := row.display.ScaleSize(Border)
+	w0 := 300
+	w1 := row.r.Dx() - w0 - b
+	want := &dumpfile.Content{
+		CurrentDir: cwd,
+		VarFont:    *varfontflag,
+		FixedFont:  *fixedfontflag,
+		Columns: []dumpfile.Column{
+			{Position: 0, Width: w0},
+			{Position: 50, Width: w1},
+		},
+		Windows: []*dumpfile.Window{
+			{
+				Type:       dumpfile.Unsaved,
+				Tag:        dumpfile.Text{Buffer: cwd + "/numbers Del Snarf | Look "},
+				Body:       dumpfile.Text{Buffer: body.String(), Q0: org, Q1: org + 4},
+				Origin:     org,
+				AutoIndent: true,
+				TabWidth:   8,
+				Scratch:    true,
+			},
+			{
+				Type:      dumpfile.Unsaved,
+				Column:    1,
+				Tag:       dumpfile.Text{Buffer: cwd + "/+Errors Del Snarf | Look "},
+				Body:      dumpfile.Text{Buffer: "hi\n"},
+				TagExpand: true,
+				FileMenu:  true,
+			},
+		},
+	}
+	if err := row.loadimpl(want, true); err != nil {
+		t.Fatalf("loadimpl failed: %v", err)
+	}
+	got, err := row.dump()
+	if err != nil {
+		t.Fatalf("dump failed: %v", err)
+	}
+	for i, c := range got.Columns {
+		if c.Width != want.Columns[i].Width {
+			t.Errorf("column %v is %v wide; want %v", i, c.Width, want.Columns[i].Width)
+		}
+	}
+	if len(got.Windows) != len(want.Windows) {
+		t.Fatalf("dumped %v windows; want %v", len(got.Windows), len(want.Windows))
+	}
+	for i, w := range want.Windows {
+		g := got.Windows[i]
+		gs := []interface{}{g.Body.Q0, g.Body.Q1, g.Origin, g.TagExpand, g.AutoIndent, g.TabWidth, g.FileMenu, g.Scratch}
+		ws := []interface{}{w.Body.Q0, w.Body.Q1, w.Origin, w.TagExpand, w.AutoIndent, w.TabWidth, w.FileMenu, w.Scratch}
+		if diff := cmp.Diff(ws, gs); diff != "" {
+			t.Errorf("window %v: selection, origin, tagexpand, autoindent, tab width, filemenu and scratch mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+func TestRowLoadMovesColumns(t *testing.T) {
+	setGlobalsForLoadTesting()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get current working directory: %v", err)
+	}
+	row.Add(nil, -1)
+	row.Add(nil, -1)
+
+	// Loading into a row with columns moves the boundaries between
+	// them rather than adding more.
+	dump := &dumpfile.Content{
+		CurrentDir: cwd,
+		VarFont:    *varfontflag,
+		FixedFont:  *fixedfontflag,
+		Columns: []dumpfile.Column{
+			{Position: 0},
+			{Position: 30},
+		},
+	}
+	xs, err := row.columnpositions(dump.Columns)
+	if err != nil {
+		t.Fatalf("columnpositions failed: %v", err)
+	}
+	if err := row.loadimpl(dump, false); err != nil {
+		t.Fatalf("loadimpl failed: %v", err)
+	}
+	if got, want := len(row.col), 2; got != want {
+		t.Fatalf("row has %v columns; want %v", got, want)
+	}
+	b := row.display.ScaleSize(Border)
+	if got, want := row.col[0].r.Max.X, xs[1]-b; got != want {
+		t.Errorf("column 0 ends at %v; want %v", got, want)
+	}
+	if got, want := row.col[1].r, image.Rect(xs[1], row.col[1].r.Min.Y, row.r.Max.X, row.col[1].r.Max.Y); got != want {
+		t.Errorf("column 1 is %v; want %v", got, want)
+	}
+}
+
 func TestRowDumpError(t *testing.T) {
 	var r Row
 
@@ -440,6 +552,24 @@ func setGlobalsForLoadTesting() {
 	row.Init(display.ScreenImage().R(), display)
 }
 
+// loadWindowForTesting loads testdata/example.dump and returns its
+// first window, holding text.
+func loadWindowForTesting(t *testing.T, text string) *Window {
+	t.Helper()
+
+	filename := editDumpFileForTesting(t, filepath.Join("testdata", "example.dump"))
+	defer os.Remove(filename)
+	setGlobalsForLoadTesting()
+	if err := row.Load(nil, filename, true); err != nil {
+		t.Fatalf("Row.Load failed: %v", err)
+	}
+	w := row.LookupWin(1)
+	w.body.file.DeleteAt(0, w.body.file.Size())
+	w.body.file.InsertAt(0, []rune(text))
+	w.body.SetOrigin(0, true)
+	return w
+}
+
 func replacePathsForTesting(t *testing.T, b []byte, isJSON bool) []byte {
 	cwd, err := os.Getwd()
 	if err != nil {
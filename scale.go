@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+
+	"github.com/rjkroege/edwood/internal/draw"
+	"github.com/rjkroege/edwood/internal/frame"
+)
+
+// sepwidth returns the thickness of the line between the tag and the
+// body of a window: one pixel scaled for the display but never less.
+func sepwidth(display draw.Display) int {
+	if display == nil {
+		return 1
+	}
+	if n := display.ScaleSize(1); n > 1 {
+		return n
+	}
+	return 1
+}
+
+// parsescale parses a scale factor: a positive number or auto, which
+// is 0 and means the factor given by the resolution of the display.
+func parsescale(s string) (float64, error) {
+	if s == "auto" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f <= 0 || f > 16 {
+		return 0, fmt.Errorf("bad scale %q", s)
+	}
+	return f, nil
+}
+
+// SetScale sets the factor by which the sizes of everything drawn are
+// scaled, overriding the resolution of the display, and draws
+// everything again. A factor of 0 goes back to the resolution.
+func SetScale(display draw.Display, scale float64) {
+	if display == nil {
+		return
+	}
+	display.SetScale(scale)
+	rescale(display, row.r)
+}
+
+// rescale draws everything again after the scale of display changed,
+// because a scale factor was set or because the window moved to a
+// monitor of another resolution: it opens the fonts again, makes new
+// buttons and scroll bars and lays out the row in r.
+func rescale(display draw.Display, r image.Rectangle) {
+	for name := range fontCache {
+		delete(fontCache, name)
+	}
+	iconinit(display)
+	ScrlResize(display)
+
+	setfont := func(t *Text) {
+		if t.fr != nil {
+			t.fr.Init(t.fr.Rect(), frame.OptFont(t.getfont()))
+		}
+	}
+	setfont(&row.tag)
+	for _, c := range row.col {
+		setfont(&c.tag)
+		for _, w := range c.w {
+			setfont(&w.tag)
+			setfont(&w.body)
+			w.tagsafe = false
+		}
+	}
+	row.Resize(r)
+	display.Flush()
+}
+
+// scalex implements the Scale command. With a number, it scales
+// everything by that factor; with auto, it goes back to the scale
+// given by the resolution of the display. Without an argument, it
+// shows the current scale.
+func scalex(_ *Text, _ *Text, argt *Text, _, _ bool, arg string) {
+	if row.display == nil {
+		return
+	}
+	r, _ := getarg(argt, false, true)
+	if r == "" {
+		r = strings.TrimSpace(arg)
+	}
+	if r == "" {
+		warning(nil, "scale %g\n", float64(row.display.ScaleSize(1000))/1000)
+		return
+	}
+	s, err := parsescale(r)
+	if err != nil {
+		warning(nil, "Scale: %v\n", err)
+		return
+	}
+	SetScale(row.display, s)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSetScale(t *testing.T) {
+	w := loadWindowForTesting(t, "hello\n")
+	defer row.display.SetScale(0)
+
+	if err := acmectlwrite("scale 2\n"); err != nil {
+		t.Fatalf("scale control message failed: %v", err)
+	}
+	if got, want := w.body.scrollr.Dx(), 2*Scrollwid; got != want {
+		t.Errorf("scroll bar is %d wide; want %d", got, want)
+	}
+	if got, want := w.body.fr.Rect().Min.Y-w.tag.fr.Rect().Max.Y, 2; got != want {
+		t.Errorf("tag and body are %d apart; want %d", got, want)
+	}
+	if got, want := w.body.fr.Rect().Min.X-w.body.scrollr.Max.X, 2*Scrollgap; got != want {
+		t.Errorf("scroll bar and text are %d apart; want %d", got, want)
+	}
+
+	if err := acmectlwrite("scale auto"); err != nil {
+		t.Fatalf("scale control message failed: %v", err)
+	}
+	if got := w.body.scrollr.Dx(); got != 0 {
+		t.Errorf("scroll bar is %d wide after going back to the display's scale; want 0", got)
+	}
+	for _, s := range []string{"scale", "scale big", "scale -1", "scale 0"} {
+		if err := acmectlwrite(s); err == nil {
+			t.Errorf("control message %q succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rjkroege/edwood/internal/draw"
+)
+
+// defaultscreenshot is the file a screenshot is written to if none is
+// named.
+const defaultscreenshot = "edwood.png"
+
+// screenshot writes the part r of the screen of display to file name
+// as a PNG. Only displays whose screens implement draw.Snapshotter,
+// such as the one of -headless, can be captured.
+func screenshot(display draw.Display, r image.Rectangle, name string) error {
+	if display == nil {
+		return fmt.Errorf("no display")
+	}
+	s, ok := display.ScreenImage().(draw.Snapshotter)
+	if !ok {
+		return fmt.Errorf("can't capture this display")
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, s.Snapshot(r)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// screenshotx implements the Screenshot command, which writes a PNG
+// of the window it is run in or, run in the tag of a column or of the
+// row, of the whole screen. The file is named by the argument,
+// relative to the directory of the window, or is edwood.png.
+func screenshotx(et *Text, _ *Text, argt *Text, _, _ bool, arg string) {
+	name, _ := getarg(argt, false, true)
+	if name == "" {
+		name = strings.TrimSpace(arg)
+	}
+	if name == "" {
+		name = defaultscreenshot
+	}
+	r := row.r
+	if et != nil && et.w != nil {
+		r = et.w.r
+	}
+	if !filepath.IsAbs(name) && et != nil {
+		name = et.AbsDirName(name)
+	}
+	if err := screenshot(row.display, r, name); err != nil {
+		warning(nil, "Screenshot: %v\n", err)
+	}
+}
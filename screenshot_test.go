@@ -0,0 +1,121 @@
+package main
+
+import (
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rjkroege/edwood/internal/draw"
+	"github.com/rjkroege/edwood/internal/draw/memdraw"
+	"github.com/rjkroege/edwood/internal/edwoodtest"
+	"github.com/rjkroege/edwood/internal/frame"
+)
+
+// setGlobalsForDrawTesting resets the row to one on an in-memory
+// display of size r, drawing in the fonts under build/font. It also
+// returns a function restoring the fonts and colours the other tests use.
+func setGlobalsForDrawTesting(r image.Rectangle) (*memdraw.Display, func()) {
+	font := filepath.Join("build", "font", "lucsans", "euro.8.font")
+	oldtag, oldvar, oldfixed := tagfont, *varfontflag, *fixedfontflag
+	oldtagcolors, oldtextcolors, oldscrtmp := tagcolors, textcolors, scrtmp
+	oldcols := []draw.Image{modbuttoncol, colbuttoncol, but2col, but3col, scrollbarcol, scrollthumbcol, guttercol, whitespacecol}
+	restore := func() {
+		tagfont, *varfontflag, *fixedfontflag = oldtag, oldvar, oldfixed
+		tagcolors, textcolors, scrtmp = oldtagcolors, oldtextcolors, oldscrtmp
+		modbuttoncol, colbuttoncol, but2col, but3col = oldcols[0], oldcols[1], oldcols[2], oldcols[3]
+		scrollbarcol, scrollthumbcol, guttercol, whitespacecol = oldcols[4], oldcols[5], oldcols[6], oldcols[7]
+		fontCache = make(map[string]draw.Font)
+		setGlobalsForLoadTesting()
+	}
+	tagfont, *varfontflag, *fixedfontflag = font, font, font
+	tagcolors[frame.ColBack] = nil
+
+	setGlobalsForLoadTesting()
+	fontCache = make(map[string]draw.Font)
+	display := memdraw.NewDisplay(r)
+	iconinit(display)
+	ScrlResize(display)
+	row = Row{}
+	row.Init(r, display)
+	return display, restore
+}
+
+// addDrawTestingWindow adds a column holding a window called name
+// with body text to the row.
+func addDrawTestingWindow(name, text string) *Window {
+	c := row.Add(nil, -1)
+	w := c.Add(nil, nil, -1)
+	w.SetName(name)
+	w.body.Insert(0, []rune(text), true)
+	w.body.SetOrigin(0, true)
+	w.body.file.Clean()
+	w.SetTag()
+	return w
+}
+
+func TestGoldenLayout(t *testing.T) {
+	r := image.Rect(0, 0, 400, 200)
+	display, restore := setGlobalsForDrawTesting(r)
+	defer restore()
+	addDrawTestingWindow("/a/file.txt", "hello, world\n\tindented\n")
+	w := addDrawTestingWindow("/b/other", strings.Repeat("many\nlines\nof\ntext\n", 10))
+	w.body.SetOrigin(11, true)
+	w.body.SetSelect(14, 18)
+
+	edwoodtest.CheckGolden(t, display, r, "golden/layout")
+	edwoodtest.CheckGolden(t, display, w.body.scrollr, "golden/scrollbar")
+}
+
+func TestScreenshot(t *testing.T) {
+	r := image.Rect(0, 0, 400, 200)
+	_, restore := setGlobalsForDrawTesting(r)
+	defer restore()
+	w := addDrawTestingWindow("/a/file.txt", "hello\n")
+	dir, err := ioutil.TempDir("", "edwood.test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tc := range []struct {
+		name  string
+		write func(file string) error
+		want  image.Rectangle
+	}{
+		{"acme/ctl", func(file string) error { return acmectlwrite("screenshot " + file) }, r},
+		{"command", func(file string) error {
+			screenshotx(&w.body, nil, nil, false, false, file)
+			return nil
+		}, w.r},
+	} {
+		file := filepath.Join(dir, "shot.png")
+		os.Remove(file)
+		if err := tc.write(file); err != nil {
+			t.Errorf("%v: screenshot failed: %v", tc.name, err)
+			continue
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			t.Errorf("%v: %v", tc.name, err)
+			continue
+		}
+		m, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Errorf("%v: bad PNG: %v", tc.name, err)
+			continue
+		}
+		if got := m.Bounds().Size(); got != tc.want.Size() {
+			t.Errorf("%v: screenshot is %v; want %v", tc.name, got, tc.want.Size())
+		}
+	}
+
+	setGlobalsForLoadTesting()
+	if err := acmectlwrite("screenshot " + filepath.Join(dir, "mock.png")); err == nil {
+		t.Errorf("screenshot of mock display succeeded")
+	}
+}
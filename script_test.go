@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"9fans.net/go/plan9"
+	"9fans.net/go/plan9/client"
+)
+
+// Scripts in testdata/script drive an Edwood, running in the test
+// process with an in-memory display, through its file system. Each
+// line of a script is a command, and blank lines and lines starting
+// with # are ignored. A text argument is the rest of the line, or a Go
+// string literal if quoted, so that it can hold newlines.
+//
+//	write file text     write text to file, such as new/body or 2/ctl
+//	read file text      check that file holds exactly text
+//	match file regexp   check that file holds text matching regexp
+//	exec win text       execute text as if clicked with button 2 in
+//	                    the tag of window win
+//	events win          start reading the event file of window win
+//	event win regexp    check that the next event of window win,
+//	                    without its newline, matches regexp
+//
+// Setting $EDWOOD_SCRIPT_ACME to a program, such as plan9port's acme,
+// runs the scripts against it instead of Edwood, to compare the two.
+
+// A scriptRunner runs scripts against an Edwood.
+type scriptRunner struct {
+	t      *testing.T
+	fsys   *client.Fsys
+	name   string // of the script
+	line   int    // being run
+	events map[string]chan string
+	fids   []*client.Fid // of the event files, closed when the script ends
+}
+
+// runScript runs the script in text, called name, against the
+// Edwood fsys serves, reporting failed checks as errors of t.
+func runScript(t *testing.T, fsys *client.Fsys, name, text string) {
+	t.Helper()
+	s := &scriptRunner{t: t, fsys: fsys, name: name, events: make(map[string]chan string)}
+	defer func() {
+		for _, fid := range s.fids {
+			fid.Close()
+		}
+	}()
+	sc := bufio.NewScanner(strings.NewReader(text))
+	for sc.Scan() {
+		s.line++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := s.run(line); err != nil {
+			t.Errorf("%s:%d: %s: %v", name, s.line, line, err)
+		}
+	}
+}
+
+// scriptargs splits line into its command, n further words and the text
+// that follows them.
+func scriptargs(line string, n int) (cmd string, words []string, text string, err error) {
+	f := strings.SplitN(line, " ", n+2)
+	if len(f) < n+1 {
+		return "", nil, "", fmt.Errorf("too few arguments")
+	}
+	cmd, words = f[0], f[1:n+1]
+	if len(f) == n+2 {
+		text = strings.TrimSpace(f[n+1])
+	}
+	if strings.HasPrefix(text, `"`) {
+		if text, err = strconv.Unquote(text); err != nil {
+			return "", nil, "", fmt.Errorf("bad string: %v", err)
+		}
+	}
+	return cmd, words, text, nil
+}
+
+func (s *scriptRunner) run(line string) error {
+	cmd := strings.Fields(line)[0]
+	switch cmd {
+	case "write":
+		_, w, text, err := scriptargs(line, 1)
+		if err != nil {
+			return err
+		}
+		return s.write(w[0], text)
+	case "read", "match":
+		_, w, text, err := scriptargs(line, 1)
+		if err != nil {
+			return err
+		}
+		got, err := s.read(w[0])
+		if err != nil {
+			return err
+		}
+		if cmd == "read" && got != text {
+			return fmt.Errorf("got %q; want %q", got, text)
+		}
+		if cmd == "match" {
+			re, err := regexp.Compile(text)
+			if err != nil {
+				return err
+			}
+			if !re.MatchString(got) {
+				return fmt.Errorf("got %q; want match for %q", got, text)
+			}
+		}
+	case "exec":
+		_, w, text, err := scriptargs(line, 1)
+		if err != nil {
+			return err
+		}
+		return fsysExec(s.fsys, w[0], text)
+	case "events":
+		_, w, _, err := scriptargs(line, 1)
+		if err != nil {
+			return err
+		}
+		return s.startevents(w[0])
+	case "event":
+		_, w, text, err := scriptargs(line, 1)
+		if err != nil {
+			return err
+		}
+		re, err := regexp.Compile(text)
+		if err != nil {
+			return err
+		}
+		c, ok := s.events[w[0]]
+		if !ok {
+			return fmt.Errorf("not reading events of window %v", w[0])
+		}
+		select {
+		case e := <-c:
+			if !re.MatchString(e) {
+				return fmt.Errorf("got event %q; want match for %q", e, text)
+			}
+		case <-time.After(5 * time.Second):
+			return fmt.Errorf("no event")
+		}
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+	return nil
+}
+
+func (s *scriptRunner) write(file, text string) error {
+	return fsysWrite(s.fsys, file, text)
+}
+
+func (s *scriptRunner) read(file string) (string, error) {
+	return fsysRead(s.fsys, file)
+}
+
+// fsysWrite writes text to file of fsys.
+func fsysWrite(fsys *client.Fsys, file, text string) error {
+	fid, err := fsys.Open(file, plan9.OWRITE)
+	if err != nil {
+		return err
+	}
+	defer fid.Close()
+	_, err = fid.Write([]byte(text))
+	return err
+}
+
+// fsysRead returns the contents of file of fsys.
+func fsysRead(fsys *client.Fsys, file string) (string, error) {
+	fid, err := fsys.Open(file, plan9.OREAD)
+	if err != nil {
+		return "", err
+	}
+	defer fid.Close()
+	b, err := ioutil.ReadAll(fid)
+	return string(b), err
+}
+
+// fsysExec executes text as if clicked with button 2 in the tag of
+// window win of fsys. It writes to the event file, which is opened
+// first because doing so can change the tag.
+func fsysExec(fsys *client.Fsys, win, text string) error {
+	fid, err := fsys.Open(win+"/event", plan9.OWRITE)
+	if err != nil {
+		return err
+	}
+	defer fid.Close()
+	if err := fsysWrite(fsys, win+"/tag", " "+text); err != nil {
+		return err
+	}
+	tag, err := fsysRead(fsys, win+"/tag")
+	if err != nil {
+		return err
+	}
+	q0 := utf8.RuneCountInString(tag[:strings.LastIndex(tag, text)])
+	_, err = fmt.Fprintf(fid, "Mx%d %d\n", q0, q0+utf8.RuneCountInString(text))
+	return err
+}
+
+// startevents reads the event file of window win, one event to a
+// line, until the script ends.
+func (s *scriptRunner) startevents(win string) error {
+	fid, err := s.fsys.Open(win+"/event", plan9.OREAD)
+	if err != nil {
+		return err
+	}
+	s.fids = append(s.fids, fid)
+	c := make(chan string, 100)
+	s.events[win] = c
+	go func() {
+		sc := bufio.NewScanner(fid)
+		for sc.Scan() {
+			c <- sc.Text()
+		}
+	}()
+	return nil
+}
+
+var newwindowonce sync.Once
+
+// startScriptEdwood starts an Edwood in the test process, with the
+// windows -headless starts with, and attaches to its file system
+// through a pipe. It returns a function that stops it.
+func startScriptEdwood(t *testing.T) (*client.Fsys, func()) {
+	t.Helper()
+
+	validate := flag.Lookup("validateboxes").Value.String()
+	flag.Set("validateboxes", "true")
+	_, restore := setGlobalsForDrawTesting(image.Rect(0, 0, 1024, 768))
+	newwindowonce.Do(func() {
+		cnewwindow = make(chan *Window)
+		go newwindowthread()
+	})
+	cwd, err := os.Getwd()
+	if err != nil {
+		restore()
+		t.Fatalf("can't get working directory: %v", err)
+	}
+	activecol, seltext = nil, nil // in the row of an earlier test
+	row.Add(nil, -1)
+	readfile(row.Add(nil, -1), cwd)
+	xa, stop := startXfidallocthread()
+	cleanup := func() {
+		stop()
+		restore()
+		flag.Set("validateboxes", validate)
+	}
+
+	// Not a net.Pipe: the client can be writing a request while the
+	// reply the server is writing waits for it to read, so the
+	// connection needs a buffer.
+	c1, c2, err := loopbackConns()
+	if err != nil {
+		cleanup()
+		t.Fatalf("can't connect: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		serve9p(c2, nil, xa)
+		close(done)
+	}()
+	conn, err := client.NewConn(c1)
+	if err != nil {
+		c1.Close()
+		<-done
+		cleanup()
+		t.Fatalf("NewConn failed: %v", err)
+	}
+	fsys, err := conn.Attach(nil, getuser(), "")
+	if err != nil {
+		conn.Close()
+		<-done
+		cleanup()
+		t.Fatalf("Attach failed: %v", err)
+	}
+	return fsys, func() {
+		conn.Close()
+		<-done
+		cleanup()
+	}
+}
+
+// loopbackConns returns the two ends of a TCP connection on the
+// loopback interface.
+func loopbackConns() (net.Conn, net.Conn, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer l.Close()
+	c1, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		return nil, nil, err
+	}
+	c2, err := l.Accept()
+	if err != nil {
+		c1.Close()
+		return nil, nil, err
+	}
+	return c1, c2, nil
+}
+
+// startScriptAcme starts the Edwood that scripts run against, or the
+// program named by $EDWOOD_SCRIPT_ACME. It returns a function that
+// stops it.
+func startScriptAcme(t *testing.T) (*client.Fsys, func()) {
+	if prog := os.Getenv("EDWOOD_SCRIPT_ACME"); prog != "" {
+		a := startAcmeCmd(t, exec.Command(prog))
+		return a.fsys, a.Cleanup
+	}
+	return startScriptEdwood(t)
+}
+
+func TestScripts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping on windows")
+	}
+	files, err := filepath.Glob(filepath.Join("testdata", "script", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		file := file
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".txt"), func(t *testing.T) {
+			b, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fsys, stop := startScriptAcme(t)
+			defer stop()
+			runScript(t, fsys, file, string(b))
+		})
+	}
+}
+
+func TestScriptArgs(t *testing.T) {
+	for _, tc := range []struct {
+		line  string
+		words []string
+		text  string
+		bad   bool
+	}{
+		{line: "read 2/body hello world", words: []string{"2/body"}, text: "hello world"},
+		{line: `write new/body "a\nb\n"`, words: []string{"new/body"}, text: "a\nb\n"},
+		{line: "events 2", words: []string{"2"}},
+		{line: "events", bad: true},
+		{line: `write 2/body "unterminated`, bad: true},
+	} {
+		_, words, text, err := scriptargs(tc.line, 1)
+		if tc.bad {
+			if err == nil {
+				t.Errorf("%q: parsed bad line", tc.line)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(words, tc.words) || text != tc.text {
+			t.Errorf("%q: got %q, %q, %v; want %q, %q", tc.line, words, text, err, tc.words, tc.text)
+		}
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/rjkroege/edwood/internal/draw"
-	"github.com/rjkroege/edwood/internal/frame"
 )
 
 var scrtmp draw.Image
@@ -17,7 +16,7 @@ func ScrSleep(dt int) {
 		select {
 		case <-timer.C:
 			return
-		case <-mousectl.C:
+		case mousectl.Mouse = <-mousectl.C:
 			timer.Stop()
 			return
 		}
@@ -57,7 +56,7 @@ func scrpos(r image.Rectangle, p0, p1 int, tot int) image.Rectangle {
 
 func ScrlResize(display draw.Display) {
 	var err error
-	scrtmp, err = display.AllocImage(image.Rect(0, 0, 32, display.ScreenImage().R().Max.Y), display.ScreenImage().Pix(), false, draw.Nofill)
+	scrtmp, err = display.AllocImage(image.Rect(0, 0, max(32, display.ScaleSize(Scrollwid)), display.ScreenImage().R().Max.Y), display.ScreenImage().Pix(), false, draw.Nofill)
 	if err != nil {
 		panic(fmt.Sprintf("scroll alloc: %v", err))
 	}
@@ -84,10 +83,10 @@ func (t *Text) ScrDraw(nchars int) {
 	if !r2.Eq(t.lastsr) {
 		t.lastsr = r2
 		// rjk is assuming that only body Text instances have scrollers.
-		b.Draw(r1, textcolors[frame.ColBord], nil, image.Point{})
-		b.Draw(r2, textcolors[frame.ColBack], nil, image.Point{})
+		b.Draw(r1, scrollbarcol, nil, image.Point{})
+		b.Draw(r2, scrollthumbcol, nil, image.Point{})
 		r2.Min.X = r2.Max.X - 1
-		b.Draw(r2, textcolors[frame.ColBord], nil, image.Point{})
+		b.Draw(r2, scrollbarcol, nil, image.Point{})
 		row.display.ScreenImage().Draw(r, b, nil, image.Pt(0, r1.Min.Y))
 		// flushimage(display, 1); // BUG?
 	}
@@ -116,7 +115,7 @@ func (t *Text) Scroll(but int) {
 		}
 		if !mouse.Point.Eq(image.Pt(x, my)) {
 			t.display.MoveTo(image.Pt(x, my))
-			mousectl.Read() // absorb event generated by moveto()
+			readmouse(t.display) // absorb event generated by moveto()
 		}
 		if but == 2 {
 			y = my
@@ -128,7 +127,7 @@ func (t *Text) Scroll(but int) {
 				t.SetOrigin(p0, false)
 			}
 			oldp0 = p0
-			mousectl.Read()
+			readmouse(t.display)
 			if mouse.Buttons&(1<<uint(but-1)) == 0 {
 				break
 			}
@@ -156,6 +155,6 @@ func (t *Text) Scroll(but int) {
 		}
 	}
 	for mouse.Buttons != 0 {
-		mousectl.Read()
+		readmouse(t.display)
 	}
 }
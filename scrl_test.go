@@ -0,0 +1,29 @@
+package main
+
+import (
+	"image"
+	"testing"
+
+	"github.com/rjkroege/edwood/internal/draw"
+)
+
+func TestScrSleepStoresMouse(t *testing.T) {
+	omousectl := mousectl
+	defer func() { mousectl = omousectl }()
+	mousec := make(chan draw.Mouse)
+	mousectl = &draw.Mousectl{C: mousec}
+
+	done := make(chan struct{})
+	go func() {
+		ScrSleep(60 * 1000)
+		close(done)
+	}()
+	// The scroll loops that sleep look at the buttons of the mouse
+	// that woke them.
+	m := draw.Mouse{Point: image.Pt(1, 2), Buttons: 0, Msec: 3}
+	mousec <- m
+	<-done
+	if mousectl.Mouse != m {
+		t.Errorf("mouse is %v; want %v", mousectl.Mouse, m)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/rjkroege/edwood/internal/dumpfile"
+)
+
+// A session is a set of windows, saved as a dump file named after it
+// in the sessions directory, that Edwood can switch to. Switching
+// saves the current session first, as does exiting.
+
+const (
+	sessionsuffix = ".dump"
+	sessionswin   = "+Sessions" // name of the window listing the sessions
+)
+
+// cursession is the name of the current session, or empty if Edwood
+// isn't in one.
+var cursession string
+
+// sessionsdir returns the directory sessions are saved in.
+func sessionsdir() (string, error) {
+	if home == "" {
+		return "", fmt.Errorf("can't find home directory")
+	}
+	return filepath.Join(home, "edwood.sessions"), nil
+}
+
+// validsession returns whether name can name a session: it must be
+// a word of letters, digits, '-', '_' and '.' that doesn't start
+// with '.'.
+func validsession(name string) bool {
+	if name == "" || name[0] == '.' {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_.", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// sessionfile returns the dump file of session name.
+func sessionfile(name string) (string, error) {
+	if !validsession(name) {
+		return "", fmt.Errorf("bad session name %q", name)
+	}
+	dir, err := sessionsdir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+sessionsuffix), nil
+}
+
+// sessionnames returns the names of the saved sessions, sorted.
+func sessionnames() ([]string, error) {
+	dir, err := sessionsdir()
+	if err != nil {
+		return nil, err
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var names []string
+	for _, fi := range fis {
+		name := strings.TrimSuffix(fi.Name(), sessionsuffix)
+		if !fi.IsDir() && name != fi.Name() && validsession(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// SaveSession dumps the windows to the file of the current session,
+// if there is one.
+func (row *Row) SaveSession() error {
+	if cursession == "" {
+		return nil
+	}
+	file, err := sessionfile(cursession)
+	if err != nil {
+		return warnError(nil, "can't save session: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return warnError(nil, "can't save session: %v", err)
+	}
+	if err := row.Dump(file); err != nil {
+		return err
+	}
+	row.showsessions(false)
+	return nil
+}
+
+// SwitchSession replaces the windows with those of session name,
+// after saving the current session. A session that hasn't been saved
+// starts with two empty columns. As with Exit, nothing happens if a
+// window has unsaved changes, though doing it again goes ahead.
+func (row *Row) SwitchSession(name string) error {
+	file, err := sessionfile(name)
+	if err != nil {
+		return warnError(nil, "can't switch session: %v", err)
+	}
+	var dump *dumpfile.Content
+	if _, err := os.Stat(file); err == nil {
+		if dump, err = dumpfile.Load(file); err != nil {
+			return warnError(nil, "can't load session %v: %v", name, err)
+		}
+	}
+	for _, c := range row.col {
+		for _, w := range c.w {
+			// Windows that are dumped with the command that made
+			// them will be made again.
+			if w.nopen[QWevent]+w.nopen[QWaddr]+w.nopen[QWdata]+w.nopen[QWxdata] > 0 && w.dumpstr == "" {
+				return warnError(nil, "can't switch session; %s is running an external command", w.body.file.name)
+			}
+		}
+	}
+	if !row.Clean() {
+		return fmt.Errorf("windows have unsaved changes")
+	}
+	if err := row.SaveSession(); err != nil {
+		return err
+	}
+
+	for len(row.col) > 0 {
+		row.Close(row.col[0], true)
+	}
+	cursession = name
+	if dump == nil {
+		row.Add(nil, -1)
+		row.Add(nil, -1)
+	} else if err := row.Load(dump, file, false); err != nil {
+		return err
+	}
+	row.showsessions(false)
+	return nil
+}
+
+// showsessions lists the sessions in the Sessions window, selecting
+// the current one. The window is made if create is true.
+func (row *Row) showsessions(create bool) {
+	dir, err := sessionsdir()
+	if err != nil {
+		warning(nil, "can't list sessions: %v\n", err)
+		return
+	}
+	name := filepath.Join(dir, sessionswin)
+	w := lookfile(name)
+	if w == nil {
+		if !create {
+			return
+		}
+		if len(row.col) == 0 && row.Add(nil, -1) == nil {
+			warning(nil, "can't make a column for the sessions\n")
+			return
+		}
+		w = row.col[len(row.col)-1].Add(nil, nil, -1)
+		w.filemenu = false
+		w.SetName(name)
+		xfidlog(w, "new")
+	}
+	names, err := sessionnames()
+	if err != nil {
+		warning(nil, "can't list sessions: %v\n", err)
+	}
+	if i := sort.SearchStrings(names, cursession); cursession != "" && (i == len(names) || names[i] != cursession) {
+		names = append(names, cursession) // not saved yet
+		sort.Strings(names)
+	}
+
+	var sb strings.Builder
+	q0, q1 := 0, 0
+	for _, n := range names {
+		if n == cursession {
+			q0 = len([]rune(sb.String()))
+			q1 = q0 + len([]rune(n))
+		}
+		sb.WriteString(n)
+		sb.WriteByte('\n')
+	}
+	t := &w.body
+	t.Delete(0, t.file.Size(), true)
+	t.Insert(0, []rune(sb.String()), true)
+	t.file.Clean()
+	w.SetTag()
+	t.Show(q0, q1, true)
+}
+
+// issessionswin returns whether w is the window listing the sessions.
+func issessionswin(w *Window) bool {
+	dir, err := sessionsdir()
+	return err == nil && w.body.file.name == filepath.Join(dir, sessionswin)
+}
+
+// lookSession implements button 3 in the Sessions window, switching to
+// the session named by the word at q0 or, in the selection, the
+// selection. It returns whether the text named a session.
+func lookSession(t *Text, q0, q1 int) bool {
+	if q1 == q0 {
+		if t.q1 > t.q0 && t.q0 <= q0 && q0 <= t.q1 {
+			q0, q1 = t.q0, t.q1
+		} else {
+			for q0 > 0 && !unicode.IsSpace(t.ReadC(q0-1)) {
+				q0--
+			}
+			for q1 < t.file.Size() && !unicode.IsSpace(t.ReadC(q1)) {
+				q1++
+			}
+		}
+	}
+	r := make([]rune, q1-q0)
+	t.file.b.Read(q0, r)
+	name := strings.TrimSpace(string(r))
+	if !validsession(name) {
+		return false
+	}
+	row.SwitchSession(name)
+	return true
+}
+
+// sessionsx implements the Sessions command, which opens the window
+// listing the sessions. Button 3 on a name there switches to it.
+func sessionsx(*Text, *Text, *Text, bool, bool, string) {
+	row.showsessions(true)
+}
+
+// sessionx implements the Session command, which switches to the
+// session named by its argument, or reports the current one.
+func sessionx(_ *Text, _ *Text, argt *Text, _, _ bool, arg string) {
+	name := strings.TrimSpace(arg)
+	if name == "" {
+		name, _ = getarg(argt, false, true)
+	}
+	if name == "" {
+		if cursession == "" {
+			warning(nil, "Session: not in a session\n")
+		} else {
+			warning(nil, "Session: %v\n", cursession)
+		}
+		return
+	}
+	row.SwitchSession(name)
+}
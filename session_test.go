@@ -0,0 +1,142 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// setSessionsForTesting keeps sessions in a temporary home directory.
+// It returns the working directory and a function that removes the
+// home directory and restores the globals.
+func setSessionsForTesting(t *testing.T) (string, func()) {
+	t.Helper()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get current working directory: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "edwood.test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	oldhome, oldwdir, oldsession := home, wdir, cursession
+	home, wdir, cursession = dir, cwd, ""
+	return cwd, func() {
+		home, wdir, cursession = oldhome, oldwdir, oldsession
+		os.RemoveAll(dir)
+	}
+}
+
+// windownames returns the names of the windows of the row.
+func windownames() []string {
+	var names []string
+	row.AllWindows(func(w *Window) { names = append(names, w.body.file.name) })
+	return names
+}
+
+func TestValidSession(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		ok   bool
+	}{
+		{"work", true},
+		{"edwood-1.2_x", true},
+		{"", false},
+		{".hidden", false},
+		{"a/b", false},
+		{"two words", false},
+		{"+Sessions", false},
+	} {
+		if got := validsession(tc.name); got != tc.ok {
+			t.Errorf("validsession(%q) is %v; want %v", tc.name, got, tc.ok)
+		}
+	}
+}
+
+func TestSwitchSession(t *testing.T) {
+	setGlobalsForLoadTesting()
+	cwd, restore := setSessionsForTesting(t)
+	defer restore()
+
+	alpha := filepath.Join(cwd, "alpha")
+	w := row.Add(nil, -1).Add(nil, nil, -1)
+	w.SetName(alpha)
+	seq++
+	w.body.file.Mark(seq)
+	w.body.Insert(0, []rune("alpha\n"), true)
+	cursession = "one"
+
+	if err := row.SwitchSession("two"); err == nil {
+		t.Fatalf("switched session with unsaved changes")
+	}
+	if err := row.SwitchSession("two"); err != nil {
+		t.Fatalf("can't switch session after warning of changes: %v", err)
+	}
+	if cursession != "two" || len(row.col) != 2 || len(windownames()) != 0 {
+		t.Fatalf("in session %q with %v columns and windows %q; want two, 2 and none",
+			cursession, len(row.col), windownames())
+	}
+
+	if err := row.SwitchSession("one"); err != nil {
+		t.Fatalf("can't switch back to session: %v", err)
+	}
+	if got, want := windownames(), []string{alpha}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("windows are %q; want %q", got, want)
+	}
+	if got, want := string(row.col[0].w[0].body.file.b), "alpha\n"; got != want {
+		t.Errorf("body is %q; want %q", got, want)
+	}
+	names, err := sessionnames()
+	if err != nil {
+		t.Fatalf("sessionnames failed: %v", err)
+	}
+	if want := []string{"one", "two"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("sessions are %q; want %q", names, want)
+	}
+
+	if err := row.SwitchSession("../escape"); err == nil {
+		t.Errorf("switched to session with bad name")
+	}
+}
+
+func TestSessionsWindow(t *testing.T) {
+	setGlobalsForLoadTesting()
+	_, restore := setSessionsForTesting(t)
+	defer restore()
+	row.Add(nil, -1)
+	cursession = "one"
+	if err := row.SaveSession(); err != nil {
+		t.Fatalf("SaveSession failed: %v", err)
+	}
+	cursession = "two"
+
+	sessionsx(nil, nil, nil, false, false, "")
+	dir, _ := sessionsdir()
+	w := lookfile(filepath.Join(dir, sessionswin))
+	if w == nil {
+		t.Fatalf("no sessions window")
+	}
+	if !issessionswin(w) {
+		t.Errorf("issessionswin is false for sessions window")
+	}
+	if got, want := string(w.body.file.b), "one\ntwo\n"; got != want {
+		t.Errorf("sessions window holds %q; want %q", got, want)
+	}
+	if w.body.q0 != 4 || w.body.q1 != 7 {
+		t.Errorf("selection is %v,%v; want the current session, 4,7", w.body.q0, w.body.q1)
+	}
+
+	// Button 3 on a word that isn't a session name is looked up as usual.
+	w.body.Insert(w.body.file.Size(), []rune("a/b\n"), true)
+	if lookSession(&w.body, 9, 9) {
+		t.Errorf("looked up %q as a session", "a/b")
+	}
+	if !lookSession(&w.body, 1, 1) {
+		t.Fatalf("didn't look up %q as a session", "one")
+	}
+	if cursession != "one" {
+		t.Errorf("in session %q; want one", cursession)
+	}
+}
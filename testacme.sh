@@ -1,6 +0,0 @@
-#!/bin/bash
-
-mkdir /tmp/testacme
-export NAMESPACE=/tmp/testacme
-acme  $*
-
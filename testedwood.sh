@@ -1,6 +0,0 @@
-#!/bin/bash
-
-mkdir /tmp/testedwood
-export NAMESPACE=/tmp/testedwood
-./edwood -validateboxes $*
-
@@ -75,8 +75,14 @@ type Text struct {
 	iq1 int
 	eq0 int
 
-	nofill   bool // When true, updates to the Text shouldn't update the frame.
-	needundo bool
+	nofill      bool   // When true, updates to the Text shouldn't update the frame.
+	highlighted bool   // When true, the frame has styled text.
+	match       []Span // The bracket beside the insertion point and its match.
+	gutter      gutter
+	needundo    bool
+
+	nowrap bool // When true, lines are clipped at the right edge of the frame instead of folded.
+	xoff   int  // Pixels of each line scrolled out of view to the left when nowrap is set.
 
 	lk sync.Mutex
 }
@@ -139,10 +145,11 @@ func (t *Text) Redraw(r image.Rectangle, odx int, noredraw bool) {
 		}
 	}
 
-	t.fr.Init(r, frame.OptMaxTab(maxt))
+	r = t.layoutgutter(r)
+	t.fr.Init(r, frame.OptMaxTab(maxt), frame.OptNoWrap(t.nowrap, t.xoff), frame.OptWhitespace(t.whitespacecolour()), frame.OptTick(tickstyle, tickblink))
 	if !noredraw {
 		enclosing := r
-		enclosing.Min.X -= t.display.ScaleSize(Scrollwid + Scrollgap)
+		enclosing.Min.X -= t.display.ScaleSize(Scrollwid+Scrollgap) + t.gutter.r.Dx()
 		t.fr.Redraw(enclosing)
 	}
 
@@ -154,6 +161,7 @@ func (t *Text) Redraw(r image.Rectangle, odx int, noredraw bool) {
 		}
 	} else {
 		t.fill(t.fr)
+		t.highlight()
 		t.SetSelect(t.q0, t.q1)
 	}
 }
@@ -421,6 +429,7 @@ func (t *Text) inserted(q0 int, r []rune) {
 		t.w.utflastqid = -1
 	}
 	n := len(r)
+	t.gutterinserted(q0, r)
 	if q0 < t.iq1 {
 		t.iq1 += n
 	}
@@ -438,6 +447,7 @@ func (t *Text) inserted(q0 int, r []rune) {
 		}
 	}
 
+	t.highlight()
 	t.logInsert(q0, r)
 	t.SetSelect(t.q0, t.q1)
 	if t.fr != nil && t.display != nil {
@@ -568,6 +578,7 @@ func (t *Text) deleted(q0, q1 int) {
 	if t.what == Body {
 		t.w.utflastqid = -1
 	}
+	t.gutterdeleted(q0, q1)
 	if q0 < t.iq1 {
 		t.iq1 -= min(n, t.iq1-q0)
 	}
@@ -594,6 +605,7 @@ func (t *Text) deleted(q0, q1 int) {
 		t.fr.Delete((p0), (p1))
 		t.fill(t.fr)
 	}
+	t.highlight()
 
 	t.logInsertDelete(q0, q1)
 
@@ -813,6 +825,12 @@ func (t *Text) Type(r rune) {
 		n = 2 * t.fr.GetFrameFillStatus().Maxlines / 3
 		caseDown()
 		return
+	case Kscrolloneleft:
+		t.ScrollX(-1)
+		return
+	case Kscrolloneright:
+		t.ScrollX(1)
+		return
 	case draw.KeyUp:
 		if t.what == Tag {
 			Tagup()
@@ -884,6 +902,22 @@ func (t *Text) Type(r rune) {
 		t.TypeCommit()
 		undo(t, nil, nil, false, false, "")
 		return
+	case draw.KeyCmd + 'm': // %M: jump to matching bracket
+		t.TypeCommit()
+		t.JumpMatch()
+		return
+	case draw.KeyCmd + 'b': // %B: balance the windows of the column
+		t.TypeCommit()
+		balancex(t, nil, nil, false, false, "")
+		return
+	case draw.KeyCmd + 'e': // %E: equalise the widths of the columns
+		t.TypeCommit()
+		equalisex(t, nil, nil, false, false, "")
+		return
+	case draw.KeyCmd + 'f': // %F: zoom the window, or put its column back
+		t.TypeCommit()
+		zoomx(t, nil, nil, false, false, "")
+		return
 
 	}
 	if t.what == Body {
@@ -981,6 +1015,7 @@ func (t *Text) Type(r rune) {
 		// TODO(rjk): I'd like the Undo op to group typing better.
 		t.file.Commit()
 		t.Delete(q0, q0+nnb, true)
+		t.showx(t.q0)
 
 		// Run through the code that will update the t.w.body.file.name.
 		t.TypeCommit()
@@ -1009,6 +1044,7 @@ func (t *Text) Type(r rune) {
 	// otherwise ordinary character; just insert, typically in caches of all texts
 	t.file.InsertAtWithoutCommit(t.q0, rp[:nr])
 	t.SetSelect(t.q0+nr, t.q0+nr)
+	t.showx(t.q0)
 
 	// TODO(rjk): Do we always want to commit if editing a
 	// a tag?
@@ -1091,7 +1127,7 @@ func (t *Text) Select() {
 		// stay here until something interesting happens
 		// TODO(rjk): Ack. This is horrible? Layering violation?
 		for {
-			mousectl.Read()
+			readmouse(t.display)
 			if !(mouse.Buttons == b && abs(mouse.Point.X-x) < 3 && abs(mouse.Point.Y-y) < 3) {
 				break
 			}
@@ -1170,7 +1206,7 @@ func (t *Text) Select() {
 		}
 		t.display.Flush()
 		for mouse.Buttons == b {
-			mousectl.Read()
+			readmouse(t.display)
 		}
 		clicktext = nil
 	}
@@ -1233,6 +1269,7 @@ func (t *Text) Show(q0, q1 int, doselect bool) {
 			t.SetOrigin(t.org+1, false)
 		}
 	}
+	t.showx(q0)
 }
 
 // TODO(rjk): remove me in a subsequent CL.
@@ -1272,6 +1309,8 @@ func (t *Text) SetSelect(q0, q1 int) {
 	}
 
 	t.fr.DrawSel(t.fr.Ptofchar(p0), p0, p1, ticked)
+	t.drawgutter()
+	t.showmatch()
 }
 
 // TODO(rjk): The implicit initialization of q0, q1 doesn't seem like very nice
@@ -1285,7 +1324,7 @@ func (t *Text) Select23(high draw.Image, mask uint) (q0, q1 int, buts uint) {
 		q1 = p1 + t.org
 	}
 	for mousectl.Mouse.Buttons != 0 {
-		mousectl.Read()
+		readmouse(t.display)
 	}
 	return q0, q1, buts
 }
@@ -1367,9 +1406,14 @@ func (t *Text) DoubleClick(inq0, inq1 int) (q0, q1 int) {
 }
 
 func (t *Text) ClickMatch(cl, cr rune, dir int, inq int) (q int, r bool) {
+	return t.clickmatch(cl, cr, dir, inq, t.file.Size())
+}
+
+// clickmatch is ClickMatch looking at no more than limit runes.
+func (t *Text) clickmatch(cl, cr rune, dir int, inq int, limit int) (q int, r bool) {
 	nest := 1
 	var c rune
-	for {
+	for ; limit > 0; limit-- {
 		if dir > 0 {
 			if inq == t.file.Size() {
 				break
@@ -1555,6 +1599,10 @@ func (t *Text) setorigin(fr frame.SelectScrollUpdater, org int, exact bool, call
 			org++
 		}
 	}
+	// Each line of a frame that doesn't wrap starts a row.
+	if t.nowrap {
+		org = t.linestart(org)
+	}
 	a = org - t.org
 	if a >= 0 && a < fr.GetFrameFillStatus().Nchars {
 		fr.Delete(0, a)
@@ -1570,6 +1618,8 @@ func (t *Text) setorigin(fr frame.SelectScrollUpdater, org int, exact bool, call
 	}
 	t.org = org
 	t.fill(fr)
+	t.highlight()
+	t.drawgutter()
 	t.ScrDraw(fr.GetFrameFillStatus().Nchars)
 
 	if !calledfromscroll {
@@ -1584,6 +1634,7 @@ func (t *Text) Reset() {
 	t.q0 = 0
 	t.q1 = 0
 	t.file.Reset()
+	t.file.highlightdeleted(0, t.file.b.nc())
 	t.file.b.Reset()
 }
 
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/rjkroege/edwood/internal/draw"
+	"github.com/rjkroege/edwood/internal/frame"
+)
+
+// ThemeColour names one of the colours set by a Theme.
+type ThemeColour int
+
+const (
+	ThemeTagBack     ThemeColour = iota // background of tags
+	ThemeTagHigh                        // background of selected text in tags
+	ThemeTagBorder                      // tag buttons and the line between tag and body
+	ThemeTagText                        // text in tags
+	ThemeTagHText                       // selected text in tags
+	ThemeBack                           // background of bodies
+	ThemeHigh                           // background of selected text in bodies
+	ThemeBorder                         // body buttons
+	ThemeText                           // text in bodies
+	ThemeHText                          // selected text in bodies
+	ThemeModButton                      // centre of the button of a modified window
+	ThemeColButton                      // button of a column
+	ThemeBut2                           // text being selected with button 2
+	ThemeBut3                           // text being selected with button 3
+	ThemeScrollBar                      // scroll bar
+	ThemeScrollThumb                    // part of the scroll bar showing the visible text
+	ThemeGutter                         // line numbers in the gutter
+	ThemeWhitespace                     // markers of visible blanks
+	NumThemeColours
+)
+
+var themecolournames = [NumThemeColours]string{
+	ThemeTagBack:     "tagback",
+	ThemeTagHigh:     "taghigh",
+	ThemeTagBorder:   "tagborder",
+	ThemeTagText:     "tagtext",
+	ThemeTagHText:    "taghtext",
+	ThemeBack:        "back",
+	ThemeHigh:        "high",
+	ThemeBorder:      "border",
+	ThemeText:        "text",
+	ThemeHText:       "htext",
+	ThemeModButton:   "modbutton",
+	ThemeColButton:   "colbutton",
+	ThemeBut2:        "but2",
+	ThemeBut3:        "but3",
+	ThemeScrollBar:   "scrollbar",
+	ThemeScrollThumb: "scrollthumb",
+	ThemeGutter:      "gutter",
+	ThemeWhitespace:  "whitespace",
+}
+
+func (c ThemeColour) String() string {
+	if c >= 0 && c < NumThemeColours {
+		return themecolournames[c]
+	}
+	return fmt.Sprintf("ThemeColour(%d)", int(c))
+}
+
+// themecolour is a colour in a Theme. If mix is not zero, the colour is
+// a mix of c and mix as made by draw.Display.AllocImageMix.
+type themecolour struct {
+	c, mix draw.Color
+}
+
+// Theme is a set of colours used to draw Edwood: those of the tags,
+// bodies, buttons and scroll bars and the colours of highlighted
+// tokens.
+type Theme struct {
+	colours [NumThemeColours]themecolour
+	tokens  [NumTokenKinds]draw.Color
+}
+
+// namedcolours are the names that a theme can use for the colours of
+// draw(3).
+var namedcolours = map[string]draw.Color{
+	"black":         0x000000FF,
+	"white":         0xFFFFFFFF,
+	"red":           0xFF0000FF,
+	"green":         0x00FF00FF,
+	"blue":          0x0000FFFF,
+	"cyan":          0x00FFFFFF,
+	"magenta":       0xFF00FFFF,
+	"yellow":        0xFFFF00FF,
+	"paleyellow":    0xFFFFAAFF,
+	"darkyellow":    0xEEEE9EFF,
+	"darkgreen":     0x448844FF,
+	"palegreen":     0xAAFFAAFF,
+	"medgreen":      0x88CC88FF,
+	"darkblue":      0x000055FF,
+	"palebluegreen": 0xAAFFFFFF,
+	"paleblue":      0x0000BBFF,
+	"bluegreen":     0x008888FF,
+	"greygreen":     0x55AAAAFF,
+	"palegreygreen": 0x9EEEEEFF,
+	"yellowgreen":   0x99994CFF,
+	"medblue":       0x000099FF,
+	"greyblue":      0x005DBBFF,
+	"palegreyblue":  0x4993DDFF,
+	"purpleblue":    0x8888CCFF,
+}
+
+// defaulttheme returns the colours of acme.
+func defaulttheme() *Theme {
+	th := &Theme{tokens: tokencolours}
+	th.colours = [NumThemeColours]themecolour{
+		ThemeTagBack:     {namedcolours["palebluegreen"], namedcolours["white"]},
+		ThemeTagHigh:     {c: namedcolours["palegreygreen"]},
+		ThemeTagBorder:   {c: namedcolours["purpleblue"]},
+		ThemeTagText:     {c: namedcolours["black"]},
+		ThemeTagHText:    {c: namedcolours["black"]},
+		ThemeBack:        {namedcolours["paleyellow"], namedcolours["white"]},
+		ThemeHigh:        {c: namedcolours["darkyellow"]},
+		ThemeBorder:      {c: namedcolours["yellowgreen"]},
+		ThemeText:        {c: namedcolours["black"]},
+		ThemeHText:       {c: namedcolours["black"]},
+		ThemeModButton:   {c: namedcolours["medblue"]},
+		ThemeColButton:   {c: namedcolours["purpleblue"]},
+		ThemeBut2:        {c: 0xAA0000FF},
+		ThemeBut3:        {c: 0x006600FF},
+		ThemeScrollBar:   {c: namedcolours["yellowgreen"]},
+		ThemeScrollThumb: {namedcolours["paleyellow"], namedcolours["white"]},
+		ThemeGutter:      {c: 0x888888FF},
+		ThemeWhitespace:  {c: 0xBBBBBBFF},
+	}
+	return th
+}
+
+// theme is the current theme.
+var theme = defaulttheme()
+
+// parsecolour parses a colour given as #rrggbb, #rrggbbaa or one of
+// the names in namedcolours.
+func parsecolour(s string) (draw.Color, error) {
+	if c, ok := namedcolours[strings.ToLower(s)]; ok {
+		return c, nil
+	}
+	if strings.HasPrefix(s, "#") && (len(s) == 7 || len(s) == 9) {
+		v, err := strconv.ParseUint(s[1:], 16, 32)
+		if err == nil {
+			if len(s) == 7 {
+				v = v<<8 | 0xFF
+			}
+			return draw.Color(v), nil
+		}
+	}
+	return 0, fmt.Errorf("bad colour %q", s)
+}
+
+// formatcolour returns c in the form read by parsecolour.
+func formatcolour(c draw.Color) string {
+	if c&0xFF == 0xFF {
+		return fmt.Sprintf("#%06x", uint32(c)>>8)
+	}
+	return fmt.Sprintf("#%08x", uint32(c))
+}
+
+// parsetheme reads a theme. Each line of a theme sets a colour, named
+// by a ThemeColour or a TokenKind, to one colour or to a mix of two:
+//
+//	# A dark theme.
+//	back #1e1e1e
+//	text #d4d4d4
+//	tagback palebluegreen white
+//	keyword #569cd6
+//
+// Colours are given as #rrggbb, #rrggbbaa or the name of one of the
+// colours of draw(3). Colours that aren't set are those of the default
+// theme. Blank lines and lines starting with # are ignored.
+func parsetheme(rd io.Reader) (*Theme, error) {
+	th := defaulttheme()
+	sc := bufio.NewScanner(rd)
+	for n := 1; sc.Scan(); n++ {
+		f := strings.Fields(sc.Text())
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		if len(f) < 2 || len(f) > 3 {
+			return nil, fmt.Errorf("line %d: want a name and one or two colours", n)
+		}
+		var cs [2]draw.Color
+		for i, s := range f[1:] {
+			c, err := parsecolour(s)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			cs[i] = c
+		}
+		if k, err := parseTokenKind(f[0]); err == nil && k != TokenNone {
+			if len(f) > 2 {
+				return nil, fmt.Errorf("line %d: can't mix colours of %v", n, k)
+			}
+			th.tokens[k] = cs[0]
+			continue
+		}
+		i := 0
+		for i < int(NumThemeColours) && themecolournames[i] != f[0] {
+			i++
+		}
+		if i == int(NumThemeColours) {
+			return nil, fmt.Errorf("line %d: unknown colour name %q", n, f[0])
+		}
+		th.colours[i] = themecolour{cs[0], cs[1]}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return th, nil
+}
+
+// loadtheme reads the theme in file name.
+func loadtheme(name string) (*Theme, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	th, err := parsetheme(f)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", name, err)
+	}
+	return th, nil
+}
+
+// String returns th in the form read by parsetheme.
+func (th *Theme) String() string {
+	var sb strings.Builder
+	for i, c := range th.colours {
+		fmt.Fprintf(&sb, "%s %s", ThemeColour(i), formatcolour(c.c))
+		if c.mix != 0 {
+			fmt.Fprintf(&sb, " %s", formatcolour(c.mix))
+		}
+		sb.WriteByte('\n')
+	}
+	for k := TokenKeyword; k < NumTokenKinds; k++ {
+		fmt.Fprintf(&sb, "%v %s\n", k, formatcolour(th.tokens[k]))
+	}
+	return sb.String()
+}
+
+// image allocates an image of colour c on display.
+func (c themecolour) image(display draw.Display) draw.Image {
+	if c.mix != 0 {
+		return display.AllocImageMix(c.c, c.mix)
+	}
+	i, _ := display.AllocImage(image.Rect(0, 0, 1, 1), display.ScreenImage().Pix(), true, c.c)
+	return i
+}
+
+// themeinit allocates the colours of th on display.
+func themeinit(display draw.Display, th *Theme) {
+	col := func(c ThemeColour) draw.Image { return th.colours[c].image(display) }
+
+	tagcolors[frame.ColBack] = col(ThemeTagBack)
+	tagcolors[frame.ColHigh] = col(ThemeTagHigh)
+	tagcolors[frame.ColBord] = col(ThemeTagBorder)
+	tagcolors[frame.ColText] = col(ThemeTagText)
+	tagcolors[frame.ColHText] = col(ThemeTagHText)
+	textcolors[frame.ColBack] = col(ThemeBack)
+	textcolors[frame.ColHigh] = col(ThemeHigh)
+	textcolors[frame.ColBord] = col(ThemeBorder)
+	textcolors[frame.ColText] = col(ThemeText)
+	textcolors[frame.ColHText] = col(ThemeHText)
+	modbuttoncol = col(ThemeModButton)
+	colbuttoncol = col(ThemeColButton)
+	but2col = col(ThemeBut2)
+	but3col = col(ThemeBut3)
+	scrollbarcol = col(ThemeScrollBar)
+	scrollthumbcol = col(ThemeScrollThumb)
+	guttercol = col(ThemeGutter)
+	whitespacecol = col(ThemeWhitespace)
+	hlstylesinit(display, th.tokens)
+}
+
+// SetTheme makes th the current theme and draws everything again in
+// its colours.
+func SetTheme(display draw.Display, th *Theme) {
+	theme = th
+	if display == nil {
+		return
+	}
+	themeinit(display, th)
+	iconinit(display)
+
+	settext := func(t *Text, cols [frame.NumColours]draw.Image) {
+		if t.fr != nil {
+			t.fr.Init(t.fr.Rect(), frame.OptColors(cols))
+		}
+	}
+	settext(&row.tag, tagcolors)
+	for _, c := range row.col {
+		settext(&c.tag, tagcolors)
+		for _, w := range c.w {
+			settext(&w.tag, tagcolors)
+			settext(&w.body, textcolors)
+		}
+	}
+	row.Resize(row.r)
+	display.Flush()
+}
+
+// themex implements the Theme command. With the name of a theme file,
+// relative to the directory of the window, it switches to that theme;
+// with the argument default, it switches to the default theme. Without
+// an argument, it shows the current theme.
+func themex(et *Text, _ *Text, argt *Text, _, _ bool, arg string) {
+	r, _ := getarg(argt, false, true)
+	if r == "" {
+		r = strings.TrimSpace(arg)
+	}
+	switch r {
+	case "":
+		warning(nil, "%s", theme)
+		return
+	case "default":
+		SetTheme(row.display, defaulttheme())
+		return
+	}
+	if !filepath.IsAbs(r) && et != nil {
+		r = et.AbsDirName(r)
+	}
+	th, err := loadtheme(r)
+	if err != nil {
+		warning(nil, "Theme: %v\n", err)
+		return
+	}
+	SetTheme(row.display, th)
+}
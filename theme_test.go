@@ -0,0 +1,130 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rjkroege/edwood/internal/draw"
+	"github.com/rjkroege/edwood/internal/edwoodtest"
+	"github.com/rjkroege/edwood/internal/frame"
+)
+
+func TestParseTheme(t *testing.T) {
+	th, err := parsetheme(strings.NewReader(`
+# A dark theme.
+back #1e1e1e
+text   #D4D4D4
+tagback palebluegreen white
+high #26406080
+keyword #569cd6
+`))
+	if err != nil {
+		t.Fatalf("parsetheme failed: %v", err)
+	}
+	want := defaulttheme()
+	want.colours[ThemeBack] = themecolour{c: 0x1E1E1EFF}
+	want.colours[ThemeText] = themecolour{c: 0xD4D4D4FF}
+	want.colours[ThemeHigh] = themecolour{c: 0x26406080}
+	want.tokens[TokenKeyword] = 0x569CD6FF
+	if !reflect.DeepEqual(th, want) {
+		t.Errorf("parsed theme is\n%v\nwant\n%v", th, want)
+	}
+
+	for _, s := range []string{
+		"back",
+		"back #fff",
+		"back #1e1e1e #1e1e1e #1e1e1e",
+		"back plaid",
+		"sideways #1e1e1e",
+		"keyword red white",
+	} {
+		if _, err := parsetheme(strings.NewReader(s)); err == nil {
+			t.Errorf("parsetheme of %q succeeded", s)
+		}
+	}
+}
+
+func TestThemeString(t *testing.T) {
+	want := defaulttheme()
+	want.colours[ThemeHigh] = themecolour{c: 0x26406080}
+	th, err := parsetheme(strings.NewReader(want.String()))
+	if err != nil {
+		t.Fatalf("parsetheme failed: %v", err)
+	}
+	if !reflect.DeepEqual(th, want) {
+		t.Errorf("theme read back is\n%v\nwant\n%v", th, want)
+	}
+}
+
+func TestSetTheme(t *testing.T) {
+	defer func() { theme = defaulttheme() }()
+	display, restore := setGlobalsForDrawTesting(image.Rect(0, 0, 200, 100))
+	defer restore()
+	themeinit(display, theme)
+	w := addDrawTestingWindow("/a/file.txt", "hello\n")
+	old := textcolors
+
+	dir, err := ioutil.TempDir("", "edwood.theme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "dark")
+	if err := ioutil.WriteFile(name, []byte("back black\ntext white\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := acmectlwrite("theme " + name + "\n"); err != nil {
+		t.Fatalf("theme control message failed: %v", err)
+	}
+	if got, want := theme.colours[ThemeBack], (themecolour{c: 0x000000FF}); got != want {
+		t.Errorf("background is %v; want %v", got, want)
+	}
+	for _, c := range []int{frame.ColBack, frame.ColText} {
+		if textcolors[c] == old[c] {
+			t.Errorf("body colour %d not allocated again", c)
+		}
+	}
+	// The body is drawn again in the colours of the theme.
+	r := w.body.fr.Rect()
+	if got, want := edwoodtest.Snapshot(t, display, r).At(r.Max.X-1, r.Max.Y-1), (color.RGBA{0, 0, 0, 0xFF}); got != want {
+		t.Errorf("body background is %v; want %v", got, want)
+	}
+
+	if err := acmectlwrite("theme default"); err != nil {
+		t.Fatalf("theme control message failed: %v", err)
+	}
+	if !reflect.DeepEqual(theme, defaulttheme()) {
+		t.Errorf("theme is not the default")
+	}
+	for _, s := range []string{"theme", "theme " + filepath.Join(dir, "missing"), "paint it black"} {
+		if err := acmectlwrite(s); err == nil {
+			t.Errorf("control message %q succeeded", s)
+		}
+	}
+}
+
+// Make sure that the colours of draw(3) have the values of the draw
+// package.
+func TestNamedColours(t *testing.T) {
+	for name, c := range map[string]draw.Color{
+		"palebluegreen": draw.Palebluegreen,
+		"paleyellow":    draw.Paleyellow,
+		"darkyellow":    draw.Darkyellow,
+		"purpleblue":    draw.Purpleblue,
+		"medblue":       draw.Medblue,
+		"yellowgreen":   draw.Yellowgreen,
+		"palegreygreen": draw.Palegreygreen,
+		"white":         draw.White,
+	} {
+		if got := namedcolours[name]; got != c {
+			t.Errorf("%v is %#x; want %#x", name, got, c)
+		}
+	}
+}
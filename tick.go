@@ -0,0 +1,40 @@
+package main
+
+import (
+	"time"
+
+	"github.com/rjkroege/edwood/internal/frame"
+)
+
+var (
+	tickstyle frame.TickStyle // how the insertion point is drawn
+	tickblink time.Duration   // how long the insertion point is shown and hidden for when it blinks
+)
+
+// SetTick sets how the insertion points of every frame are drawn and
+// blink and draws everything again.
+func SetTick(style frame.TickStyle, blink time.Duration) {
+	tickstyle = style
+	tickblink = blink
+	if row.display == nil {
+		return
+	}
+	row.Resize(row.r)
+	row.display.Flush()
+}
+
+// parseblink parses the interval at which the insertion point blinks:
+// a duration or off.
+func parseblink(s string) (time.Duration, error) {
+	if s == "off" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d, nil
+}
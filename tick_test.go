@@ -0,0 +1,61 @@
+package main
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"github.com/rjkroege/edwood/internal/edwoodtest"
+	"github.com/rjkroege/edwood/internal/frame"
+)
+
+func TestSetTick(t *testing.T) {
+	defer func() { tickstyle, tickblink = frame.TickThin, 0 }()
+	display, restore := setGlobalsForDrawTesting(image.Rect(0, 0, 200, 100))
+	defer restore()
+	w := addDrawTestingWindow("/a/file.txt", "")
+
+	// inked counts the pixels of the insertion point at the start of
+	// the empty body that differ from its background.
+	inked := func() int {
+		pt := w.body.fr.Ptofchar(0)
+		h := w.body.fr.DefaultFontHeight()
+		m := edwoodtest.Snapshot(t, display, image.Rect(pt.X-2, pt.Y, pt.X+2*h, pt.Y+h))
+		back := edwoodtest.Snapshot(t, display, w.body.fr.Rect()).At(w.body.fr.Rect().Max.X-1, w.body.fr.Rect().Max.Y-1)
+		n := 0
+		b := m.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if m.At(x, y) != back {
+					n++
+				}
+			}
+		}
+		return n
+	}
+	thin := inked()
+	if thin == 0 {
+		t.Fatalf("no insertion point drawn")
+	}
+
+	if err := acmectlwrite("tick block\nblink 500ms\n"); err != nil {
+		t.Fatalf("tick control messages failed: %v", err)
+	}
+	if tickstyle != frame.TickBlock || tickblink != 500*time.Millisecond {
+		t.Errorf("tick is %v blinking every %v; want block blinking every 500ms", tickstyle, tickblink)
+	}
+	if block := inked(); block <= thin {
+		t.Errorf("block insertion point inks %d pixels; want more than the %d of a thin one", block, thin)
+	}
+	if err := acmectlwrite("blink off"); err != nil {
+		t.Fatalf("blink control message failed: %v", err)
+	}
+	if tickblink != 0 {
+		t.Errorf("tick blinks every %v; want no blinking", tickblink)
+	}
+	for _, s := range []string{"tick", "tick wavy", "blink", "blink often"} {
+		if err := acmectlwrite(s); err == nil {
+			t.Errorf("control message %q succeeded", s)
+		}
+	}
+}
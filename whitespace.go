@@ -0,0 +1,129 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/rjkroege/edwood/internal/draw"
+)
+
+// whitespacecol is the colour of the markers drawn over visible blanks.
+var whitespacecol draw.Image
+
+// isblank returns true if r is white space that can trail a line.
+func isblank(r rune) bool {
+	return r == ' ' || r == '\t'
+}
+
+// SetWhitespace sets whether the blanks in w's body are made visible.
+func (w *Window) SetWhitespace(visible bool) {
+	if w.whitespace == visible {
+		return
+	}
+	w.whitespace = visible
+	t := &w.body
+	if t.fr == nil || t.display == nil {
+		return
+	}
+	t.relayout()
+	t.ScrDraw(t.fr.GetFrameFillStatus().Nchars)
+}
+
+// SetTrailing sets whether the white space at the ends of the lines of
+// w's body is highlighted.
+func (w *Window) SetTrailing(trailing bool) {
+	w.trailing = trailing
+	w.body.highlight()
+}
+
+// whitespacecolour returns the colour in which t's frame draws blanks
+// or nil if they aren't visible.
+func (t *Text) whitespacecolour() draw.Image {
+	if t.what != Body || t.w == nil || !t.w.whitespace {
+		return nil
+	}
+	return whitespacecol
+}
+
+// trailingspans returns the runs of white space at the ends of the
+// lines of t's File, clipped to [q0, q1).
+func (t *Text) trailingspans(q0, q1 int) []Span {
+	var spans []Span
+	start := -1
+	for q := q0; q < q1; q++ {
+		switch c := t.file.ReadC(q); {
+		case isblank(c):
+			if start < 0 {
+				start = q
+			}
+		case c == '\n' && start >= 0:
+			spans = append(spans, Span{start, q, TokenTrailing})
+			start = -1
+		default:
+			start = -1
+		}
+	}
+	if start >= 0 {
+		q, n := q1, t.file.Size()
+		for q < n && isblank(t.file.ReadC(q)) {
+			q++
+		}
+		if q == n || t.file.ReadC(q) == '\n' {
+			spans = append(spans, Span{start, q1, TokenTrailing})
+		}
+	}
+	return spans
+}
+
+// StripTrailing deletes the white space at the ends of the lines of w's
+// body as one change that can be undone. It returns true if there was
+// any.
+func (w *Window) StripTrailing() bool {
+	t := &w.body
+	w.Commit(t)
+	spans := t.trailingspans(0, t.file.Size())
+	if len(spans) == 0 {
+		return false
+	}
+	seq++
+	t.file.Mark(seq)
+	for i := len(spans) - 1; i >= 0; i-- {
+		t.Delete(spans[i].Q0, spans[i].Q1, true)
+	}
+	w.SetTag()
+	return true
+}
+
+// whitespacex implements the Whitespace command. Without an argument,
+// it makes the blanks of the window's body visible or hides them
+// again; on and off do one or the other. The arguments trailing and
+// notrailing turn the highlighting of white space at the ends of lines
+// on and off; strip and nostrip set whether Put deletes it before
+// writing the file.
+func whitespacex(et *Text, _ *Text, argt *Text, _, _ bool, arg string) {
+	if et == nil || et.w == nil {
+		return
+	}
+	w := et.w
+	r, _ := getarg(argt, false, true)
+	if r == "" {
+		r = strings.TrimSpace(arg)
+	}
+	switch r {
+	case "":
+		w.SetWhitespace(!w.whitespace)
+	case "on":
+		w.SetWhitespace(true)
+	case "off":
+		w.SetWhitespace(false)
+	case "trailing":
+		w.SetTrailing(true)
+	case "notrailing":
+		w.SetTrailing(false)
+	case "strip":
+		w.strip = true
+	case "nostrip":
+		w.strip = false
+	default:
+		warning(nil, "Whitespace: bad argument %q\n", r)
+	}
+}
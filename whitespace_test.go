@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTrailingSpans(t *testing.T) {
+	w := loadWindowForTesting(t, "a  \nb\t\n  c\nd ")
+
+	for _, tc := range []struct {
+		q0, q1 int
+		want   []Span
+	}{
+		{0, 13, []Span{{1, 3, TokenTrailing}, {5, 6, TokenTrailing}, {12, 13, TokenTrailing}}},
+		{2, 12, []Span{{2, 3, TokenTrailing}, {5, 6, TokenTrailing}}},
+		{0, 2, []Span{{1, 2, TokenTrailing}}},
+		{7, 9, nil},
+	} {
+		got := w.body.trailingspans(tc.q0, tc.q1)
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("trailingspans(%v, %v) mismatch (-want +got):\n%s", tc.q0, tc.q1, diff)
+		}
+	}
+
+	spans := []Span{{0, 4, TokenComment}, {9, 10, TokenKeyword}}
+	want := []Span{{0, 1, TokenComment}, {1, 3, TokenTrailing}, {3, 4, TokenComment}, {5, 6, TokenTrailing}, {9, 10, TokenKeyword}, {12, 13, TokenTrailing}}
+	if diff := cmp.Diff(want, overlayspans(spans, w.body.trailingspans(0, 13))); diff != "" {
+		t.Errorf("overlayspans mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestStripTrailing(t *testing.T) {
+	text := "a  \nb\t\n  c\nd "
+	w := loadWindowForTesting(t, text)
+	themeinit(row.display, theme)
+	w.SetTrailing(true)
+	w.SetWhitespace(true)
+	if c := w.body.whitespacecolour(); c == nil || c != whitespacecol {
+		t.Errorf("blanks are not visible")
+	}
+
+	if !w.StripTrailing() {
+		t.Fatalf("StripTrailing found no white space")
+	}
+	if got, want := w.body.file.b.String(), "a\nb\n  c\nd"; got != want {
+		t.Errorf("stripped body is %q; want %q", got, want)
+	}
+	if w.StripTrailing() {
+		t.Errorf("StripTrailing of a stripped body found white space")
+	}
+	w.Undo(true)
+	if got := w.body.file.b.String(); got != text {
+		t.Errorf("body after undo is %q; want %q", got, text)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"sync"
 
 	"github.com/rjkroege/edwood/internal/draw"
+	"github.com/rjkroege/edwood/internal/dumpfile"
 	"github.com/rjkroege/edwood/internal/frame"
 )
 
@@ -21,9 +22,14 @@ type Window struct {
 	r       image.Rectangle
 
 	//	isdir      bool // true if this Window is showing a directory in its body.
-	filemenu   bool
-	autoindent bool
-	showdel    bool
+	filemenu    bool
+	autoindent  bool
+	showdel     bool
+	nohighlight bool // true if syntax highlighting is turned off
+	whitespace  bool // true if blanks in the body are visible
+	trailing    bool // true if white space at the ends of lines is highlighted
+	strip       bool // true if Put deletes white space at the ends of lines
+	nomatch     bool // true if the match of the bracket beside dot isn't highlighted
 
 	id    int
 	addr  Range
@@ -47,6 +53,8 @@ type Window struct {
 	ctlfid      uint32     // ctl file Fid which has the ctrllock
 	dumpstr     string
 	dumpdir     string
+	dumpenv     []string
+	restart     dumpfile.Restart
 	utflastqid  int    // Qid of last read request (QWbody or QWtag)
 	utflastboff uint64 // Byte offset of last read of body or tag
 	utflastq    int    // Rune offset of last read of body or tag
@@ -93,9 +101,16 @@ func (w *Window) initHeadless(clone *Window) *Window {
 
 	w.filemenu = true
 	w.autoindent = *globalAutoIndent
+	w.body.gutter.mode, _ = parseGutterMode(*globalGutter)
 
 	if clone != nil {
 		w.autoindent = clone.autoindent
+		w.body.gutter.mode = clone.body.gutter.mode
+		w.body.nowrap = clone.body.nowrap
+		w.body.xoff = clone.body.xoff
+		w.whitespace = clone.whitespace
+		w.trailing = clone.trailing
+		w.strip = clone.strip
 	}
 	w.editoutlk = make(chan bool, 1)
 	return w
@@ -121,7 +136,7 @@ func (w *Window) Init(clone *Window, r image.Rectangle, dis draw.Display) {
 		w.tag.SetSelect(len(w.tag.file.b), len(w.tag.file.b))
 	}
 	r1 = r
-	r1.Min.Y += w.taglines*fontget(tagfont, w.display).Height() + 1
+	r1.Min.Y += w.taglines*fontget(tagfont, w.display).Height() + sepwidth(w.display)
 	if r1.Max.Y < r1.Min.Y {
 		r1.Max.Y = r1.Min.Y
 	}
@@ -134,8 +149,8 @@ func (w *Window) Init(clone *Window, r image.Rectangle, dis draw.Display) {
 	}
 	w.body.Init(r1, rf, textcolors, w.display)
 	w.body.what = Body
-	r1.Min.Y--
-	r1.Max.Y = r1.Min.Y + 1
+	r1.Min.Y -= sepwidth(w.display)
+	r1.Max.Y = r1.Min.Y + sepwidth(w.display)
 	if w.display != nil {
 		w.display.ScreenImage().Draw(r1, tagcolors[frame.ColBord], nil, image.Point{})
 	}
@@ -288,13 +303,14 @@ func (w *Window) Resize(r image.Rectangle, safe, keepextra bool) int {
 	r1.Min.Y = y
 	if !safe || !w.body.all.Eq(r1) {
 		oy := y
-		if y+1+w.body.fr.DefaultFontHeight() <= r.Max.Y { // room for one line
+		sep := sepwidth(w.display)
+		if y+sep+w.body.fr.DefaultFontHeight() <= r.Max.Y { // room for one line
 			r1.Min.Y = y
-			r1.Max.Y = y + 1
+			r1.Max.Y = y + sep
 			if w.display != nil {
 				w.display.ScreenImage().Draw(r1, tagcolors[frame.ColBord], nil, image.Point{})
 			}
-			y++
+			y += sep
 			r1.Min.Y = min(y, r.Max.Y)
 			r1.Max.Y = r.Max.Y
 		} else {
@@ -401,6 +417,7 @@ func (w *Window) Undo(isundo bool) {
 func (w *Window) SetName(name string) {
 	t := &w.body
 	t.file.SetName(name)
+	w.sethighlighter()
 
 	w.SetTag()
 }
@@ -6,12 +6,15 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"unicode/utf8"
 
 	"9fans.net/go/plan9"
 	"github.com/rjkroege/edwood/internal/draw"
+	"github.com/rjkroege/edwood/internal/dumpfile"
+	"github.com/rjkroege/edwood/internal/frame"
 	"github.com/rjkroege/edwood/internal/ninep"
 	"github.com/rjkroege/edwood/internal/runes"
 )
@@ -32,7 +35,8 @@ func (x *Xfid) respond(t *plan9.Fcall, err error) *Xfid {
 	return x.fs.respond(x, t, err)
 }
 
-func xfidctl(x *Xfid, d draw.Display) {
+// xfidctl runs the functions sent to x.c, then hands x back on free.
+func xfidctl(x *Xfid, d draw.Display, free chan<- *Xfid) {
 	// log.Println("xfidctl", x)
 	// defer log.Println("done xfidctl")
 	for f := range x.c {
@@ -40,7 +44,7 @@ func xfidctl(x *Xfid, d draw.Display) {
 		if d != nil {
 			d.Flush()
 		} // d here is for testability.
-		cxfidfree <- x
+		free <- x
 	}
 }
 
@@ -57,7 +61,7 @@ func xfidflush(x *Xfid) {
 		for _, w := range c.w {
 			w.Lock('E')
 			wx := w.eventx
-			if wx != nil && wx.fcall.Tag == x.fcall.Oldtag {
+			if wx != nil && wx.fs == x.fs && wx.fcall.Tag == x.fcall.Oldtag {
 				w.eventx = nil
 				wx.flushed = true
 				wx.c <- nil
@@ -155,6 +159,7 @@ func xfidopen(x *Xfid) {
 			}
 			w.wrselrange = Range{t.q1, t.q1}
 		}
+		x.f.snapshot = genfilesnapshot(q, w)
 		w.Unlock()
 	} else {
 		switch q {
@@ -168,6 +173,7 @@ func xfidopen(x *Xfid) {
 				return
 			}
 		}
+		x.f.snapshot = genfilesnapshot(q, nil)
 	}
 	fc.Qid = x.f.qid
 	fc.Iounit = uint32(x.fs.msize() - plan9.IOHDRSZ)
@@ -183,6 +189,7 @@ func xfidclose(x *Xfid) {
 	w := x.f.w
 	x.f.busy = false
 	x.f.w = nil
+	x.f.snapshot = nil
 	if !x.f.open {
 		if w != nil {
 			w.Close()
@@ -223,6 +230,8 @@ func xfidclose(x *Xfid) {
 				if q == QWevent {
 					w.dumpstr = ""
 					w.dumpdir = ""
+					w.dumpenv = nil
+					w.restart = dumpfile.RestartOnLoad
 				}
 			}
 		case QWrdsel:
@@ -262,7 +271,7 @@ func xfidread(x *Xfid) {
 		case Qcons: // Do nothing.
 		case Qlabel: // Do nothing.
 		case Qindex:
-			xfidindexread(x)
+			xfidgenread(x, nil)
 			return
 		case Qlog:
 			xfidlogread(x)
@@ -292,9 +301,8 @@ func xfidread(x *Xfid) {
 	case QWbody:
 		xfidutfread(x, &w.body, w.body.Nc(), int(QWbody))
 
-	case QWctl:
-		ninep.ReadString(&fc, &x.fcall, w.CtlPrint(true))
-		x.respond(&fc, nil)
+	case QWctl, QWspans:
+		xfidgenread(x, w)
 
 	case QWevent:
 		xfideventread(x, w)
@@ -435,13 +443,24 @@ func xfidwrite(x *Xfid) {
 	//x.fcall.Data[x.fcall.Count] = 0; // null-terminate. unneeded
 	switch qid {
 	case Qcons:
+		// Lock row before the window it may add, as Qnew does.
+		row.lk.Lock()
 		w = errorwin(x.f.mntdir, 'X')
+		row.lk.Unlock()
 		updateText(&w.body)
 
 	case Qlabel:
 		fc.Count = x.fcall.Count
 		x.respond(&fc, nil)
 
+	case Qctl:
+		if err := acmectlwrite(string(x.fcall.Data)); err != nil {
+			x.respond(&fc, err)
+			break
+		}
+		fc.Count = x.fcall.Count
+		x.respond(&fc, nil)
+
 	case QWaddr:
 		r := []rune(string(x.fcall.Data))
 		t := &w.body
@@ -484,8 +503,18 @@ func xfidwrite(x *Xfid) {
 		updateText(&w.body)
 
 	case QWctl:
+		x.f.snapshot = nil // next read reflects the new state
 		xfidctlwrite(x, w)
 
+	case QWspans:
+		x.f.snapshot = nil
+		if err := spanswrite(w, string(x.fcall.Data)); err != nil {
+			x.respond(&fc, err)
+			break
+		}
+		fc.Count = x.fcall.Count
+		x.respond(&fc, nil)
+
 	case QWdata:
 		a := w.addr
 		t := &w.body
@@ -538,6 +567,96 @@ func xfidwrite(x *Xfid) {
 	}
 }
 
+// acmectlwrite carries out the control messages in data, written to
+// the ctl file at the top of the file system, which affect all of
+// Edwood instead of a single window.
+func acmectlwrite(data string) error {
+	row.lk.Lock()
+	defer row.lk.Unlock()
+
+	for _, line := range strings.Split(data, "\n") {
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			continue
+		}
+		switch words[0] {
+		case "theme": // switch to the default theme or the one in a file
+			if len(words) != 2 {
+				return ErrBadCtl
+			}
+			th := defaulttheme()
+			if words[1] != "default" {
+				var err error
+				if th, err = loadtheme(words[1]); err != nil {
+					return err
+				}
+			}
+			SetTheme(row.display, th)
+		case "tick": // change how the insertion point is drawn
+			if len(words) != 2 {
+				return ErrBadCtl
+			}
+			style, err := frame.ParseTickStyle(words[1])
+			if err != nil {
+				return err
+			}
+			SetTick(style, tickblink)
+		case "blink": // blink the insertion point or stop it blinking
+			if len(words) != 2 {
+				return ErrBadCtl
+			}
+			d, err := parseblink(words[1])
+			if err != nil {
+				return err
+			}
+			SetTick(tickstyle, d)
+		case "scale": // scale everything by a factor or by the resolution
+			if len(words) != 2 {
+				return ErrBadCtl
+			}
+			s, err := parsescale(words[1])
+			if err != nil {
+				return err
+			}
+			SetScale(row.display, s)
+		case "screenshot": // write a PNG of the screen
+			if len(words) != 2 {
+				return ErrBadCtl
+			}
+			if err := screenshot(row.display, row.r, words[1]); err != nil {
+				return err
+			}
+		case "dump": // dump the state of Edwood to a file
+			if len(words) != 2 {
+				return ErrBadCtl
+			}
+			if err := row.Dump(words[1]); err != nil {
+				return err
+			}
+		case "balance": // make the windows of each column all as tall
+			for _, c := range row.col {
+				c.Balance()
+			}
+		case "equalise": // make the columns all as wide
+			row.Equalise()
+		case "replay": // replay recorded mouse and keyboard events
+			if len(words) != 2 {
+				return ErrBadCtl
+			}
+			// The events are handled with the row locked.
+			row.lk.Unlock()
+			err := replayfile(row.display, words[1])
+			row.lk.Lock()
+			if err != nil {
+				return err
+			}
+		default:
+			return ErrBadCtl
+		}
+	}
+	return nil
+}
+
 func xfidctlwrite(x *Xfid, w *Window) {
 	// log.Println("xfidctlwrite", x)
 	// defer log.Println("done xfidctlwrite")
@@ -594,17 +713,11 @@ forloop:
 				err = ErrBadCtl
 				break forloop
 			}
-			r, _, nulls := cvttorunes([]byte(words[1]), len(words[1]))
-			if nulls {
-				err = fmt.Errorf("nulls in file name")
+			var r []rune
+			r, err = filenamerunes(words[1])
+			if err != nil {
 				break forloop
 			}
-			for _, rr := range r {
-				if rr <= ' ' {
-					err = fmt.Errorf("bad character in file name")
-					break forloop
-				}
-			}
 			seq++
 			w.body.file.Mark(seq)
 			w.SetName(string(r))
@@ -619,6 +732,7 @@ forloop:
 				break forloop
 			}
 			w.dumpstr = string(r)
+			w.dumpenv = dumpenviron(w.dumpenv)
 		case "dumpdir": // set dump directory
 			if len(words) < 2 {
 				err = ErrBadCtl
@@ -630,6 +744,31 @@ forloop:
 				break forloop
 			}
 			w.dumpdir = string(r)
+		case "dumpenv": // set a variable of the dump environment
+			if len(words) < 2 {
+				err = ErrBadCtl
+				break forloop
+			}
+			kv := words[1] // the rest of the line, since a value may hold spaces
+			if strings.IndexByte(kv, '=') <= 0 {
+				err = ErrBadCtl
+				break forloop
+			}
+			if strings.IndexByte(kv, 0) >= 0 {
+				err = fmt.Errorf("nulls in dump environment")
+				break forloop
+			}
+			w.dumpenv = setenv(w.dumpenv, kv)
+		case "restart": // set when Load runs the dump command again
+			if len(words) < 2 {
+				err = ErrBadCtl
+				break forloop
+			}
+			var restart dumpfile.Restart
+			if restart, err = parseRestart(words[1]); err != nil {
+				break forloop
+			}
+			w.restart = restart
 		case "delete": // delete for sure
 			w.col.Close(w, true)
 			w = nil
@@ -672,6 +811,62 @@ forloop:
 		case "cleartag": // wipe tag right of bar
 			w.ClearTag()
 			settag = true
+		case "highlight": // turn on syntax highlighting
+			w.nohighlight = false
+			w.body.highlight()
+		case "nohighlight": // turn off syntax highlighting
+			w.nohighlight = true
+			w.body.highlight()
+		case "gutter": // show line numbers
+			mode := GutterAbsolute
+			if len(words) > 1 {
+				mode, err = parseGutterMode(words[1])
+				if err != nil || mode == GutterNone {
+					err = ErrBadCtl
+					break forloop
+				}
+			}
+			w.SetGutter(mode)
+		case "nogutter": // hide line numbers
+			w.SetGutter(GutterNone)
+		case "nowrap": // clip long lines and scroll horizontally
+			w.SetNoWrap(true)
+		case "wrap": // fold long lines
+			w.SetNoWrap(false)
+		case "whitespace": // make blanks visible
+			w.SetWhitespace(true)
+		case "nowhitespace": // hide blanks
+			w.SetWhitespace(false)
+		case "trailing": // highlight white space at the ends of lines
+			w.SetTrailing(true)
+		case "notrailing": // stop highlighting trailing white space
+			w.SetTrailing(false)
+		case "strip": // delete trailing white space on put
+			w.strip = true
+		case "nostrip": // keep trailing white space on put
+			w.strip = false
+		case "match": // highlight the match of the bracket beside dot
+			w.nomatch = false
+			w.body.showmatch()
+		case "nomatch": // stop highlighting matching brackets
+			w.nomatch = true
+			w.body.showmatch()
+		case "balance": // make the windows of the column all as tall
+			w.col.Balance()
+		case "zoom": // make the window as tall as can be, or put the column back
+			w.col.Zoom(w)
+		case "screenshot": // write a PNG of the window
+			if len(words) != 2 {
+				err = ErrBadCtl
+				break forloop
+			}
+			name := words[1]
+			if !filepath.IsAbs(name) {
+				name = w.body.AbsDirName(name)
+			}
+			if err = screenshot(w.display, w.r, name); err != nil {
+				break forloop
+			}
 
 		default:
 			err = ErrBadCtl
@@ -700,6 +895,91 @@ forloop:
 	}
 }
 
+// filenamerunes converts name to runes, checking that it is
+// suitable as a window's file name.
+func filenamerunes(name string) ([]rune, error) {
+	r, _, nulls := cvttorunes([]byte(name), len(name))
+	if nulls {
+		return nil, fmt.Errorf("nulls in file name")
+	}
+	for _, rr := range r {
+		if rr <= ' ' {
+			return nil, fmt.Errorf("bad character in file name")
+		}
+	}
+	return r, nil
+}
+
+// xfidwstat responds to a plan9.Twstat request with directory entry d.
+// The only changes supported are truncating the body to zero length,
+// which clears the window, and setting the name of the window
+// directory, which sets the window's file name.
+func xfidwstat(x *Xfid, d *plan9.Dir) {
+	var fc plan9.Fcall
+
+	w := x.f.w
+	w.Lock('E')
+	defer w.Unlock()
+	if w.col == nil {
+		x.respond(&fc, ErrDeletedWin)
+		return
+	}
+
+	// Reject changes to anything other than length and name.
+	var null plan9.Dir
+	null.Null()
+	if d.Type != null.Type || d.Dev != null.Dev || d.Qid != null.Qid ||
+		d.Mode != null.Mode || d.Atime != null.Atime || d.Mtime != null.Mtime ||
+		d.Uid != null.Uid || d.Gid != null.Gid || d.Muid != null.Muid {
+		x.respond(&fc, ErrPermission)
+		return
+	}
+
+	q := FILE(x.f.qid)
+	perm := x.f.dir.perm
+	if q == Qdir {
+		perm = windowDirTab(w.id).perm
+	}
+	writable := perm&0200 != 0
+	if d.Length != null.Length && (q != QWbody || d.Length != 0 || !writable) {
+		x.respond(&fc, ErrPermission)
+		return
+	}
+	var name []rune
+	if d.Name != null.Name {
+		if q != Qdir || !writable {
+			x.respond(&fc, ErrPermission)
+			return
+		}
+		var err error
+		name, err = filenamerunes(d.Name)
+		if err != nil {
+			x.respond(&fc, err)
+			return
+		}
+	}
+
+	if d.Length == 0 {
+		t := &w.body
+		w.Commit(t)
+		if t.Nc() > 0 {
+			seq++
+			t.file.Mark(seq)
+			t.Delete(0, t.Nc(), true)
+			t.SetSelect(0, 0)
+			t.ScrDraw(t.fr.GetFrameFillStatus().Nchars)
+		}
+		w.ClampAddr()
+		w.SetTag()
+	}
+	if name != nil {
+		seq++
+		w.body.file.Mark(seq)
+		w.SetName(string(name))
+	}
+	x.respond(&fc, nil)
+}
+
 func xfideventwrite(x *Xfid, w *Window) {
 	var err error
 
@@ -939,28 +1219,59 @@ func xfideventread(x *Xfid, w *Window) {
 	w.events = w.events[n:]
 }
 
-func xfidindexread(x *Xfid) {
-	// log.Println("xfidindexread", x)
-	// defer log.Println("done xfidindexread")
+// genfiles maps the file identifier (e.g. Qindex) of each generated
+// file to the function producing its contents. A generated file is
+// snapshotted per fid when it is opened, so that a client reading it
+// in small chunks sees a consistent listing even if windows are
+// created, deleted or modified between the reads. Window files are
+// generated with the window locked; the others are passed a nil window.
+var genfiles = map[uint64]func(w *Window) []byte{
+	Qindex: indexbytes,
+	QWctl: func(w *Window) []byte {
+		return []byte(w.CtlPrint(true))
+	},
+	QWspans: spansbytes,
+}
 
-	// BUG(fhs): This is broken when the client is doing a sequential
-	// read using a very small buffer and we create/delete windows
-	// in-between the requests.
+// genfilesnapshot returns the current contents of generated file q
+// for window w, or nil if q is not a generated file.
+func genfilesnapshot(q uint64, w *Window) []byte {
+	gen, ok := genfiles[q]
+	if !ok {
+		return nil
+	}
+	b := gen(w)
+	if b == nil {
+		b = []byte{}
+	}
+	return b
+}
 
-	row.lk.Lock()
-	nmax := 0
-	for _, c := range row.col {
-		for _, w := range c.w {
-			nmax += Ctlsize + w.tag.Nc()*utf8.UTFMax + 1
-		}
+// xfidgenread responds to a read of a generated file from the snapshot
+// taken by xfidopen. If the fid has no snapshot (e.g. it was never
+// opened, or a write invalidated it), a new one is taken first.
+func xfidgenread(x *Xfid, w *Window) {
+	if x.f.snapshot == nil {
+		x.f.snapshot = genfilesnapshot(FILE(x.f.qid), w)
 	}
+	var fc plan9.Fcall
+	ninep.ReadBuffer(&fc, &x.fcall, x.f.snapshot)
+	x.respond(&fc, nil)
+}
+
+// indexbytes generates the contents of the acme/index file. It locks
+// each window in turn, so none may be locked by the caller.
+func indexbytes(*Window) []byte {
+	row.lk.Lock()
+	defer row.lk.Unlock()
 
-	nmax++
 	var sb strings.Builder
 	for _, c := range row.col {
 		for _, w := range c.w {
+			w.Lock('F')
 			// only show the currently active window of a set
 			if w.body.file.curtext != &w.body {
+				w.Unlock()
 				continue
 			}
 			sb.WriteString(w.CtlPrint(false))
@@ -974,11 +1285,8 @@ func xfidindexread(x *Xfid) {
 			}
 			sb.WriteString(string(tag))
 			sb.WriteString("\n")
+			w.Unlock()
 		}
 	}
-	row.lk.Unlock()
-
-	var fc plan9.Fcall
-	ninep.ReadString(&fc, &x.fcall, sb.String())
-	x.respond(&fc, nil)
+	return []byte(sb.String())
 }
@@ -13,6 +13,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"9fans.net/go/plan9"
 	"github.com/google/go-cmp/cmp"
@@ -21,50 +22,40 @@ import (
 )
 
 func TestXfidallocthread(t *testing.T) {
-	cxfidalloc = make(chan *Xfid)
-	cxfidfree = make(chan *Xfid)
+	xa := newXfidAllocator()
 
 	ctx, cancel := context.WithCancel(context.Background())
 
 	d := (draw.Display)(nil)
 	done := make(chan struct{})
 	go func() {
-		xfidallocthread(ctx, d)
-		close(cxfidalloc)
-		close(cxfidfree)
-		cxfidalloc = nil
-		cxfidfree = nil
+		xfidallocthread(ctx, xa, d)
 		close(done)
 	}()
 
-	cxfidalloc <- (*Xfid)(nil) // Request an xfid
-	x := <-cxfidalloc
+	x := xa.get() // Request an xfid
 	if x == nil {
 		t.Errorf("Failed to get an Xfid")
 	}
-	cxfidfree <- x
+	xa.put(x)
 
 	cancel() // Ask xfidallocthread to finish up.
 
-	// Wait for xfidallocthread to return and global channels to be reset.
+	// Wait for xfidallocthread to return.
 	<-done
 }
 
 func TestXfidctl(t *testing.T) {
-	cxfidfree = make(chan *Xfid)
-	defer func() {
-		close(cxfidfree)
-		cxfidfree = nil
-	}()
+	free := make(chan *Xfid)
 
 	x := &Xfid{c: make(chan func(*Xfid))}
 	defer close(x.c)
-	go xfidctl(x, edwoodtest.NewDisplay())
+	go xfidctl(x, edwoodtest.NewDisplay(), free)
 
 	called := false
 	x.c <- func(x *Xfid) { called = true }
 
-	if got := <-cxfidfree; got != x {
+	if got := <-free; got != x {
 		t.Errorf("got freed Xfid %v; want %v", got, x)
 	}
 	if !called {
@@ -753,6 +744,53 @@ func TestXfidwriteQcons(t *testing.T) {
 	}
 }
 
+func TestXfidwriteQconsLocksRow(t *testing.T) {
+	configureGlobals()
+	row.Init(image.Rectangle{
+		image.Point{0, 0},
+		image.Point{800, 600},
+	}, edwoodtest.NewDisplay())
+
+	data := []byte("cons error\n")
+	mr := new(mockResponder)
+	x := &Xfid{
+		fcall: plan9.Fcall{
+			Data:  data,
+			Count: uint32(len(data)),
+		},
+		f: &Fid{
+			qid: plan9.Qid{Path: QID(0, Qcons)},
+		},
+		fs: mr,
+	}
+
+	// The +Errors window is added to the row, so a write to cons
+	// must wait while another thread holds row.lk.
+	row.lk.Lock()
+	done := make(chan struct{})
+	go func() {
+		xfidwrite(x)
+		close(done)
+	}()
+	select {
+	case <-done:
+		row.lk.Unlock()
+		t.Fatalf("write to cons did not wait for the row lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if len(row.col) != 0 {
+		t.Errorf("row has %v columns while locked; want 0", len(row.col))
+	}
+	row.lk.Unlock()
+	<-done
+	if mr.err != nil {
+		t.Fatalf("got error %v; want nil", mr.err)
+	}
+	if w := lookfile(errorwin1Name("")); w == nil {
+		t.Errorf("+Errors window not created")
+	}
+}
+
 func TestXfidwriteQWerrors(t *testing.T) {
 	data := []byte("window error: Hello, 世界!\n")
 	mr := new(mockResponder)
@@ -867,6 +905,17 @@ func TestXfidwriteQWctl(t *testing.T) {
 		{nil, "dumpdir /home/gopher"},
 		{ErrBadCtl, "dumpdir"},
 		{fmt.Errorf("nulls in dump directory string"), "dumpdir /home\u0000/gopher"},
+		{nil, "dumpenv PATH=/bin:/usr/bin"},
+		{nil, "dumpenv EMPTY="},
+		{ErrBadCtl, "dumpenv"},
+		{ErrBadCtl, "dumpenv PATH"},
+		{ErrBadCtl, "dumpenv =/bin"},
+		{fmt.Errorf("nulls in dump environment"), "dumpenv PATH=/bin\u0000"},
+		{nil, "restart never"},
+		{nil, "restart onload"},
+		{nil, "restart always"},
+		{ErrBadCtl, "restart"},
+		{ErrBadCtl, "restart sometimes"},
 		{nil, "delete"},
 		{fmt.Errorf("file dirty"), "del"},
 		{fmt.Errorf("file dirty"), "del\ndel"},
@@ -880,6 +929,23 @@ func TestXfidwriteQWctl(t *testing.T) {
 		{nil, "nomenu"},
 		{nil, "menu"},
 		{nil, "cleartag"},
+		{nil, "highlight"},
+		{nil, "nohighlight"},
+		{nil, "gutter"},
+		{nil, "gutter relative"},
+		{ErrBadCtl, "gutter sideways"},
+		{ErrBadCtl, "gutter off"},
+		{nil, "nogutter"},
+		{nil, "nowrap"},
+		{nil, "wrap"},
+		{nil, "whitespace"},
+		{nil, "nowhitespace"},
+		{nil, "trailing"},
+		{nil, "notrailing"},
+		{nil, "strip"},
+		{nil, "nostrip"},
+		{nil, "match"},
+		{nil, "nomatch"},
 		{ErrBadCtl, "brewcoffee"},
 		{ErrDeletedWin, "delete\nclean"},
 		{ErrDeletedWin, "delete\nget"},
@@ -936,6 +1002,46 @@ func TestXfidwriteQWctl(t *testing.T) {
 	}
 }
 
+func TestXfidwriteQWctlDumpenv(t *testing.T) {
+	configureGlobals()
+	warnings = nil
+	cwarn = nil
+
+	display := edwoodtest.NewDisplay()
+	w := NewWindow().initHeadless(nil)
+	w.display = display
+	w.col = &Column{
+		w:       []*Window{w},
+		display: display,
+	}
+	w.body.display = display
+	w.body.fr = &MockFrame{}
+	w.tag.display = display
+	w.tag.fr = &MockFrame{}
+
+	for _, data := range []string{"dumpenv FOO=a b", "dumpenv BAR=x\ndumpenv FOO=a  b c "} {
+		mr := new(mockResponder)
+		x := &Xfid{
+			fcall: plan9.Fcall{
+				Data:  []byte(data),
+				Count: uint32(len(data)),
+			},
+			f: &Fid{
+				qid: plan9.Qid{Path: QID(0, QWctl)},
+				w:   w,
+			},
+			fs: mr,
+		}
+		xfidwrite(x)
+		if mr.err != nil {
+			t.Fatalf("%q: got error %v; want nil", data, mr.err)
+		}
+	}
+	if got, want := w.dumpenv, []string{"FOO=a  b c ", "BAR=x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dumpenv is %q; want %q", got, want)
+	}
+}
+
 func TestXfidwriteQWevent(t *testing.T) {
 	for _, tc := range []struct {
 		err  error
@@ -1484,3 +1590,157 @@ func readIndexFile(t *testing.T, filename string) []byte {
 	}
 	return replacePathsForTesting(t, b, false)
 }
+
+func TestXfidreadQindexSnapshot(t *testing.T) {
+	filename := editDumpFileForTesting(t, filepath.Join("testdata", "example.dump"))
+	defer os.Remove(filename)
+
+	setGlobalsForLoadTesting()
+
+	err := row.Load(nil, filename, true)
+	if err != nil {
+		t.Fatalf("Row.Load failed: %v", err)
+	}
+	want := indexbytes(nil)
+
+	mr := new(mockResponder)
+	x := &Xfid{
+		f: &Fid{
+			qid: plan9.Qid{Path: QID(0, Qindex)},
+		},
+		fs: mr,
+	}
+	xfidopen(x)
+	if mr.err != nil {
+		t.Fatalf("xfidopen returned error %v", mr.err)
+	}
+
+	// Read in small chunks while deleting windows in-between the reads.
+	var got []byte
+	for {
+		x.fcall.Offset = uint64(len(got))
+		x.fcall.Count = 16
+		xfidread(x)
+		if mr.err != nil {
+			t.Fatalf("xfidread returned error %v", mr.err)
+		}
+		if len(mr.fcall.Data) == 0 {
+			break
+		}
+		got = append(got, mr.fcall.Data...)
+		if c := row.col[0]; len(c.w) > 0 {
+			c.w = c.w[1:]
+		}
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("index data mismatch (-want +got):\n%s", diff)
+	}
+
+	xfidclose(x)
+	if x.f.snapshot != nil {
+		t.Errorf("snapshot not released on close: %q", x.f.snapshot)
+	}
+}
+
+func TestIndexbytesLocksWindows(t *testing.T) {
+	filename := editDumpFileForTesting(t, filepath.Join("testdata", "example.dump"))
+	defer os.Remove(filename)
+
+	setGlobalsForLoadTesting()
+
+	err := row.Load(nil, filename, true)
+	if err != nil {
+		t.Fatalf("Row.Load failed: %v", err)
+	}
+	want := indexbytes(nil)
+
+	// A window held by another thread, such as one running a
+	// command, is not read until it is released.
+	w := row.col[0].w[0]
+	w.Lock('E')
+	c := make(chan []byte)
+	go func() {
+		c <- indexbytes(nil)
+	}()
+	select {
+	case <-c:
+		w.Unlock()
+		t.Fatalf("indexbytes did not wait for a locked window")
+	case <-time.After(50 * time.Millisecond):
+	}
+	w.Unlock()
+	if diff := cmp.Diff(want, <-c); diff != "" {
+		t.Errorf("index data mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestXfidwstat(t *testing.T) {
+	length := func(n uint64) *plan9.Dir {
+		var d plan9.Dir
+		d.Null()
+		d.Length = n
+		return &d
+	}
+	name := func(s string) *plan9.Dir {
+		var d plan9.Dir
+		d.Null()
+		d.Name = s
+		return &d
+	}
+	mode := func(m plan9.Perm) *plan9.Dir {
+		var d plan9.Dir
+		d.Null()
+		d.Mode = m
+		return &d
+	}
+
+	for _, tc := range []struct {
+		name     string
+		q        uint64
+		dir      *DirTab
+		d        *plan9.Dir
+		err      error
+		body     string
+		filename string
+	}{
+		{"TruncateBody", QWbody, dirtabw[2], length(0), nil, "", "/etc/hosts"},
+		{"TruncateBodyNonZero", QWbody, dirtabw[2], length(3), ErrPermission, "Hello, 世界!\n", "/etc/hosts"},
+		{"TruncateTag", QWtag, dirtabw[10], length(0), ErrPermission, "Hello, 世界!\n", "/etc/hosts"},
+		{"Rename", Qdir, dirtabw[0], name("/tmp/hosts"), nil, "Hello, 世界!\n", "/tmp/hosts"},
+		{"RenameBadName", Qdir, dirtabw[0], name("/tmp/ hosts"), fmt.Errorf("bad character in file name"), "Hello, 世界!\n", "/etc/hosts"},
+		{"RenameBody", QWbody, dirtabw[2], name("/tmp/hosts"), ErrPermission, "Hello, 世界!\n", "/etc/hosts"},
+		{"Chmod", QWbody, dirtabw[2], mode(0666), ErrPermission, "Hello, 世界!\n", "/etc/hosts"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := NewWindow().initHeadless(nil)
+			w.col = new(Column)
+			w.display = edwoodtest.NewDisplay()
+			w.body.fr = &MockFrame{}
+			w.tag.fr = &MockFrame{}
+			w.body.what = Body
+			w.tag.what = Tag
+			w.body.file.b = Buffer("Hello, 世界!\n")
+			w.body.file.SetName("/etc/hosts")
+
+			mr := new(mockResponder)
+			x := &Xfid{
+				f: &Fid{
+					qid: plan9.Qid{Path: QID(w.id, tc.q)},
+					w:   w,
+					dir: tc.dir,
+				},
+				fs: mr,
+			}
+			xfidwstat(x, tc.d)
+			if !reflect.DeepEqual(mr.err, tc.err) {
+				t.Errorf("got error %v; want %v", mr.err, tc.err)
+			}
+			if got := w.body.file.b.String(); got != tc.body {
+				t.Errorf("body is %q; want %q", got, tc.body)
+			}
+			if got := w.body.file.name; got != tc.filename {
+				t.Errorf("file name is %q; want %q", got, tc.filename)
+			}
+		})
+	}
+}